@@ -17,6 +17,8 @@ import (
 	"github.com/AlieNoori/social/internal/ratelimiter"
 	"github.com/AlieNoori/social/internal/store"
 	"github.com/AlieNoori/social/internal/store/cache"
+	"github.com/AlieNoori/social/internal/stream"
+	"github.com/AlieNoori/social/internal/webhook"
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
 	httpSwagger "github.com/swaggo/http-swagger/v2"
@@ -31,6 +33,9 @@ type application struct {
 	mailer        mailer.Client
 	authenticator auth.Authenticator
 	rateLimiter   ratelimiter.Limiter
+	broker        stream.Broker
+	webhookSender *webhook.Sender
+	jobs          jobGroup
 }
 
 type config struct {
@@ -43,6 +48,42 @@ type config struct {
 	auth        authConfig
 	redisCfg    redisConfig
 	rateLimiter ratelimiter.Config
+	cleanup     cleanupConfig
+	timeline    timelineConfig
+	trending    trendingConfig
+	stream      streamConfig
+	webhooks    webhooksConfig
+	outbox      outboxConfig
+}
+
+type outboxConfig struct {
+	pollInterval time.Duration
+	batchSize    int
+	retention    time.Duration
+}
+
+type webhooksConfig struct {
+	pollInterval time.Duration
+	batchSize    int
+	timeout      time.Duration
+}
+
+type streamConfig struct {
+	heartbeat time.Duration
+}
+
+type trendingConfig struct {
+	windows         []time.Duration
+	refreshInterval time.Duration
+}
+
+type timelineConfig struct {
+	celebrityThreshold int
+}
+
+type cleanupConfig struct {
+	interval    time.Duration
+	gracePeriod time.Duration
 }
 
 type redisConfig struct {
@@ -55,6 +96,7 @@ type redisConfig struct {
 type authConfig struct {
 	basic basicConfig
 	token tokenConfig
+	login loginConfig
 }
 
 type basicConfig struct {
@@ -62,16 +104,43 @@ type basicConfig struct {
 	pass string
 }
 
+type loginConfig struct {
+	maxAttempts int
+	lockout     time.Duration
+}
+
 type tokenConfig struct {
-	secret string
-	exp    time.Duration
-	iss    string
+	secret     string
+	keySetPath string
+	// keySetReload is how often the keyset is read from disk again, it is
+	// also reloaded on SIGHUP
+	keySetReload time.Duration
+	exp          time.Duration
+	refreshExp   time.Duration
+	iss          string
 }
 
 type mailConfig struct {
-	sendGrid  sendGridConfig
-	exp       time.Duration
-	fromEmail string
+	// backend is one of sendgrid, smtp, file or log
+	backend      string
+	sendGrid     sendGridConfig
+	smtp         smtpConfig
+	fileDir      string
+	exp          time.Duration
+	resetExp     time.Duration
+	fromEmail    string
+	workers      int
+	pollInterval time.Duration
+	batchSize    int
+	// retention is how long sent and dead emails are kept
+	retention time.Duration
+}
+
+type smtpConfig struct {
+	host     string
+	port     int
+	username string
+	password string
 }
 
 type sendGridConfig struct {
@@ -94,7 +163,12 @@ func (app *application) mount() http.Handler {
 		r.Use(app.RateLimiterMiddleware)
 	}
 
-	r.Use(middleware.Timeout(60 * time.Second))
+	// the event stream stays open for as long as the client is connected
+	r.Use(middleware.Maybe(middleware.Timeout(60*time.Second), func(r *http.Request) bool {
+		return r.URL.Path != "/v1/stream"
+	}))
+
+	r.Get("/.well-known/jwks.json", app.jwksHandler)
 
 	r.Route("/v1", func(r chi.Router) {
 		// r.With(app.BasicAuthMiddleware()).Get("/health", app.healthCheckHandler)
@@ -113,11 +187,27 @@ func (app *application) mount() http.Handler {
 				r.Get("/", app.getPostHandler)
 				r.Patch("/", app.checkPostOwnership("moderator", app.updatePostHandler))
 				r.Delete("/", app.checkPostOwnership("admin", app.deletePostHandler))
+
+				r.Put("/reactions/{kind}", app.reactToPostHandler)
+				r.Delete("/reactions/{kind}", app.removeReactionHandler)
+
+				r.Route("/comments", func(r chi.Router) {
+					r.Get("/", app.getCommentsHandler)
+					r.Post("/", app.createCommentHandler)
+
+					r.Route("/{commentID}", func(r chi.Router) {
+						r.Use(app.commentsContextMiddleware)
+						r.Get("/replies", app.getRepliesHandler)
+						r.Patch("/", app.checkCommentOwnership("moderator", app.updateCommentHandler))
+						r.Delete("/", app.checkCommentOwnership("admin", app.deleteCommentHandler))
+					})
+				})
 			})
 		})
 
 		r.Route("/users", func(r chi.Router) {
 			r.Put("/activate/{token}", app.activateUserHandler)
+			r.Post("/activate/resend", app.resendActivationHandler)
 
 			r.Route("/{userID}", func(r chi.Router) {
 				r.Use(app.TokenAuthMiddleware)
@@ -125,17 +215,73 @@ func (app *application) mount() http.Handler {
 
 				r.Put("/follow", app.followUserHandler)
 				r.Put("/unfollow", app.unfollowUserHandler)
+
+				r.Get("/followers", app.getFollowersHandler)
+				r.Get("/following", app.getFollowingHandler)
+
+				r.Put("/block", app.blockUserHandler)
+				r.Delete("/block", app.unblockUserHandler)
+				r.Put("/mute", app.muteUserHandler)
+				r.Delete("/mute", app.unmuteUserHandler)
 			})
 
 			r.Group(func(r chi.Router) {
 				r.Use(app.TokenAuthMiddleware)
 				r.Get("/feed", app.getUserFeedHandler)
+				r.Patch("/me/settings", app.updateSettingsHandler)
+
+				r.Get("/follow-requests", app.getFollowRequestsHandler)
+				r.Put("/follow-requests/{requesterID}", app.approveFollowRequestHandler)
+				r.Delete("/follow-requests/{requesterID}", app.rejectFollowRequestHandler)
+			})
+		})
+
+		r.With(app.TokenAuthMiddleware).Get("/stream", app.streamHandler)
+
+		r.Route("/notifications", func(r chi.Router) {
+			r.Use(app.TokenAuthMiddleware)
+			r.Get("/", app.getNotificationsHandler)
+			r.Post("/read", app.markNotificationsReadHandler)
+		})
+
+		r.Route("/tags", func(r chi.Router) {
+			r.With(app.TokenAuthMiddleware).Get("/trending", app.getTrendingTagsHandler)
+			// tag timelines are public: anonymous viewers only see public posts
+			r.With(app.OptionalTokenAuthMiddleware).Get("/{tag}/posts", app.getTagPostsHandler)
+		})
+
+		r.Route("/search", func(r chi.Router) {
+			r.Use(app.TokenAuthMiddleware)
+			r.Get("/posts", app.searchPostsHandler)
+			r.Get("/users", app.searchUsersHandler)
+		})
+
+		r.Route("/admin", func(r chi.Router) {
+			r.Use(app.TokenAuthMiddleware)
+			r.Use(app.requireRole("admin"))
+
+			r.Route("/webhooks", func(r chi.Router) {
+				r.Post("/", app.createWebhookHandler)
+				r.Get("/", app.getWebhooksHandler)
+
+				r.Route("/{webhookID}", func(r chi.Router) {
+					r.Use(app.webhooksContextMiddleware)
+					r.Get("/", app.getWebhookHandler)
+					r.Patch("/", app.updateWebhookHandler)
+					r.Delete("/", app.deleteWebhookHandler)
+					r.Get("/deliveries", app.getWebhookDeliveriesHandler)
+					r.Post("/deliveries/{deliveryID}/redeliver", app.redeliverWebhookHandler)
+				})
 			})
 		})
 
 		r.Route("/authentication", func(r chi.Router) {
 			r.Post("/user", app.registerUserHandler)
 			r.Post("/token", app.createTokenHandler)
+			r.Post("/refresh", app.refreshTokenHandler)
+			r.Post("/logout", app.logoutHandler)
+			r.Post("/password/forgot", app.forgotPasswordHandler)
+			r.Post("/password/reset", app.resetPasswordHandler)
 		})
 	})
 
@@ -155,8 +301,21 @@ func (app *application) run(mux http.Handler) error {
 		WriteTimeout: time.Second * 30,
 	}
 
+	// open streams never become idle, closing the broker ends them so the
+	// shutdown does not wait for them
+	srv.RegisterOnShutdown(func() {
+		if err := app.broker.Close(); err != nil {
+			app.logger.Errorw("error closing the event broker", "error", err)
+		}
+	})
+
 	shutdown := make(chan error)
 
+	jobsCtx, stopJobs := context.WithCancel(context.Background())
+	defer stopJobs()
+
+	app.startBackgroundJobs(jobsCtx)
+
 	go func() {
 		quit := make(chan os.Signal, 1)
 		signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
@@ -169,7 +328,11 @@ func (app *application) run(mux http.Handler) error {
 
 		app.logger.Infow("signal cought", "signal", s.String())
 
-		shutdown <- srv.Shutdown(ctx)
+		stopJobs()
+		err := srv.Shutdown(ctx)
+		app.jobs.Close()
+
+		shutdown <- err
 	}()
 
 	app.logger.Infow("server has started", "addr", app.config.addr, "env", app.config.env)
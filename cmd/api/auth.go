@@ -1,14 +1,12 @@
 package main
 
 import (
-	"crypto/sha256"
-	"encoding/hex"
+	"context"
 	"errors"
-	"fmt"
 	"net/http"
 	"time"
 
-	"github.com/AlieNoori/social/internal/mailer"
+	"github.com/AlieNoori/social/internal/auth"
 	"github.com/AlieNoori/social/internal/store"
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
@@ -18,6 +16,7 @@ type RegisterUserPayload struct {
 	Username string `json:"username" validate:"required,max=100"`
 	Email    string `json:"email" validate:"required,email,max=225"`
 	Password string `json:"password" validate:"required,min=3,max=72"`
+	Language string `json:"language" validate:"omitempty,bcp47_language_tag,max=35"`
 }
 
 type UserWithToken struct {
@@ -54,6 +53,7 @@ func (app *application) registerUserHandler(w http.ResponseWriter, r *http.Reque
 	user := &store.User{
 		UserName: payload.Username,
 		Email:    payload.Email,
+		Language: payload.Language,
 		Role: store.Role{
 			Name: "user",
 		},
@@ -64,10 +64,10 @@ func (app *application) registerUserHandler(w http.ResponseWriter, r *http.Reque
 	}
 
 	plainToken := uuid.New().String()
-	hash := sha256.Sum256([]byte(plainToken))
-	hashToken := hex.EncodeToString(hash[:])
 
-	if err := app.store.Users.CreateAndInvite(ctx, user, hashToken, app.config.mail.exp); err != nil {
+	// the welcome email is queued along with the user and sent in the
+	// background
+	if err := app.store.Users.CreateAndInvite(ctx, user, plainToken, app.config.mail.exp, app.invitationEmail(plainToken)); err != nil {
 		switch {
 		case errors.Is(err, store.ErrDuplicateEmail):
 			app.badRequestResponse(w, r, err)
@@ -84,36 +84,6 @@ func (app *application) registerUserHandler(w http.ResponseWriter, r *http.Reque
 		Token: plainToken,
 	}
 
-	isProdEnv := app.config.env == "production"
-	activationURL := fmt.Sprintf("%s/confirm/%s", app.config.frontendURL, plainToken)
-	emailData := struct {
-		ActivationURL string
-		Username      string
-	}{
-		ActivationURL: activationURL,
-		Username:      user.UserName,
-	}
-
-	// send email
-	status, err := app.mailer.Send(
-		mailer.UserWelcomeTemplate,
-		user.UserName,
-		user.Email,
-		emailData,
-		!isProdEnv)
-	if err != nil {
-		app.logger.Errorw("error sending welcome email", "error", err)
-
-		if err := app.store.Users.Delete(ctx, user.ID); err != nil {
-			app.logger.Errorw("errer deleting user", "error", err)
-		}
-
-		app.internalServerError(w, r, err)
-		return
-	}
-
-	app.logger.Infow("email sent", "status code", status)
-
 	if err := app.writeResponse(w, http.StatusCreated, userWithToken); err != nil {
 		app.internalServerError(w, r, err)
 	}
@@ -132,9 +102,10 @@ type CreateUserTokenPayload struct {
 //	@Accept			json
 //	@Produce		json
 //	@Param			payload	body	 CreateUserTokenPayload true	"User credentials"
-//	@Success		200		{string}	string					"Token"
+//	@Success		201		{object}	AuthTokens				"Tokens"
 //	@Failure		400		{object}	error
 //	@Failure		401		{object}	error
+//	@Failure		423		{object}	error
 //	@Failure		500		{object}	error
 //	@Router			/authentication/token [post]
 func (app *application) createTokenHandler(w http.ResponseWriter, r *http.Request) {
@@ -149,32 +120,213 @@ func (app *application) createTokenHandler(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
-	user, err := app.store.Users.GetByEmail(r.Context(), payload.Email)
+	user, err := app.store.Users.Authenticate(
+		r.Context(),
+		payload.Email,
+		payload.Password,
+		app.config.auth.login.maxAttempts,
+		app.config.auth.login.lockout,
+	)
 	if err != nil {
 		switch err {
-		case store.ErrNotFound:
+		case store.ErrNotFound, store.ErrInvalidCredentials:
 			app.unauthorizedErrorResponse(w, r, err)
+		case store.ErrAccountLocked:
+			app.lockedResponse(w, r, err)
 		default:
 			app.internalServerError(w, r, err)
 		}
 		return
 	}
 
+	tokens, err := app.createSession(r.Context(), user)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.writeResponse(w, http.StatusCreated, tokens); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+type RefreshTokenPayload struct {
+	RefreshToken string `json:"refresh_token" validate:"required,max=100"`
+}
+
+type AuthTokens struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+}
+
+// refreshTokenHandler godoc
+//
+//	@Summary		Refreshes a token
+//	@Description	Exchanges a refresh token for a new access and refresh token
+//	@Tags			authentication
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		RefreshTokenPayload	true	"Refresh token"
+//	@Success		201		{object}	AuthTokens			"Tokens"
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		500		{object}	error
+//	@Router			/authentication/refresh [post]
+func (app *application) refreshTokenHandler(w http.ResponseWriter, r *http.Request) {
+	var payload RefreshTokenPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	refreshToken := uuid.New().String()
+
+	session, err := app.store.Sessions.Rotate(r.Context(), payload.RefreshToken, refreshToken, app.config.auth.token.refreshExp)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.unauthorizedErrorResponse(w, r, err)
+		case errors.Is(err, store.ErrTokenReused):
+			app.logger.Warnw("refresh token reuse detected, session revoked", "method", r.Method, "path", r.URL.Path)
+			app.unauthorizedErrorResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	accessToken, err := app.generateAccessToken(session.UserId, session.ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	tokens := AuthTokens{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+	}
+
+	if err := app.writeResponse(w, http.StatusCreated, tokens); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// logoutHandler godoc
+//
+//	@Summary		Logs out
+//	@Description	Revokes the session the refresh token belongs to
+//	@Tags			authentication
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		RefreshTokenPayload	true	"Refresh token"
+//	@Success		204		{string}	string				"Session revoked"
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		500		{object}	error
+//	@Router			/authentication/logout [post]
+func (app *application) logoutHandler(w http.ResponseWriter, r *http.Request) {
+	var payload RefreshTokenPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := app.store.Sessions.RevokeByToken(r.Context(), payload.RefreshToken); err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.unauthorizedErrorResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (app *application) createSession(ctx context.Context, user *store.User) (*AuthTokens, error) {
+	session := &store.Session{
+		ID:     uuid.New().String(),
+		UserId: user.ID,
+	}
+	refreshToken := uuid.New().String()
+
+	if err := app.store.Sessions.Create(ctx, session, refreshToken, app.config.auth.token.refreshExp); err != nil {
+		return nil, err
+	}
+
+	accessToken, err := app.generateAccessToken(user.ID, session.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &AuthTokens{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+	}, nil
+}
+
+func (app *application) generateAccessToken(userId int, sessionId string) (string, error) {
 	claims := jwt.MapClaims{
-		"sub": user.ID,
+		"sub": userId,
+		"sid": sessionId,
 		"exp": time.Now().Add(app.config.auth.token.exp).Unix(),
 		"iat": time.Now().Unix(),
 		"nbf": time.Now().Unix(),
 		"iss": app.config.auth.token.iss,
 		"aud": app.config.auth.token.iss,
 	}
-	token, err := app.authenticator.GenerateToken(claims)
+
+	return app.authenticator.GenerateToken(claims)
+}
+
+// reloadSigningKeys reads the token signing keys from disk again, so keys can
+// be rotated without a restart. On error the current keys stay in use.
+func (app *application) reloadSigningKeys(ctx context.Context) error {
+	reloader, ok := app.authenticator.(auth.Reloader)
+	if !ok {
+		return nil
+	}
+
+	return reloader.Reload()
+}
+
+// jwksHandler godoc
+//
+//	@Summary		Publishes the token signing keys
+//	@Description	Returns the public keys tokens can be verified with, in JWKS format
+//	@Tags			authentication
+//	@Produce		json
+//	@Success		200	{object}	auth.JWKSet
+//	@Failure		404	{object}	error
+//	@Failure		500	{object}	error
+//	@Router			/.well-known/jwks.json [get]
+func (app *application) jwksHandler(w http.ResponseWriter, r *http.Request) {
+	provider, ok := app.authenticator.(auth.JWKSProvider)
+	if !ok {
+		app.notFoundResponse(w, r, errors.New("authenticator does not publish signing keys"))
+		return
+	}
+
+	set, err := provider.JWKS()
 	if err != nil {
 		app.internalServerError(w, r, err)
 		return
 	}
 
-	if err := app.writeResponse(w, http.StatusCreated, token); err != nil {
+	w.Header().Set("Cache-Control", "public, max-age=300")
+
+	if err := writeJSON(w, http.StatusOK, set); err != nil {
 		app.internalServerError(w, r, err)
 	}
 }
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/AlieNoori/social/internal/auth"
+	"github.com/AlieNoori/social/internal/store"
+)
+
+// memorySessionStore keeps sessions in memory and follows the rotation rules
+// of the postgres store: a rotated token can not be used again and presenting
+// it anyway revokes the session.
+type memorySessionStore struct {
+	store.MockSessionStore
+	mu      sync.Mutex
+	tokens  map[string]string
+	used    map[string]bool
+	revoked map[string]bool
+	users   map[string]int
+}
+
+func newMemorySessionStore() *memorySessionStore {
+	return &memorySessionStore{
+		tokens:  make(map[string]string),
+		used:    make(map[string]bool),
+		revoked: make(map[string]bool),
+		users:   make(map[string]int),
+	}
+}
+
+func (s *memorySessionStore) Create(_ context.Context, session *store.Session, token string, _ time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.tokens[token] = session.ID
+	s.users[session.ID] = session.UserId
+
+	return nil
+}
+
+func (s *memorySessionStore) Rotate(_ context.Context, oldToken, newToken string, _ time.Duration) (*store.Session, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sessionId, ok := s.tokens[oldToken]
+	if !ok || s.revoked[sessionId] {
+		return nil, store.ErrNotFound
+	}
+
+	if s.used[oldToken] {
+		s.revoked[sessionId] = true
+		return nil, store.ErrTokenReused
+	}
+
+	s.used[oldToken] = true
+	s.tokens[newToken] = sessionId
+
+	return &store.Session{ID: sessionId, UserId: s.users[sessionId]}, nil
+}
+
+func (s *memorySessionStore) RevokeByToken(_ context.Context, token string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sessionId, ok := s.tokens[token]
+	if !ok {
+		return store.ErrNotFound
+	}
+	s.revoked[sessionId] = true
+
+	return nil
+}
+
+func (s *memorySessionStore) IsActive(_ context.Context, sessionId string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, ok := s.users[sessionId]
+	return ok && !s.revoked[sessionId], nil
+}
+
+func TestSessionHandlers(t *testing.T) {
+	cfg := config{auth: authConfig{token: tokenConfig{exp: time.Minute, refreshExp: time.Hour, iss: "gophersocial"}}}
+	app := NewTestApplication(t, cfg)
+	app.authenticator = auth.NewJWTAuthenticator("secret", "gophersocial", "gophersocial")
+	sessions := newMemorySessionStore()
+	app.store.Sessions = sessions
+	mux := app.mount()
+
+	login := func(t *testing.T) *AuthTokens {
+		t.Helper()
+
+		tokens, err := app.createSession(context.Background(), &store.User{ID: 205})
+		if err != nil {
+			t.Fatalf("error: %s\n", err.Error())
+		}
+
+		return tokens
+	}
+
+	post := func(t *testing.T, path, refreshToken string) (int, AuthTokens) {
+		t.Helper()
+
+		body, err := json.Marshal(RefreshTokenPayload{RefreshToken: refreshToken})
+		if err != nil {
+			t.Fatalf("error: %s\n", err.Error())
+		}
+
+		req, err := http.NewRequest(http.MethodPost, "http://localhost:8080/v1/authentication/"+path, bytes.NewReader(body))
+		if err != nil {
+			t.Fatalf("error: %s\n", err.Error())
+		}
+
+		rr := executeRequest(req, mux)
+
+		var res struct {
+			Data AuthTokens `json:"data"`
+		}
+		if rr.Code == http.StatusCreated {
+			if err := json.NewDecoder(rr.Body).Decode(&res); err != nil {
+				t.Fatalf("error: %s\n", err.Error())
+			}
+		}
+
+		return rr.Code, res.Data
+	}
+
+	getUser := func(t *testing.T, accessToken string) int {
+		t.Helper()
+
+		req, err := http.NewRequest(http.MethodGet, "http://localhost:8080/v1/users/190", nil)
+		if err != nil {
+			t.Fatalf("error: %s\n", err.Error())
+		}
+
+		req.Header.Set("Autorization", "Bearer "+accessToken)
+
+		return executeRequest(req, mux).Code
+	}
+
+	t.Run("should rotate the refresh token", func(t *testing.T) {
+		tokens := login(t)
+
+		code, rotated := post(t, "refresh", tokens.RefreshToken)
+		checkResponse(t, http.StatusCreated, code)
+
+		if rotated.RefreshToken == "" || rotated.RefreshToken == tokens.RefreshToken {
+			t.Fatalf("expected a new refresh token and got %q", rotated.RefreshToken)
+		}
+
+		checkResponse(t, http.StatusOK, getUser(t, rotated.AccessToken))
+
+		code, _ = post(t, "refresh", rotated.RefreshToken)
+		checkResponse(t, http.StatusCreated, code)
+	})
+
+	t.Run("should revoke the session when a refresh token is reused", func(t *testing.T) {
+		tokens := login(t)
+
+		code, rotated := post(t, "refresh", tokens.RefreshToken)
+		checkResponse(t, http.StatusCreated, code)
+
+		code, _ = post(t, "refresh", tokens.RefreshToken)
+		checkResponse(t, http.StatusUnauthorized, code)
+
+		// the token issued by the rotation belongs to the revoked session too
+		code, _ = post(t, "refresh", rotated.RefreshToken)
+		checkResponse(t, http.StatusUnauthorized, code)
+
+		checkResponse(t, http.StatusUnauthorized, getUser(t, rotated.AccessToken))
+	})
+
+	t.Run("should revoke the session on logout", func(t *testing.T) {
+		tokens := login(t)
+
+		code, _ := post(t, "logout", tokens.RefreshToken)
+		checkResponse(t, http.StatusNoContent, code)
+
+		checkResponse(t, http.StatusUnauthorized, getUser(t, tokens.AccessToken))
+
+		code, _ = post(t, "refresh", tokens.RefreshToken)
+		checkResponse(t, http.StatusUnauthorized, code)
+	})
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/AlieNoori/social/internal/store"
+	"github.com/go-chi/chi/v5"
+)
+
+type relationFunc func(context.Context, int, int) error
+
+// BlockUser godoc
+//
+//	@Summary		Blocks a user
+//	@Description	Blocks a user by ID, removing the follows between both users and hiding your posts and comments from them
+//	@Tags			users
+//	@Produce		json
+//	@Param			userID	path		int		true	"User ID"
+//	@Success		204		{string}	string	"User blocked"
+//	@Failure		400		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/{userID}/block [put]
+func (app *application) blockUserHandler(w http.ResponseWriter, r *http.Request) {
+	app.updateRelation(w, r, app.store.Blocks.Block, func(ctx context.Context, me, other int) {
+		// the follows between both users are gone, their timelines are
+		// rebuilt without them
+		app.invalidateTimeline(ctx, me)
+		app.invalidateTimeline(ctx, other)
+	})
+}
+
+// UnblockUser godoc
+//
+//	@Summary		Unblocks a user
+//	@Description	Unblocks a user by ID, follows removed by the block are not restored
+//	@Tags			users
+//	@Produce		json
+//	@Param			userID	path		int		true	"User ID"
+//	@Success		204		{string}	string	"User unblocked"
+//	@Failure		400		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/{userID}/block [delete]
+func (app *application) unblockUserHandler(w http.ResponseWriter, r *http.Request) {
+	app.updateRelation(w, r, app.store.Blocks.Unblock, nil)
+}
+
+// MuteUser godoc
+//
+//	@Summary		Mutes a user
+//	@Description	Mutes a user by ID, hiding their posts from your feed without notifying them
+//	@Tags			users
+//	@Produce		json
+//	@Param			userID	path		int		true	"User ID"
+//	@Success		204		{string}	string	"User muted"
+//	@Failure		400		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/{userID}/mute [put]
+func (app *application) muteUserHandler(w http.ResponseWriter, r *http.Request) {
+	app.updateRelation(w, r, app.store.Blocks.Mute, nil)
+}
+
+// UnmuteUser godoc
+//
+//	@Summary		Unmutes a user
+//	@Description	Unmutes a user by ID
+//	@Tags			users
+//	@Produce		json
+//	@Param			userID	path		int		true	"User ID"
+//	@Success		204		{string}	string	"User unmuted"
+//	@Failure		400		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/{userID}/mute [delete]
+func (app *application) unmuteUserHandler(w http.ResponseWriter, r *http.Request) {
+	app.updateRelation(w, r, app.store.Blocks.Unmute, func(ctx context.Context, me, _ int) {
+		// the muted posts were left out when the timeline was built
+		app.invalidateTimeline(ctx, me)
+	})
+}
+
+// updateRelation applies update between the authenticated user and the user
+// in the path, then calls after when it is set.
+func (app *application) updateRelation(w http.ResponseWriter, r *http.Request, update relationFunc, after func(context.Context, int, int)) {
+	me := getUserFromCtx(r)
+	otherId, err := strconv.Atoi(chi.URLParam(r, "userID"))
+	if err != nil || otherId < 1 {
+		app.badRequestResponse(w, r, errors.New("invalid user id"))
+		return
+	}
+
+	if otherId == me.ID {
+		app.badRequestResponse(w, r, errors.New("cannot block or mute yourself"))
+		return
+	}
+
+	ctx := r.Context()
+
+	if _, err := app.getUser(ctx, otherId); err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if err := update(ctx, me.ID, otherId); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if after != nil {
+		after(ctx, me.ID, otherId)
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,248 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/AlieNoori/social/internal/store"
+)
+
+// memoryCommentStore keeps comments in memory and refuses edits based on a
+// stale version like the postgres store.
+type memoryCommentStore struct {
+	comments map[int]*store.Comment
+	created  *store.Comment
+}
+
+func (s *memoryCommentStore) Create(_ context.Context, comment *store.Comment) error {
+	comment.ID = len(s.comments) + 1
+	s.comments[comment.ID] = comment
+	s.created = comment
+
+	return nil
+}
+
+func (s *memoryCommentStore) GetById(_ context.Context, id int) (*store.Comment, error) {
+	comment, ok := s.comments[id]
+	if !ok {
+		return nil, store.ErrNotFound
+	}
+	c := *comment
+
+	return &c, nil
+}
+
+func (s *memoryCommentStore) GetByPostId(context.Context, int, int, store.PaginatedQuery) ([]store.Comment, error) {
+	return []store.Comment{}, nil
+}
+
+func (s *memoryCommentStore) GetReplies(context.Context, int, int, int, int) ([]store.Comment, error) {
+	return []store.Comment{}, nil
+}
+
+func (s *memoryCommentStore) Update(_ context.Context, comment *store.Comment) error {
+	stored, ok := s.comments[comment.ID]
+	if !ok || stored.Deleted || stored.Version != comment.Version {
+		return store.ErrNotFound
+	}
+
+	comment.Version++
+	c := *comment
+	s.comments[comment.ID] = &c
+
+	return nil
+}
+
+func (s *memoryCommentStore) Delete(_ context.Context, id int) error {
+	if _, ok := s.comments[id]; !ok {
+		return store.ErrNotFound
+	}
+	delete(s.comments, id)
+
+	return nil
+}
+
+// roleUserStore returns every user with all of its private fields set and the
+// given role.
+type roleUserStore struct {
+	store.MockUserStore
+	role store.Role
+}
+
+func (s *roleUserStore) GetById(_ context.Context, id int) (*store.User, error) {
+	return &store.User{
+		ID:       id,
+		UserName: "gopher",
+		Email:    "gopher@example.com",
+		IsActive: true,
+		Role:     s.role,
+		Language: "en",
+	}, nil
+}
+
+func TestCommentHandlers(t *testing.T) {
+	ctx := context.Background()
+	app := NewTestApplication(t, config{})
+	comments := &memoryCommentStore{comments: make(map[int]*store.Comment)}
+	users := &roleUserStore{}
+	app.store.Comments = comments
+	app.store.Users = users
+	mux := app.mount()
+
+	testToken, err := app.authenticator.GenerateToken(nil)
+	if err != nil {
+		t.Fatalf("error: %s\n", err.Error())
+	}
+
+	moderator, err := app.store.Roles.GetByName(ctx, "moderator")
+	if err != nil {
+		t.Fatalf("error: %s\n", err.Error())
+	}
+
+	request := func(t *testing.T, method, url string, payload any) int {
+		t.Helper()
+
+		var body bytes.Buffer
+		if payload != nil {
+			if err := json.NewEncoder(&body).Encode(payload); err != nil {
+				t.Fatalf("error: %s\n", err.Error())
+			}
+		}
+
+		req, err := http.NewRequest(method, "http://localhost:8080"+url, &body)
+		if err != nil {
+			t.Fatalf("error: %s\n", err.Error())
+		}
+		req.Header.Set("Autorization", "Bearer "+testToken)
+
+		return executeRequest(req, mux).Code
+	}
+
+	// reset stores a comment of the test user and one of another user
+	reset := func() {
+		users.role = store.Role{}
+		comments.comments = map[int]*store.Comment{
+			1: {ID: 1, PostId: 1, UserId: 205, Content: "mine"},
+			2: {ID: 2, PostId: 1, UserId: 7, Content: "theirs"},
+		}
+	}
+
+	edit := func(version int) UpdateCommentPayload {
+		return UpdateCommentPayload{Content: "edited", Version: &version}
+	}
+
+	t.Run("should only expose the public fields of the commenter", func(t *testing.T) {
+		reset()
+
+		code := request(t, http.MethodPost, "/v1/posts/1/comments", CreateCommentPayload{Content: "hello"})
+
+		checkResponse(t, http.StatusCreated, code)
+		if comments.created == nil {
+			t.Fatal("expected the comment to be created")
+		}
+
+		if commenter := comments.created.User; commenter.ID != 205 || commenter.UserName != "gopher" || commenter.Email != "" || commenter.Role != (store.Role{}) {
+			t.Errorf("expected only the id and username of the commenter and got %+v", commenter)
+		}
+
+		event, err := json.Marshal(comments.created)
+		if err != nil {
+			t.Fatalf("error: %s\n", err.Error())
+		}
+		if strings.Contains(string(event), "gopher@example.com") {
+			t.Errorf("expected the comment event to leave out the commenter email, got %s", event)
+		}
+	})
+
+	t.Run("should reject a reply to a comment of another post", func(t *testing.T) {
+		reset()
+		comments.comments[3] = &store.Comment{ID: 3, PostId: 2, UserId: 7}
+		parentId := 3
+
+		code := request(t, http.MethodPost, "/v1/posts/1/comments", CreateCommentPayload{Content: "hello", ParentId: &parentId})
+
+		checkResponse(t, http.StatusBadRequest, code)
+	})
+
+	t.Run("should let the owner edit and delete a comment", func(t *testing.T) {
+		reset()
+
+		checkResponse(t, http.StatusOK, request(t, http.MethodPatch, "/v1/posts/1/comments/1", edit(0)))
+		checkResponse(t, http.StatusNoContent, request(t, http.MethodDelete, "/v1/posts/1/comments/1", nil))
+	})
+
+	t.Run("should forbid other users", func(t *testing.T) {
+		reset()
+
+		checkResponse(t, http.StatusForbidden, request(t, http.MethodPatch, "/v1/posts/1/comments/2", edit(0)))
+		checkResponse(t, http.StatusForbidden, request(t, http.MethodDelete, "/v1/posts/1/comments/2", nil))
+	})
+
+	t.Run("should let moderators edit but not delete comments of others", func(t *testing.T) {
+		reset()
+		users.role = *moderator
+
+		checkResponse(t, http.StatusOK, request(t, http.MethodPatch, "/v1/posts/1/comments/2", edit(0)))
+		checkResponse(t, http.StatusForbidden, request(t, http.MethodDelete, "/v1/posts/1/comments/2", nil))
+	})
+
+	t.Run("should not find a comment through another post", func(t *testing.T) {
+		reset()
+
+		checkResponse(t, http.StatusNotFound, request(t, http.MethodPatch, "/v1/posts/2/comments/1", edit(0)))
+	})
+
+	t.Run("should refuse edits based on a stale version", func(t *testing.T) {
+		reset()
+
+		checkResponse(t, http.StatusOK, request(t, http.MethodPatch, "/v1/posts/1/comments/1", edit(0)))
+		checkResponse(t, http.StatusConflict, request(t, http.MethodPatch, "/v1/posts/1/comments/1", edit(0)))
+		checkResponse(t, http.StatusOK, request(t, http.MethodPatch, "/v1/posts/1/comments/1", edit(1)))
+	})
+
+	t.Run("should require the version of the edited comment", func(t *testing.T) {
+		reset()
+
+		code := request(t, http.MethodPatch, "/v1/posts/1/comments/1", map[string]string{"content": "edited"})
+
+		checkResponse(t, http.StatusBadRequest, code)
+	})
+}
+
+func TestGetRepliesHandler(t *testing.T) {
+	app := NewTestApplication(t, config{})
+	app.store.Comments = &memoryCommentStore{comments: map[int]*store.Comment{
+		1: {ID: 1, PostId: 1, UserId: 7},
+	}}
+	mux := app.mount()
+
+	testToken, err := app.authenticator.GenerateToken(nil)
+	if err != nil {
+		t.Fatalf("error: %s\n", err.Error())
+	}
+
+	for url, want := range map[string]int{
+		"/v1/posts/1/comments/1/replies":                 http.StatusOK,
+		"/v1/posts/1/comments/1/replies?after=3&limit=5": http.StatusOK,
+		"/v1/posts/1/comments/1/replies?after=-1":        http.StatusBadRequest,
+		"/v1/posts/1/comments/1/replies?limit=51":        http.StatusBadRequest,
+		"/v1/posts/1/comments/2/replies":                 http.StatusNotFound,
+		"/v1/posts/2/comments/1/replies":                 http.StatusNotFound,
+	} {
+		t.Run(url, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodGet, "http://localhost:8080"+url, nil)
+			if err != nil {
+				t.Fatalf("error: %s\n", err.Error())
+			}
+			req.Header.Set("Autorization", "Bearer "+testToken)
+
+			rr := executeRequest(req, mux)
+
+			checkResponse(t, want, rr.Code)
+		})
+	}
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/AlieNoori/social/internal/backoff"
+	"github.com/AlieNoori/social/internal/mailer"
+	"github.com/AlieNoori/social/internal/store"
+)
+
+// emailLease bounds the time a worker has to send an email before it is
+// claimed again.
+const emailLease = 2 * time.Minute
+
+// emailRetry spaces the attempts of an email from 30 seconds up to an hour
+// before it is moved to the dead state.
+var emailRetry = backoff.Policy{Base: 30 * time.Second, Max: time.Hour, MaxAttempts: 8}
+
+// newMailer builds the mailer backend selected by the configuration.
+func newMailer(cfg mailConfig) (mailer.Client, error) {
+	switch cfg.backend {
+	case "sendgrid":
+		return mailer.NewSendGrid(cfg.sendGrid.apiKey, cfg.fromEmail), nil
+	case "smtp":
+		return mailer.NewSMTP(cfg.smtp.host, cfg.smtp.port, cfg.smtp.username, cfg.smtp.password, cfg.fromEmail), nil
+	case "file":
+		return mailer.NewFileMailer(cfg.fileDir, cfg.fromEmail)
+	case "log":
+		return mailer.NewLogMailer(os.Stdout, cfg.fromEmail), nil
+	default:
+		return nil, fmt.Errorf("unknown mailer backend %q", cfg.backend)
+	}
+}
+
+// invitationEmail builds the activation email for a plain invitation token,
+// the store queues it along with the invitation so the token is never
+// written anywhere else.
+func (app *application) invitationEmail(token string) store.EmailFunc {
+	return func(user *store.User) (*store.QueuedEmail, error) {
+		data := struct {
+			ActivationURL string
+			Username      string
+		}{
+			ActivationURL: fmt.Sprintf("%s/confirm/%s", app.config.frontendURL, token),
+			Username:      user.UserName,
+		}
+
+		return newQueuedEmail(mailer.UserWelcomeTemplate, user, data)
+	}
+}
+
+// passwordResetEmail builds the email linking to a plain password reset
+// token.
+func (app *application) passwordResetEmail(token string) store.EmailFunc {
+	return func(user *store.User) (*store.QueuedEmail, error) {
+		data := struct {
+			ResetURL  string
+			Username  string
+			ExpiresIn string
+		}{
+			ResetURL:  fmt.Sprintf("%s/reset-password/%s", app.config.frontendURL, token),
+			Username:  user.UserName,
+			ExpiresIn: app.config.mail.resetExp.String(),
+		}
+
+		return newQueuedEmail(mailer.PasswordResetTemplate, user, data)
+	}
+}
+
+// newQueuedEmail addresses an email to the user, it is rendered in the
+// language of the user.
+func newQueuedEmail(template string, user *store.User, data any) (*store.QueuedEmail, error) {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+
+	return &store.QueuedEmail{
+		Template: template,
+		Locale:   user.Language,
+		UserName: user.UserName,
+		Email:    user.Email,
+		Data:     payload,
+	}, nil
+}
+
+// sendQueuedEmails claims a batch of due emails and sends them through a pool
+// of workers. Failed emails are retried with an exponential backoff until
+// they run out of attempts.
+func (app *application) sendQueuedEmails(ctx context.Context) error {
+	emails, err := app.store.Emails.ClaimDue(ctx, app.config.mail.batchSize, emailLease)
+	if err != nil {
+		return err
+	}
+
+	queue := make(chan store.QueuedEmail)
+
+	var wg sync.WaitGroup
+	for range max(app.config.mail.workers, 1) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for email := range queue {
+				if err := app.sendQueuedEmail(ctx, email); err != nil {
+					app.logger.Errorw("error settling queued email", "email", email.ID, "error", err)
+				}
+			}
+		}()
+	}
+
+	for _, email := range emails {
+		queue <- email
+	}
+	close(queue)
+	wg.Wait()
+
+	return nil
+}
+
+func (app *application) sendQueuedEmail(ctx context.Context, email store.QueuedEmail) error {
+	var data map[string]any
+	if err := json.Unmarshal(email.Data, &data); err != nil {
+		return err
+	}
+
+	isProdEnv := app.config.env == "production"
+
+	status, err := app.mailer.Send(email.Template, email.Locale, email.UserName, email.Email, data, !isProdEnv)
+	if err == nil {
+		app.logger.Infow("email sent", "template", email.Template, "status code", status)
+		return app.store.Emails.MarkSent(ctx, email.ID)
+	}
+
+	app.logger.Errorw("error sending email", "email", email.ID, "template", email.Template, "attempts", email.Attempts, "error", err)
+
+	return app.store.Emails.MarkFailed(ctx, email.ID, err.Error(), emailRetry.RetryAt(email.Attempts))
+}
+
+func (app *application) purgeEmails(ctx context.Context) error {
+	deleted, err := app.store.Emails.PurgeSettled(ctx, app.config.mail.retention)
+	if err != nil {
+		return err
+	}
+
+	if deleted > 0 {
+		app.logger.Infow("purged sent emails", "count", deleted)
+	}
+
+	return nil
+}
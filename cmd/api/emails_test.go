@@ -0,0 +1,140 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/AlieNoori/social/internal/store"
+)
+
+// recordingEmailStore hands out the given emails once and records how they
+// were settled.
+type recordingEmailStore struct {
+	store.MockEmailStore
+	mu     sync.Mutex
+	due    []store.QueuedEmail
+	sent   []int
+	failed map[int]*time.Time
+}
+
+func (s *recordingEmailStore) ClaimDue(context.Context, int, time.Duration) ([]store.QueuedEmail, error) {
+	due := s.due
+	s.due = nil
+	return due, nil
+}
+
+func (s *recordingEmailStore) MarkSent(_ context.Context, id int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sent = append(s.sent, id)
+	return nil
+}
+
+func (s *recordingEmailStore) MarkFailed(_ context.Context, id int, _ string, retryAt *time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failed[id] = retryAt
+	return nil
+}
+
+// stubMailer fails for the given recipients and records the data of the
+// emails it sends.
+type stubMailer struct {
+	mu      sync.Mutex
+	failFor string
+	data    map[string]any
+}
+
+func (m *stubMailer) Send(templateFile, locale, username, email string, data any, isSandbox bool) (int, error) {
+	if email == m.failFor {
+		return -1, errors.New("mail provider unavailable")
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.data = data.(map[string]any)
+
+	return 202, nil
+}
+
+func TestSendQueuedEmails(t *testing.T) {
+	app := NewTestApplication(t, config{mail: mailConfig{workers: 2, batchSize: 10}})
+	emails := &recordingEmailStore{failed: make(map[int]*time.Time)}
+	mailer := &stubMailer{failFor: "down@example.com"}
+	app.store.Emails = emails
+	app.mailer = mailer
+
+	emails.due = []store.QueuedEmail{
+		{ID: 1, Template: "t", Email: "gopher@example.com", Data: json.RawMessage(`{"Username":"gopher"}`), Attempts: 1},
+		{ID: 2, Template: "t", Email: "down@example.com", Data: json.RawMessage(`{}`), Attempts: 1},
+		{ID: 3, Template: "t", Email: "down@example.com", Data: json.RawMessage(`{}`), Attempts: emailRetry.MaxAttempts},
+	}
+
+	if err := app.sendQueuedEmails(context.Background()); err != nil {
+		t.Fatalf("error: %s\n", err.Error())
+	}
+
+	t.Run("should mark sent emails and pass their data to the mailer", func(t *testing.T) {
+		if len(emails.sent) != 1 || emails.sent[0] != 1 {
+			t.Errorf("expected email 1 to be sent and got %v", emails.sent)
+		}
+
+		if mailer.data["Username"] != "gopher" {
+			t.Errorf("unexpected template data %v", mailer.data)
+		}
+	})
+
+	t.Run("should retry failed emails until they run out of attempts", func(t *testing.T) {
+		if retryAt, ok := emails.failed[2]; !ok || retryAt == nil {
+			t.Errorf("expected email 2 to be retried")
+		}
+
+		if retryAt, ok := emails.failed[3]; !ok || retryAt != nil {
+			t.Errorf("expected email 3 to be dead")
+		}
+	})
+}
+
+func TestNewMailer(t *testing.T) {
+	for _, backend := range []string{"sendgrid", "smtp", "file", "log"} {
+		if _, err := newMailer(mailConfig{backend: backend, fileDir: t.TempDir()}); err != nil {
+			t.Errorf("backend %q: %s", backend, err.Error())
+		}
+	}
+
+	if _, err := newMailer(mailConfig{backend: "pigeon"}); err == nil {
+		t.Error("expected an unknown backend to be rejected")
+	}
+}
+
+func TestTokenEmails(t *testing.T) {
+	app := NewTestApplication(t, config{frontendURL: "http://localhost:4000", mail: mailConfig{resetExp: time.Hour}})
+	user := &store.User{UserName: "gopher", Email: "gopher@example.com", Language: "de"}
+
+	for name, build := range map[string]store.EmailFunc{
+		"http://localhost:4000/confirm/plain-token":        app.invitationEmail("plain-token"),
+		"http://localhost:4000/reset-password/plain-token": app.passwordResetEmail("plain-token"),
+	} {
+		email, err := build(user)
+		if err != nil {
+			t.Fatalf("error: %s\n", err.Error())
+		}
+
+		var data map[string]any
+		if err := json.Unmarshal(email.Data, &data); err != nil {
+			t.Fatalf("error: %s\n", err.Error())
+		}
+
+		if data["ActivationURL"] != name && data["ResetURL"] != name {
+			t.Errorf("expected the email to link to %s and got %v", name, data)
+		}
+
+		if email.Locale != "de" || email.Email != user.Email || email.UserName != user.UserName {
+			t.Errorf("expected the email to be addressed to the user and got %+v", email)
+		}
+	}
+}
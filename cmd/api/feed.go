@@ -1,7 +1,6 @@
 package main
 
 import (
-	"fmt"
 	"net/http"
 
 	"github.com/AlieNoori/social/internal/store"
@@ -14,10 +13,11 @@ import (
 //	@Tags			feed
 //	@Accept			json
 //	@Produce		json
-//	@Param			since	query		string	false	"Since"
-//	@Param			until	query		string	false	"Until"
+//	@Param			since	query		string	false	"Since, RFC 3339 or YYYY-MM-DD HH:MM:SS"
+//	@Param			until	query		string	false	"Until, RFC 3339 or YYYY-MM-DD HH:MM:SS"
 //	@Param			limit	query		int		false	"Limit"
 //	@Param			offset	query		int		false	"Offset"
+//	@Param			cursor	query		string	false	"Cursor of the next page, takes precedence over offset"
 //	@Param			sort	query		string	false	"Sort"
 //	@Param			tags	query		string	false	"Tags"
 //	@Param			search	query		string	false	"Search"
@@ -38,7 +38,6 @@ func (app *application) getUserFeedHandler(w http.ResponseWriter, r *http.Reques
 		app.badRequestResponse(w, r, err)
 		return
 	}
-	fmt.Println(fq)
 
 	if err := validate.Struct(fq); err != nil {
 		app.badRequestResponse(w, r, err)
@@ -46,14 +45,29 @@ func (app *application) getUserFeedHandler(w http.ResponseWriter, r *http.Reques
 	}
 
 	ctx := r.Context()
+	user := getUserFromCtx(r)
 
-	feed, err := app.store.Posts.GetUserFeed(ctx, 120, fq)
+	if feed, nextCursor, ok := app.getCachedFeed(ctx, user.ID, fq); ok {
+		if err := app.writePaginatedResponse(w, http.StatusOK, feed, nextCursor); err != nil {
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	feed, err := app.store.Posts.GetUserFeed(ctx, user.ID, fq)
 	if err != nil {
 		app.internalServerError(w, r, err)
 		return
 	}
 
-	if err := app.writeResponse(w, http.StatusOK, feed); err != nil {
+	var nextCursor *string
+	if len(feed) == fq.Limit {
+		last := feed[len(feed)-1]
+		cursor := store.FeedCursor{CreatedAt: last.CreatedAt, ID: last.ID}.Encode()
+		nextCursor = &cursor
+	}
+
+	if err := app.writePaginatedResponse(w, http.StatusOK, feed, nextCursor); err != nil {
 		app.internalServerError(w, r, err)
 	}
 }
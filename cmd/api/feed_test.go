@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/AlieNoori/social/internal/store"
+)
+
+type feedRecorder struct {
+	store.MockPostStore
+	userIds []int
+}
+
+func (f *feedRecorder) GetUserFeed(_ context.Context, userId int, _ store.PaginatedFeedQeury) ([]store.PostWithMetadata, error) {
+	f.userIds = append(f.userIds, userId)
+	return []store.PostWithMetadata{}, nil
+}
+
+func TestGetUserFeedHandler(t *testing.T) {
+	app := NewTestApplication(t, config{})
+	recorder := &feedRecorder{}
+	app.store.Posts = recorder
+	mux := app.mount()
+
+	testToken, err := app.authenticator.GenerateToken(nil)
+	if err != nil {
+		t.Fatalf("error: %s\n", err.Error())
+	}
+
+	t.Run("should not allow unathenticated requests", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodGet, "http://localhost:8080/v1/users/feed", nil)
+		if err != nil {
+			t.Fatalf("error: %s\n", err.Error())
+		}
+
+		rr := executeRequest(req, mux)
+
+		checkResponse(t, http.StatusUnauthorized, rr.Code)
+	})
+
+	t.Run("should build the feed of the authenticated user", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodGet, "http://localhost:8080/v1/users/feed", nil)
+		if err != nil {
+			t.Fatalf("error: %s\n", err.Error())
+		}
+
+		req.Header.Set("Autorization", "Bearer "+testToken)
+
+		rr := executeRequest(req, mux)
+
+		checkResponse(t, http.StatusOK, rr.Code)
+
+		// the test authenticator issues tokens for user 205
+		if len(recorder.userIds) != 1 || recorder.userIds[0] != 205 {
+			t.Errorf("expected the feed of user 205 and got %v", recorder.userIds)
+		}
+	})
+
+	t.Run("should reject invalid query parameters", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodGet, "http://localhost:8080/v1/users/feed?since=yesterday", nil)
+		if err != nil {
+			t.Fatalf("error: %s\n", err.Error())
+		}
+
+		req.Header.Set("Autorization", "Bearer "+testToken)
+
+		rr := executeRequest(req, mux)
+
+		checkResponse(t, http.StatusBadRequest, rr.Code)
+	})
+}
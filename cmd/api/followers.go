@@ -0,0 +1,210 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/AlieNoori/social/internal/store"
+	"github.com/go-chi/chi/v5"
+)
+
+type connectionsFunc func(context.Context, int, *store.FeedCursor, int) ([]store.Connection, error)
+
+// GetFollowers godoc
+//
+//	@Summary		Fetches the followers of a user
+//	@Description	Fetches a page of the users following a user, most recent first
+//	@Tags			users
+//	@Accept			json
+//	@Produce		json
+//	@Param			userID	path		int		true	"User ID"
+//	@Param			limit	query		int		false	"Limit"
+//	@Param			cursor	query		string	false	"Cursor of the next page"
+//	@Success		200		{object}	[]store.Connection
+//	@Failure		400		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/{userID}/followers [get]
+func (app *application) getFollowersHandler(w http.ResponseWriter, r *http.Request) {
+	app.writeConnections(w, r, app.store.Followers.GetFollowers)
+}
+
+// GetFollowing godoc
+//
+//	@Summary		Fetches the users a user follows
+//	@Description	Fetches a page of the users followed by a user, most recent first
+//	@Tags			users
+//	@Accept			json
+//	@Produce		json
+//	@Param			userID	path		int		true	"User ID"
+//	@Param			limit	query		int		false	"Limit"
+//	@Param			cursor	query		string	false	"Cursor of the next page"
+//	@Success		200		{object}	[]store.Connection
+//	@Failure		400		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/{userID}/following [get]
+func (app *application) getFollowingHandler(w http.ResponseWriter, r *http.Request) {
+	app.writeConnections(w, r, app.store.Followers.GetFollowing)
+}
+
+func (app *application) writeConnections(w http.ResponseWriter, r *http.Request, list connectionsFunc) {
+	userId, err := strconv.Atoi(chi.URLParam(r, "userID"))
+	if err != nil || userId < 1 {
+		app.badRequestResponse(w, r, errors.New("invalid user id"))
+		return
+	}
+
+	cursor, limit, err := parseConnectionsPage(r)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+
+	if _, err := app.getUser(ctx, userId); err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	connections, err := list(ctx, userId, cursor, limit)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	app.writeConnectionsPage(w, r, connections, limit)
+}
+
+// GetFollowRequests godoc
+//
+//	@Summary		Fetches the pending follow requests
+//	@Description	Fetches a page of the users waiting for the authenticated user to approve their follow request, most recent first
+//	@Tags			users
+//	@Accept			json
+//	@Produce		json
+//	@Param			limit	query		int		false	"Limit"
+//	@Param			cursor	query		string	false	"Cursor of the next page"
+//	@Success		200		{object}	[]store.Connection
+//	@Failure		400		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/follow-requests [get]
+func (app *application) getFollowRequestsHandler(w http.ResponseWriter, r *http.Request) {
+	cursor, limit, err := parseConnectionsPage(r)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	requests, err := app.store.Followers.GetFollowRequests(r.Context(), getUserFromCtx(r).ID, cursor, limit)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	app.writeConnectionsPage(w, r, requests, limit)
+}
+
+// ApproveFollowRequest godoc
+//
+//	@Summary		Approves a follow request
+//	@Description	Approves the pending follow request of a user, who starts following the authenticated user
+//	@Tags			users
+//	@Produce		json
+//	@Param			requesterID	path		int		true	"Requester ID"
+//	@Success		204			{string}	string	"Follow request approved"
+//	@Failure		400			{object}	error
+//	@Failure		404			{object}	error
+//	@Failure		500			{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/follow-requests/{requesterID} [put]
+func (app *application) approveFollowRequestHandler(w http.ResponseWriter, r *http.Request) {
+	app.resolveFollowRequest(w, r, app.store.Followers.ApproveFollowRequest)
+}
+
+// RejectFollowRequest godoc
+//
+//	@Summary		Rejects a follow request
+//	@Description	Rejects the pending follow request of a user
+//	@Tags			users
+//	@Produce		json
+//	@Param			requesterID	path		int		true	"Requester ID"
+//	@Success		204			{string}	string	"Follow request rejected"
+//	@Failure		400			{object}	error
+//	@Failure		404			{object}	error
+//	@Failure		500			{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/follow-requests/{requesterID} [delete]
+func (app *application) rejectFollowRequestHandler(w http.ResponseWriter, r *http.Request) {
+	app.resolveFollowRequest(w, r, app.store.Followers.RejectFollowRequest)
+}
+
+func (app *application) resolveFollowRequest(w http.ResponseWriter, r *http.Request, resolve relationFunc) {
+	requesterId, err := strconv.Atoi(chi.URLParam(r, "requesterID"))
+	if err != nil || requesterId < 1 {
+		app.badRequestResponse(w, r, errors.New("invalid requester id"))
+		return
+	}
+
+	if err := resolve(r.Context(), getUserFromCtx(r).ID, requesterId); err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// parseConnectionsPage reads the limit and cursor query parameters of a
+// followers list.
+func parseConnectionsPage(r *http.Request) (*store.FeedCursor, int, error) {
+	limit := 20
+	var cursor *store.FeedCursor
+	qv := r.URL.Query()
+
+	if v := qv.Get("limit"); v != "" {
+		l, err := strconv.Atoi(v)
+		if err != nil || l < 1 || l > 50 {
+			return nil, 0, errors.New("limit must be between 1 and 50")
+		}
+		limit = l
+	}
+
+	if v := qv.Get("cursor"); v != "" {
+		c, err := store.DecodeFeedCursor(v)
+		if err != nil {
+			return nil, 0, err
+		}
+		cursor = c
+	}
+
+	return cursor, limit, nil
+}
+
+func (app *application) writeConnectionsPage(w http.ResponseWriter, r *http.Request, connections []store.Connection, limit int) {
+	var nextCursor *string
+	if len(connections) == limit {
+		last := connections[len(connections)-1]
+		c := store.FeedCursor{CreatedAt: last.FollowedAt, ID: last.ID}.Encode()
+		nextCursor = &c
+	}
+
+	if err := app.writePaginatedResponse(w, http.StatusOK, connections, nextCursor); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/AlieNoori/social/internal/store"
+)
+
+type privateUserStore struct {
+	store.MockUserStore
+}
+
+func (s *privateUserStore) GetById(_ context.Context, id int) (*store.User, error) {
+	return &store.User{ID: id, IsPrivate: id != 205}, nil
+}
+
+type followRecorder struct {
+	store.MockFollowerStore
+	follows, requests int
+}
+
+func (f *followRecorder) Follow(context.Context, int, int) error {
+	f.follows++
+	return nil
+}
+
+func (f *followRecorder) RequestFollow(context.Context, int, int) error {
+	f.requests++
+	return nil
+}
+
+func TestPrivateAccounts(t *testing.T) {
+	app := NewTestApplication(t, config{})
+	app.store.Users = &privateUserStore{}
+	recorder := &followRecorder{}
+	app.store.Followers = recorder
+	mux := app.mount()
+
+	testToken, err := app.authenticator.GenerateToken(nil)
+	if err != nil {
+		t.Fatalf("error: %s\n", err.Error())
+	}
+
+	t.Run("should request to follow a private user", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodPut, "http://localhost:8080/v1/users/190/follow", nil)
+		if err != nil {
+			t.Fatalf("error: %s\n", err.Error())
+		}
+
+		req.Header.Set("Autorization", "Bearer "+testToken)
+
+		rr := executeRequest(req, mux)
+
+		checkResponse(t, http.StatusAccepted, rr.Code)
+
+		if recorder.requests != 1 || recorder.follows != 0 {
+			t.Errorf("expected a follow request and no follow, got %d requests and %d follows", recorder.requests, recorder.follows)
+		}
+	})
+
+	t.Run("should list the pending follow requests", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodGet, "http://localhost:8080/v1/users/follow-requests", nil)
+		if err != nil {
+			t.Fatalf("error: %s\n", err.Error())
+		}
+
+		req.Header.Set("Autorization", "Bearer "+testToken)
+
+		rr := executeRequest(req, mux)
+
+		checkResponse(t, http.StatusOK, rr.Code)
+	})
+
+	t.Run("should require a valid setting", func(t *testing.T) {
+		for body, want := range map[string]int{
+			`{"is_private": true}`:      http.StatusNoContent,
+			`{"language": "de-AT"}`:     http.StatusNoContent,
+			`{"language": "not a tag"}`: http.StatusBadRequest,
+			`{}`:                        http.StatusBadRequest,
+		} {
+			req, err := http.NewRequest(http.MethodPatch, "http://localhost:8080/v1/users/me/settings", strings.NewReader(body))
+			if err != nil {
+				t.Fatalf("error: %s\n", err.Error())
+			}
+
+			req.Header.Set("Autorization", "Bearer "+testToken)
+
+			rr := executeRequest(req, mux)
+
+			checkResponse(t, want, rr.Code)
+		}
+	})
+}
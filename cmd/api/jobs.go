@@ -0,0 +1,135 @@
+package main
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/AlieNoori/social/internal/auth"
+)
+
+func (app *application) startBackgroundJobs(ctx context.Context) {
+	app.runPeriodically(ctx, "purge inactive users", app.config.cleanup.interval, app.purgeInactiveUsers)
+	app.runPeriodically(ctx, "deliver webhooks", app.config.webhooks.pollInterval, app.deliverWebhooks)
+	app.runPeriodically(ctx, "dispatch outbox", app.config.outbox.pollInterval, app.dispatchOutbox)
+	app.runPeriodically(ctx, "purge outbox", time.Hour, app.purgeOutbox)
+	app.runPeriodically(ctx, "send emails", app.config.mail.pollInterval, app.sendQueuedEmails)
+	app.runPeriodically(ctx, "purge emails", time.Hour, app.purgeEmails)
+
+	if app.config.redisCfg.enabled {
+		app.runPeriodically(ctx, "refresh trending tags", app.config.trending.refreshInterval, app.refreshTrendingTags)
+	}
+
+	if _, ok := app.authenticator.(auth.Reloader); ok {
+		app.runPeriodically(ctx, "reload signing keys", app.config.auth.token.keySetReload, app.reloadSigningKeys)
+		app.runOnSignal(ctx, "reload signing keys", syscall.SIGHUP, app.reloadSigningKeys)
+	}
+}
+
+// jobGroup tracks the goroutines started outside of requests so shutdown can
+// wait for them. Requests still running during shutdown may start jobs too,
+// so once closed the group refuses new ones rather than adding to a
+// WaitGroup that is being waited on.
+type jobGroup struct {
+	mu     sync.Mutex
+	closed bool
+	wg     sync.WaitGroup
+}
+
+// Go runs fn in a new goroutine and reports whether it was started, which it
+// is not after Close.
+func (g *jobGroup) Go(fn func()) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.closed {
+		return false
+	}
+
+	g.wg.Add(1)
+	go func() {
+		defer g.wg.Done()
+		fn()
+	}()
+
+	return true
+}
+
+// Wait waits for the running jobs.
+func (g *jobGroup) Wait() {
+	g.wg.Wait()
+}
+
+// Close refuses new jobs and waits for the running ones.
+func (g *jobGroup) Close() {
+	g.mu.Lock()
+	g.closed = true
+	g.mu.Unlock()
+
+	g.wg.Wait()
+}
+
+// runPeriodically calls fn every interval until ctx is cancelled. Jobs are
+// tracked by app.jobs so shutdown can wait for a running iteration to finish.
+func (app *application) runPeriodically(ctx context.Context, name string, interval time.Duration, fn func(context.Context) error) {
+	if interval <= 0 {
+		return
+	}
+
+	app.jobs.Go(func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := fn(ctx); err != nil {
+					app.logger.Errorw("background job failed", "job", name, "error", err)
+				}
+			}
+		}
+	})
+}
+
+// runOnSignal calls fn every time the process receives sig until ctx is
+// cancelled.
+func (app *application) runOnSignal(ctx context.Context, name string, sig os.Signal, fn func(context.Context) error) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, sig)
+
+	started := app.jobs.Go(func() {
+		defer signal.Stop(signals)
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-signals:
+				if err := fn(ctx); err != nil {
+					app.logger.Errorw("background job failed", "job", name, "error", err)
+				}
+			}
+		}
+	})
+	if !started {
+		signal.Stop(signals)
+	}
+}
+
+func (app *application) purgeInactiveUsers(ctx context.Context) error {
+	deleted, err := app.store.Users.PurgeInactive(ctx, app.config.cleanup.gracePeriod)
+	if err != nil {
+		return err
+	}
+
+	if deleted > 0 {
+		app.logger.Infow("purged inactive users", "count", deleted)
+	}
+
+	return nil
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestJobGroup(t *testing.T) {
+	t.Run("should wait for running jobs on close", func(t *testing.T) {
+		var g jobGroup
+		var done atomic.Bool
+
+		release := make(chan struct{})
+		if !g.Go(func() {
+			<-release
+			done.Store(true)
+		}) {
+			t.Fatal("expected the job to be started")
+		}
+
+		close(release)
+		g.Close()
+
+		if !done.Load() {
+			t.Error("expected close to wait for the running job")
+		}
+	})
+
+	t.Run("should refuse jobs started during and after close", func(t *testing.T) {
+		var g jobGroup
+		var started atomic.Int64
+
+		var wg sync.WaitGroup
+		for range 50 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				g.Go(func() { started.Add(1) })
+			}()
+		}
+
+		g.Close()
+		ran := started.Load()
+		wg.Wait()
+
+		if g.Go(func() { started.Add(1) }) {
+			t.Error("expected a job to be refused after close")
+		}
+
+		if after := started.Load(); after != ran {
+			t.Errorf("expected no job to run after close and got %d", after-ran)
+		}
+	})
+}
@@ -47,3 +47,14 @@ func (app *application) writeResponse(w http.ResponseWriter, status int, data an
 
 	return writeJSON(w, status, &envelope{Data: data})
 }
+
+// writePaginatedResponse writes data with the cursor of the next page, which
+// is null once the last page has been reached.
+func (app *application) writePaginatedResponse(w http.ResponseWriter, status int, data any, nextCursor *string) error {
+	type envelope struct {
+		Data       any     `json:"data"`
+		NextCursor *string `json:"next_cursor"`
+	}
+
+	return writeJSON(w, status, &envelope{Data: data, NextCursor: nextCursor})
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"expvar"
 	"log"
 	"runtime"
@@ -9,10 +10,11 @@ import (
 	"github.com/AlieNoori/social/internal/auth"
 	"github.com/AlieNoori/social/internal/db"
 	"github.com/AlieNoori/social/internal/env"
-	"github.com/AlieNoori/social/internal/mailer"
 	"github.com/AlieNoori/social/internal/ratelimiter"
 	"github.com/AlieNoori/social/internal/store"
 	"github.com/AlieNoori/social/internal/store/cache"
+	"github.com/AlieNoori/social/internal/stream"
+	"github.com/AlieNoori/social/internal/webhook"
 	"github.com/go-redis/redis/v8"
 	"go.uber.org/zap"
 )
@@ -61,10 +63,23 @@ func main() {
 		env: env.GetString("ENV", "development"),
 		mail: mailConfig{
 			exp:       env.GetDuration("EXPIRE_INVITE_EMAIL", time.Hour*24*3),
+			resetExp:  env.GetDuration("EXPIRE_PASSWORD_RESET_EMAIL", time.Hour),
 			fromEmail: env.GetString("FROME_MAIL", ""),
+			backend:   env.GetString("MAILER_BACKEND", "sendgrid"),
 			sendGrid: sendGridConfig{
 				apiKey: env.GetString("SENDGRID_API_KEY", ""),
 			},
+			smtp: smtpConfig{
+				host:     env.GetString("SMTP_HOST", "localhost"),
+				port:     env.GetInt("SMTP_PORT", 1025),
+				username: env.GetString("SMTP_USERNAME", ""),
+				password: env.GetString("SMTP_PASSWORD", ""),
+			},
+			fileDir:      env.GetString("MAILER_FILE_DIR", "tmp/mail"),
+			workers:      env.GetInt("MAILER_WORKERS", 4),
+			pollInterval: env.GetDuration("MAILER_POLL_INTERVAL", time.Second*2),
+			batchSize:    env.GetInt("MAILER_BATCH_SIZE", 20),
+			retention:    env.GetDuration("MAILER_RETENTION", time.Hour*24),
 		},
 		frontendURL: env.GetString("FRONEND_URL", "http://localhost:4000"),
 		auth: authConfig{
@@ -73,11 +88,42 @@ func main() {
 				pass: env.GetString("AUTH_BASIC_PASS", "admin"),
 			},
 			token: tokenConfig{
-				secret: env.GetString("AUTH_TOKEN_SECRET", "example"),
-				exp:    time.Hour * 24 * 3,
-				iss:    "gophersocial",
+				secret:       env.GetString("AUTH_TOKEN_SECRET", "example"),
+				keySetPath:   env.GetString("AUTH_TOKEN_KEYSET", ""),
+				keySetReload: env.GetDuration("AUTH_TOKEN_KEYSET_RELOAD_INTERVAL", time.Minute),
+				exp:          env.GetDuration("AUTH_TOKEN_EXP", time.Minute*15),
+				refreshExp:   env.GetDuration("AUTH_REFRESH_TOKEN_EXP", time.Hour*24*7),
+				iss:          "gophersocial",
+			},
+			login: loginConfig{
+				maxAttempts: env.GetInt("AUTH_MAX_LOGIN_ATTEMPTS", 5),
+				lockout:     env.GetDuration("AUTH_LOCKOUT_DURATION", time.Minute*15),
 			},
 		},
+		cleanup: cleanupConfig{
+			interval:    env.GetDuration("INACTIVE_USERS_CLEANUP_INTERVAL", time.Hour),
+			gracePeriod: env.GetDuration("INACTIVE_USERS_GRACE_PERIOD", time.Hour*24*7),
+		},
+		timeline: timelineConfig{
+			celebrityThreshold: env.GetInt("TIMELINE_CELEBRITY_THRESHOLD", 10000),
+		},
+		trending: trendingConfig{
+			windows:         []time.Duration{time.Hour, time.Hour * 24},
+			refreshInterval: env.GetDuration("TRENDING_TAGS_REFRESH_INTERVAL", time.Minute*5),
+		},
+		stream: streamConfig{
+			heartbeat: env.GetDuration("STREAM_HEARTBEAT_INTERVAL", time.Second*15),
+		},
+		webhooks: webhooksConfig{
+			pollInterval: env.GetDuration("WEBHOOKS_POLL_INTERVAL", time.Second*5),
+			batchSize:    env.GetInt("WEBHOOKS_BATCH_SIZE", 20),
+			timeout:      env.GetDuration("WEBHOOKS_TIMEOUT", time.Second*10),
+		},
+		outbox: outboxConfig{
+			pollInterval: env.GetDuration("OUTBOX_POLL_INTERVAL", time.Second),
+			batchSize:    env.GetInt("OUTBOX_BATCH_SIZE", 50),
+			retention:    env.GetDuration("OUTBOX_RETENTION", time.Hour*24),
+		},
 		rateLimiter: ratelimiter.Config{
 			RequestPerTimeFrame: env.GetInt("RATELIMITER_REQUESTS_COUNT", 20),
 			TimeFrame:           time.Second * 5,
@@ -115,10 +161,30 @@ func main() {
 		cfg.rateLimiter.TimeFrame,
 	)
 
-	// mailer := mailer.NewSendGrid(cfg.mail.sendGrid.apiKey, cfg.mail.fromEmail)
-	mailer := mailer.NewSendGrid(cfg.mail.sendGrid.apiKey, cfg.mail.fromEmail)
+	// events reach clients connected to other instances through redis
+	var broker stream.Broker = stream.NewMemoryBroker()
+	if cfg.redisCfg.enabled {
+		broker, err = stream.NewRedisBroker(context.Background(), rdb)
+		if err != nil {
+			logger.Fatal(err)
+		}
+	}
+
+	mailer, err := newMailer(cfg.mail)
+	if err != nil {
+		logger.Fatal(err)
+	}
+	logger.Infow("Mailer configured", "backend", cfg.mail.backend)
 
-	jwtAuthenticator := auth.NewJWTAuthenticator(cfg.auth.token.secret, cfg.auth.token.iss, cfg.auth.token.iss)
+	var authenticator auth.Authenticator
+	if cfg.auth.token.keySetPath != "" {
+		authenticator, err = auth.NewKeySetAuthenticator(cfg.auth.token.keySetPath, cfg.auth.token.iss, cfg.auth.token.iss)
+		if err != nil {
+			logger.Fatal(err)
+		}
+	} else {
+		authenticator = auth.NewJWTAuthenticator(cfg.auth.token.secret, cfg.auth.token.iss, cfg.auth.token.iss)
+	}
 
 	app := &application{
 		config:        cfg,
@@ -126,8 +192,10 @@ func main() {
 		cacheStore:    cacheStore,
 		logger:        logger,
 		mailer:        mailer,
-		authenticator: jwtAuthenticator,
+		authenticator: authenticator,
 		rateLimiter:   rateLimiter,
+		broker:        broker,
+		webhookSender: webhook.NewSender(cfg.webhooks.timeout),
 	}
 
 	expvar.NewString("version").Set(version)
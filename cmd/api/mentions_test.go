@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/AlieNoori/social/internal/store"
+	"github.com/AlieNoori/social/internal/stream"
+)
+
+// mentionNotificationStore notifies every mentioned user and records the
+// events it was given.
+type mentionNotificationStore struct {
+	store.MockNotificationStore
+	events []store.MentionEvent
+}
+
+func (s *mentionNotificationStore) CreateMentions(_ context.Context, e store.MentionEvent) ([]store.Notification, error) {
+	s.events = append(s.events, e)
+
+	notifications := make([]store.Notification, len(e.UserIds))
+	for i, userId := range e.UserIds {
+		notifications[i] = store.Notification{
+			ID:      i + 1,
+			UserId:  userId,
+			Kind:    store.NotificationMention,
+			ActorId: e.ActorId,
+			PostId:  e.PostId,
+		}
+	}
+
+	return notifications, nil
+}
+
+func TestNotifyMentions(t *testing.T) {
+	app := NewTestApplication(t, config{})
+	notifications := &mentionNotificationStore{}
+	app.store.Notifications = notifications
+	ctx := context.Background()
+
+	const mentionedId = 7
+	sub := app.broker.Subscribe(mentionedId)
+	defer sub.Close()
+
+	event := store.OutboxEvent{
+		Event:   store.OutboxUsersMentioned,
+		Payload: json.RawMessage(`{"actor_id":205,"post_id":1,"user_ids":[7]}`),
+	}
+
+	if err := app.dispatchOutboxEvent(ctx, event, app.outboxHandlers()[event.Event]); err != nil {
+		t.Fatalf("error: %s\n", err.Error())
+	}
+
+	t.Run("should notify the mentioned users", func(t *testing.T) {
+		if len(notifications.events) != 1 {
+			t.Fatalf("expected 1 mention event and got %d", len(notifications.events))
+		}
+
+		e := notifications.events[0]
+		if e.ActorId != 205 || e.PostId == nil || *e.PostId != 1 || len(e.UserIds) != 1 || e.UserIds[0] != mentionedId {
+			t.Errorf("unexpected mention event %+v", e)
+		}
+	})
+
+	t.Run("should stream the mention notifications", func(t *testing.T) {
+		select {
+		case event := <-sub.Events:
+			if event.Type != stream.EventNotification {
+				t.Fatalf("expected a notification event and got %q", event.Type)
+			}
+
+			var n store.Notification
+			if err := json.Unmarshal(event.Data, &n); err != nil {
+				t.Fatalf("error: %s\n", err.Error())
+			}
+
+			if n.ID != 1 || n.Kind != store.NotificationMention || n.Actor.ID != 205 {
+				t.Errorf("unexpected notification %+v", n)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("expected the mention notification to be streamed")
+		}
+	})
+}
@@ -11,6 +11,7 @@ import (
 
 	"github.com/AlieNoori/social/internal/store"
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
 )
 
 func (app *application) TokenAuthMiddleware(next http.Handler) http.Handler {
@@ -45,6 +46,24 @@ func (app *application) TokenAuthMiddleware(next http.Handler) http.Handler {
 
 		ctx := r.Context()
 
+		// tokens issued before sessions existed carry no session id
+		sessionId, _ := claims["sid"].(string)
+		if _, err := uuid.Parse(sessionId); err != nil {
+			app.unauthorizedErrorResponse(w, r, fmt.Errorf("token has no valid session"))
+			return
+		}
+
+		active, err := app.store.Sessions.IsActive(ctx, sessionId)
+		if err != nil {
+			app.internalServerError(w, r, err)
+			return
+		}
+
+		if !active {
+			app.unauthorizedErrorResponse(w, r, fmt.Errorf("session has been revoked"))
+			return
+		}
+
 		user, err := app.getUser(ctx, userId)
 		if err != nil {
 			log.Println("get user", err)
@@ -58,6 +77,22 @@ func (app *application) TokenAuthMiddleware(next http.Handler) http.Handler {
 	})
 }
 
+// OptionalTokenAuthMiddleware authenticates the request like
+// TokenAuthMiddleware when it carries a token and lets it through anonymously
+// otherwise.
+func (app *application) OptionalTokenAuthMiddleware(next http.Handler) http.Handler {
+	authenticated := app.TokenAuthMiddleware(next)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Autorization") == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		authenticated.ServeHTTP(w, r)
+	})
+}
+
 func (app *application) BasicAuthMiddleware() func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -98,11 +133,20 @@ func (app *application) BasicAuthMiddleware() func(http.Handler) http.Handler {
 }
 
 func (app *application) checkPostOwnership(requiredRole string, next http.HandlerFunc) http.HandlerFunc {
+	return app.checkOwnership(requiredRole, func(r *http.Request) int { return getPostFromCtx(r).UserId }, next)
+}
+
+func (app *application) checkCommentOwnership(requiredRole string, next http.HandlerFunc) http.HandlerFunc {
+	return app.checkOwnership(requiredRole, func(r *http.Request) int { return getCommentFromCtx(r).UserId }, next)
+}
+
+// checkOwnership lets the owner of a resource through, as well as any user
+// whose role is at least requiredRole.
+func (app *application) checkOwnership(requiredRole string, ownerId func(*http.Request) int, next http.HandlerFunc) http.HandlerFunc {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		user := getUserFromCtx(r)
-		post := getPostFromCtx(r)
 
-		if post.UserId == user.ID {
+		if ownerId(r) == user.ID {
 			next.ServeHTTP(w, r)
 			return
 		}
@@ -122,6 +166,26 @@ func (app *application) checkPostOwnership(requiredRole string, next http.Handle
 	})
 }
 
+// requireRole only lets through users whose role is at least roleName.
+func (app *application) requireRole(roleName string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			allowed, err := app.checkRolePrecedence(r.Context(), getUserFromCtx(r), roleName)
+			if err != nil {
+				app.internalServerError(w, r, err)
+				return
+			}
+
+			if !allowed {
+				app.forbiddenResponse(w, r)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
 func (app *application) checkRolePrecedence(ctx context.Context, user *store.User, roleName string) (bool, error) {
 	role, err := app.store.Roles.GetByName(ctx, roleName)
 	if err != nil {
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/AlieNoori/social/internal/store"
+	"github.com/AlieNoori/social/internal/stream"
+)
+
+type MarkNotificationsReadPayload struct {
+	IDs []int `json:"ids" validate:"max=100,dive,gte=1"`
+	All bool  `json:"all"`
+}
+
+// notify creates a notification as a side effect of a request, a failure is
+// logged without failing it.
+func (app *application) notify(ctx context.Context, n *store.Notification) {
+	if err := app.createNotification(ctx, n); err != nil {
+		app.logger.Errorw("error creating notification", "kind", n.Kind, "user", n.UserId, "error", err)
+	}
+}
+
+// createNotification records a notification for the user and pushes it to
+// their connected clients.
+func (app *application) createNotification(ctx context.Context, n *store.Notification) error {
+	if err := app.store.Notifications.Create(ctx, n); err != nil {
+		return err
+	}
+
+	if n.ID == 0 {
+		return nil
+	}
+
+	return app.publishNotification(ctx, n)
+}
+
+// publishNotification pushes a recorded notification to the connected
+// clients of its user.
+func (app *application) publishNotification(ctx context.Context, n *store.Notification) error {
+	actor, err := app.getUser(ctx, n.ActorId)
+	if err != nil {
+		return err
+	}
+	n.Actor = store.User{ID: actor.ID, UserName: actor.UserName}
+
+	app.publish([]int{n.UserId}, stream.EventNotification, n)
+
+	return nil
+}
+
+// GetNotifications godoc
+//
+//	@Summary		Fetches the notifications
+//	@Description	Fetches a page of the notifications of the authenticated user, most recent first, with the number of unread ones
+//	@Tags			notifications
+//	@Accept			json
+//	@Produce		json
+//	@Param			limit	query		int		false	"Limit"
+//	@Param			cursor	query		string	false	"Cursor of the next page"
+//	@Success		200		{object}	[]store.Notification
+//	@Failure		400		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/notifications [get]
+func (app *application) getNotificationsHandler(w http.ResponseWriter, r *http.Request) {
+	cursor, limit, err := parseConnectionsPage(r)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	user := getUserFromCtx(r)
+	ctx := r.Context()
+
+	notifications, err := app.store.Notifications.GetForUser(ctx, user.ID, cursor, limit)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	unread, err := app.store.Notifications.CountUnread(ctx, user.ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	var nextCursor *string
+	if len(notifications) == limit {
+		last := notifications[len(notifications)-1]
+		c := store.FeedCursor{CreatedAt: last.CreatedAt, ID: last.ID}.Encode()
+		nextCursor = &c
+	}
+
+	type envelope struct {
+		Data        []store.Notification `json:"data"`
+		NextCursor  *string              `json:"next_cursor"`
+		UnreadCount int                  `json:"unread_count"`
+	}
+
+	if err := writeJSON(w, http.StatusOK, &envelope{
+		Data:        notifications,
+		NextCursor:  nextCursor,
+		UnreadCount: unread,
+	}); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// MarkNotificationsRead godoc
+//
+//	@Summary		Marks notifications as read
+//	@Description	Marks the given notifications of the authenticated user as read, or all of them
+//	@Tags			notifications
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		MarkNotificationsReadPayload	true	"Notifications to mark"
+//	@Success		204		{string}	string							"Notifications read"
+//	@Failure		400		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/notifications/read [post]
+func (app *application) markNotificationsReadHandler(w http.ResponseWriter, r *http.Request) {
+	var payload MarkNotificationsReadPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if payload.All == (len(payload.IDs) > 0) {
+		app.badRequestResponse(w, r, errors.New("either ids or all must be set"))
+		return
+	}
+
+	if err := app.store.Notifications.MarkRead(r.Context(), getUserFromCtx(r).ID, payload.IDs); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
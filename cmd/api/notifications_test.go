@@ -0,0 +1,54 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestNotificationsHandlers(t *testing.T) {
+	app := NewTestApplication(t, config{})
+	mux := app.mount()
+	testToken, err := app.authenticator.GenerateToken(nil)
+	if err != nil {
+		t.Fatalf("error: %s\n", err.Error())
+	}
+
+	t.Run("should list notifications with the unread count", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodGet, "http://localhost:8080/v1/notifications?limit=10", nil)
+		if err != nil {
+			t.Fatalf("error: %s\n", err.Error())
+		}
+
+		req.Header.Set("Autorization", "Bearer "+testToken)
+
+		rr := executeRequest(req, mux)
+
+		checkResponse(t, http.StatusOK, rr.Code)
+
+		if !strings.Contains(rr.Body.String(), `"unread_count":0`) {
+			t.Errorf("expected the unread count in %s", rr.Body.String())
+		}
+	})
+
+	for body, want := range map[string]int{
+		`{"ids":[1,2]}`:          http.StatusNoContent,
+		`{"all":true}`:           http.StatusNoContent,
+		`{}`:                     http.StatusBadRequest,
+		`{"ids":[1],"all":true}`: http.StatusBadRequest,
+		`{"ids":[0]}`:            http.StatusBadRequest,
+	} {
+		t.Run("mark read "+body, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodPost, "http://localhost:8080/v1/notifications/read", strings.NewReader(body))
+			if err != nil {
+				t.Fatalf("error: %s\n", err.Error())
+			}
+
+			req.Header.Set("Autorization", "Bearer "+testToken)
+
+			rr := executeRequest(req, mux)
+
+			checkResponse(t, want, rr.Code)
+		})
+	}
+}
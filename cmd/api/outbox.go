@@ -0,0 +1,245 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/AlieNoori/social/internal/backoff"
+	"github.com/AlieNoori/social/internal/store"
+	"github.com/AlieNoori/social/internal/stream"
+)
+
+// outboxLease bounds the time a dispatcher has to handle an event before
+// another one picks it up.
+const outboxLease = 5 * time.Minute
+
+// outboxRetry spaces the attempts of an event from a second up to ten
+// minutes, events are retried until all their handlers succeed.
+var outboxRetry = backoff.Policy{Base: time.Second, Max: 10 * time.Minute}
+
+type outboxHandler struct {
+	// name identifies the handler in the outbox_handled log, renaming a
+	// handler runs it again for events that are not processed yet.
+	name   string
+	handle func(context.Context, store.OutboxEvent) error
+}
+
+// outboxHandlers maps each outbox event to the handlers it is dispatched to.
+// Events are delivered at least once: a handler can run again for the same
+// event, with the same idempotency key, if the dispatcher stops before
+// recording it. Events of issued one-time tokens have no handler, their email
+// is queued along with the token.
+func (app *application) outboxHandlers() map[string][]outboxHandler {
+	webhooks := outboxHandler{"webhooks", app.enqueueWebhooks}
+	notifications := outboxHandler{"notifications", app.notifyOutboxEvent}
+
+	return map[string][]outboxHandler{
+		store.OutboxUserActivated:   {webhooks},
+		store.OutboxPostCreated:     {{"fan-out", app.fanOutCreatedPost}, webhooks},
+		store.OutboxPostDeleted:     {webhooks},
+		store.OutboxCommentCreated:  {notifications, webhooks},
+		store.OutboxUserFollowed:    {{"timelines", app.invalidateFollowerTimeline}, notifications},
+		store.OutboxFollowRequested: {notifications},
+		store.OutboxUsersMentioned:  {notifications},
+	}
+}
+
+// dispatchOutbox handles a batch of due outbox events. An event whose
+// handlers all succeeded is marked processed, otherwise it is retried with
+// a backoff and only the handlers that failed run again.
+func (app *application) dispatchOutbox(ctx context.Context) error {
+	events, err := app.store.Outbox.ClaimDue(ctx, app.config.outbox.batchSize, outboxLease)
+	if err != nil {
+		return err
+	}
+
+	handlers := app.outboxHandlers()
+
+	for _, event := range events {
+		if err := app.dispatchOutboxEvent(ctx, event, handlers[event.Event]); err != nil {
+			app.logger.Errorw("error dispatching outbox event", "event", event.Event, "id", event.ID, "attempts", event.Attempts, "error", err)
+
+			retryAt := time.Now().Add(outboxRetry.Delay(event.Attempts))
+			if err := app.store.Outbox.MarkFailed(ctx, event.ID, err.Error(), retryAt); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if err := app.store.Outbox.MarkProcessed(ctx, event.ID); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (app *application) dispatchOutboxEvent(ctx context.Context, event store.OutboxEvent, handlers []outboxHandler) error {
+	var errs []error
+	for _, h := range handlers {
+		if slices.Contains(event.Handled, h.name) {
+			continue
+		}
+
+		if err := h.handle(ctx, event); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", h.name, err))
+			continue
+		}
+
+		if err := app.store.Outbox.MarkHandled(ctx, event.ID, h.name); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+func (app *application) purgeOutbox(ctx context.Context) error {
+	deleted, err := app.store.Outbox.PurgeProcessed(ctx, app.config.outbox.retention)
+	if err != nil {
+		return err
+	}
+
+	if deleted > 0 {
+		app.logger.Infow("purged outbox events", "count", deleted)
+	}
+
+	return nil
+}
+
+// enqueueWebhooks forwards the event to the webhooks subscribed to it, the
+// outbox event names double as webhook event names.
+func (app *application) enqueueWebhooks(ctx context.Context, event store.OutboxEvent) error {
+	return app.store.Webhooks.Enqueue(ctx, event.Event, event.IdempotencyKey, event.Payload)
+}
+
+func (app *application) fanOutCreatedPost(ctx context.Context, event store.OutboxEvent) error {
+	var post store.Post
+	if err := json.Unmarshal(event.Payload, &post); err != nil {
+		return err
+	}
+
+	return app.fanOutPost(ctx, &post)
+}
+
+func (app *application) invalidateFollowerTimeline(ctx context.Context, event store.OutboxEvent) error {
+	var e store.FollowEvent
+	if err := json.Unmarshal(event.Payload, &e); err != nil {
+		return err
+	}
+
+	app.invalidateTimeline(ctx, e.FollowerId)
+
+	return nil
+}
+
+// notifyOutboxEvent creates the notifications of an event. Notifications
+// are coalesced by the store so running it twice is harmless.
+func (app *application) notifyOutboxEvent(ctx context.Context, event store.OutboxEvent) error {
+	switch event.Event {
+	case store.OutboxUserFollowed, store.OutboxFollowRequested:
+		var e store.FollowEvent
+		if err := json.Unmarshal(event.Payload, &e); err != nil {
+			return err
+		}
+
+		kind := store.NotificationFollow
+		if event.Event == store.OutboxFollowRequested {
+			kind = store.NotificationFollowRequest
+		}
+
+		return app.createNotification(ctx, &store.Notification{
+			UserId:  e.FollowedId,
+			ActorId: e.FollowerId,
+			Kind:    kind,
+		})
+	case store.OutboxCommentCreated:
+		var comment store.Comment
+		if err := json.Unmarshal(event.Payload, &comment); err != nil {
+			return err
+		}
+
+		return app.notifyComment(ctx, &comment)
+	case store.OutboxUsersMentioned:
+		var e store.MentionEvent
+		if err := json.Unmarshal(event.Payload, &e); err != nil {
+			return err
+		}
+
+		return app.notifyMentions(ctx, e)
+	}
+
+	return nil
+}
+
+// notifyMentions notifies the users mentioned in a post or comment.
+func (app *application) notifyMentions(ctx context.Context, e store.MentionEvent) error {
+	notifications, err := app.store.Notifications.CreateMentions(ctx, e)
+	if err != nil {
+		return err
+	}
+
+	for i := range notifications {
+		if err := app.publishNotification(ctx, &notifications[i]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// notifyComment notifies the author of the post and, for a reply, the author
+// of the parent comment.
+func (app *application) notifyComment(ctx context.Context, comment *store.Comment) error {
+	post, err := app.store.Posts.GetById(ctx, comment.PostId)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			// the post was deleted in the meantime
+			return nil
+		}
+		return err
+	}
+
+	if post.UserId != comment.UserId {
+		app.publish([]int{post.UserId}, stream.EventComment, comment)
+	}
+
+	err = app.createNotification(ctx, &store.Notification{
+		UserId:    post.UserId,
+		ActorId:   comment.UserId,
+		Kind:      store.NotificationComment,
+		PostId:    &post.ID,
+		CommentId: &comment.ID,
+	})
+	if err != nil {
+		return err
+	}
+
+	if comment.ParentId == nil {
+		return nil
+	}
+
+	parent, err := app.store.Comments.GetById(ctx, *comment.ParentId)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			return nil
+		}
+		return err
+	}
+
+	if parent.UserId == post.UserId {
+		return nil
+	}
+
+	return app.createNotification(ctx, &store.Notification{
+		UserId:    parent.UserId,
+		ActorId:   comment.UserId,
+		Kind:      store.NotificationReply,
+		PostId:    &post.ID,
+		CommentId: &comment.ID,
+	})
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/AlieNoori/social/internal/store"
+)
+
+// recordingOutboxStore hands out the given events once and records how they
+// were settled.
+type recordingOutboxStore struct {
+	store.MockOutboxStore
+	due       []store.OutboxEvent
+	handled   map[int][]string
+	processed []int
+	failed    map[int]time.Time
+}
+
+func (s *recordingOutboxStore) ClaimDue(context.Context, int, time.Duration) ([]store.OutboxEvent, error) {
+	due := s.due
+	s.due = nil
+	return due, nil
+}
+
+func (s *recordingOutboxStore) MarkHandled(_ context.Context, id int, handler string) error {
+	s.handled[id] = append(s.handled[id], handler)
+	return nil
+}
+
+func (s *recordingOutboxStore) MarkProcessed(_ context.Context, id int) error {
+	s.processed = append(s.processed, id)
+	return nil
+}
+
+func (s *recordingOutboxStore) MarkFailed(_ context.Context, id int, _ string, retryAt time.Time) error {
+	s.failed[id] = retryAt
+	return nil
+}
+
+type failingWebhookStore struct {
+	store.MockWebhookStore
+	enqueued []string
+}
+
+func (s *failingWebhookStore) Enqueue(_ context.Context, event, key string, _ any) error {
+	s.enqueued = append(s.enqueued, key)
+	return errors.New("webhooks unavailable")
+}
+
+func TestDispatchOutbox(t *testing.T) {
+	app := NewTestApplication(t, config{outbox: outboxConfig{batchSize: 10}})
+	outbox := &recordingOutboxStore{handled: make(map[int][]string), failed: make(map[int]time.Time)}
+	webhooks := &failingWebhookStore{}
+	app.store.Outbox = outbox
+	app.store.Webhooks = webhooks
+
+	comment, err := json.Marshal(&store.Comment{ID: 3, PostId: 1, UserId: 205})
+	if err != nil {
+		t.Fatalf("error: %s\n", err.Error())
+	}
+
+	outbox.due = []store.OutboxEvent{
+		{ID: 1, Event: store.OutboxCommentCreated, IdempotencyKey: "comment.created:3", Payload: comment, Attempts: 1},
+		{ID: 2, Event: store.OutboxPostDeleted, IdempotencyKey: "post.deleted:4", Payload: json.RawMessage(`{"id":4}`), Handled: []string{"webhooks"}},
+		{ID: 3, Event: "unknown", IdempotencyKey: "unknown:1", Payload: json.RawMessage(`{}`)},
+	}
+
+	if err := app.dispatchOutbox(context.Background()); err != nil {
+		t.Fatalf("error: %s\n", err.Error())
+	}
+
+	t.Run("should retry the failed handlers only", func(t *testing.T) {
+		if !slices.Equal(outbox.handled[1], []string{"notifications"}) {
+			t.Errorf("expected the notifications to be recorded as handled and got %v", outbox.handled[1])
+		}
+
+		if _, ok := outbox.failed[1]; !ok {
+			t.Error("expected the event to be retried")
+		}
+
+		if slices.Contains(outbox.processed, 1) {
+			t.Error("expected the event not to be processed")
+		}
+	})
+
+	t.Run("should pass the idempotency key to the handlers", func(t *testing.T) {
+		if !slices.Equal(webhooks.enqueued, []string{"comment.created:3"}) {
+			t.Errorf("unexpected enqueued webhooks %v", webhooks.enqueued)
+		}
+	})
+
+	t.Run("should process events whose handlers are done", func(t *testing.T) {
+		if !slices.Equal(outbox.processed, []int{2, 3}) {
+			t.Errorf("expected events 2 and 3 to be processed and got %v", outbox.processed)
+		}
+	})
+}
+
+func TestOutboxRetry(t *testing.T) {
+	want := map[int]time.Duration{1: time.Second, 2: 2 * time.Second, 5: 16 * time.Second, 30: 10 * time.Minute}
+
+	for attempts, backoff := range want {
+		if got := outboxRetry.Delay(attempts); got != backoff {
+			t.Errorf("expected a backoff of %s after %d attempts and got %s", backoff, attempts, got)
+		}
+	}
+}
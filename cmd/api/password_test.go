@@ -0,0 +1,117 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/AlieNoori/social/internal/store"
+)
+
+// resetUserStore keeps password reset tokens in memory, a token can be used
+// once before it expires.
+type resetUserStore struct {
+	store.MockUserStore
+	users  map[string]*store.User
+	tokens map[string]time.Time
+	queued []*store.QueuedEmail
+}
+
+func (s *resetUserStore) CreatePasswordReset(_ context.Context, email, token string, exp time.Duration, resetEmail store.EmailFunc) (*store.User, error) {
+	user, ok := s.users[email]
+	if !ok {
+		return nil, store.ErrNotFound
+	}
+
+	queued, err := resetEmail(user)
+	if err != nil {
+		return nil, err
+	}
+
+	s.tokens[token] = time.Now().Add(exp)
+	s.queued = append(s.queued, queued)
+
+	return user, nil
+}
+
+func (s *resetUserStore) ResetPassword(_ context.Context, token, _ string) error {
+	expiry, ok := s.tokens[token]
+	if !ok || !expiry.After(time.Now()) {
+		return store.ErrNotFound
+	}
+	delete(s.tokens, token)
+
+	return nil
+}
+
+func TestPasswordHandlers(t *testing.T) {
+	app := NewTestApplication(t, config{
+		frontendURL: "http://localhost:5173",
+		mail:        mailConfig{resetExp: time.Hour},
+	})
+	users := &resetUserStore{
+		users:  map[string]*store.User{"gopher@example.com": {ID: 1, UserName: "gopher", Email: "gopher@example.com"}},
+		tokens: make(map[string]time.Time),
+	}
+	app.store.Users = users
+	mux := app.mount()
+
+	post := func(t *testing.T, url string, payload any) int {
+		t.Helper()
+
+		body, err := json.Marshal(payload)
+		if err != nil {
+			t.Fatalf("error: %s\n", err.Error())
+		}
+
+		req, err := http.NewRequest(http.MethodPost, "http://localhost:8080"+url, bytes.NewReader(body))
+		if err != nil {
+			t.Fatalf("error: %s\n", err.Error())
+		}
+
+		return executeRequest(req, mux).Code
+	}
+
+	t.Run("should accept an unknown email without queueing an email", func(t *testing.T) {
+		code := post(t, "/v1/authentication/password/forgot", ForgotPasswordPayload{Email: "nobody@example.com"})
+
+		checkResponse(t, http.StatusAccepted, code)
+		if len(users.queued) != 0 {
+			t.Errorf("expected no email and got %d", len(users.queued))
+		}
+	})
+
+	t.Run("should reset the password once with the emailed token", func(t *testing.T) {
+		code := post(t, "/v1/authentication/password/forgot", ForgotPasswordPayload{Email: "gopher@example.com"})
+
+		checkResponse(t, http.StatusAccepted, code)
+		if len(users.queued) != 1 {
+			t.Fatalf("expected one email and got %d", len(users.queued))
+		}
+
+		var data struct{ ResetURL string }
+		if err := json.Unmarshal(users.queued[0].Data, &data); err != nil {
+			t.Fatalf("error: %s\n", err.Error())
+		}
+		token := data.ResetURL[strings.LastIndex(data.ResetURL, "/")+1:]
+		if _, ok := users.tokens[token]; !ok {
+			t.Fatalf("expected the email to link to the stored token, got %s", data.ResetURL)
+		}
+
+		payload := ResetPasswordPayload{Token: token, Password: "new password"}
+		checkResponse(t, http.StatusNoContent, post(t, "/v1/authentication/password/reset", payload))
+		checkResponse(t, http.StatusNotFound, post(t, "/v1/authentication/password/reset", payload))
+	})
+
+	t.Run("should reject an expired token", func(t *testing.T) {
+		users.tokens["expired"] = time.Now().Add(-time.Minute)
+
+		code := post(t, "/v1/authentication/password/reset", ResetPasswordPayload{Token: "expired", Password: "new password"})
+
+		checkResponse(t, http.StatusNotFound, code)
+	})
+}
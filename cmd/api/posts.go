@@ -48,6 +48,7 @@ func (app *application) createPostHandler(w http.ResponseWriter, r *http.Request
 
 	if err := validate.Struct(payload); err != nil {
 		app.badRequestResponse(w, r, err)
+		return
 	}
 
 	user := getUserFromCtx(r)
@@ -87,13 +88,29 @@ func (app *application) createPostHandler(w http.ResponseWriter, r *http.Request
 func (app *application) getPostHandler(w http.ResponseWriter, r *http.Request) {
 	post := getPostFromCtx(r)
 
-	comments, err := app.store.Comments.GetByPostId(r.Context(), post.ID)
+	// only the newest comments are embedded, the rest is paginated through
+	// the comments endpoint
+	pq := store.PaginatedQuery{
+		Limit:  20,
+		Offset: 0,
+		Sort:   "desc",
+	}
+
+	comments, err := app.store.Comments.GetByPostId(r.Context(), post.ID, getUserFromCtx(r).ID, pq)
 	if err != nil {
 		app.internalServerError(w, r, err)
 		return
 	}
 	post.Comments = comments
 
+	reactions, myReaction, err := app.store.Reactions.GetForPost(r.Context(), post.ID, getUserFromCtx(r).ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	post.Reactions = reactions
+	post.MyReaction = myReaction
+
 	if err := app.writeResponse(w, http.StatusOK, *post); err != nil {
 		app.internalServerError(w, r, err)
 		return
@@ -213,6 +230,19 @@ func (app *application) postsContextMiddleware(next http.Handler) http.Handler {
 			return
 		}
 
+		// posts of a user that blocked the viewer, or of a private user the
+		// viewer does not follow, are hidden as if they did not exist
+		visible, err := app.store.Users.CanView(ctx, getUserFromCtx(r).ID, post.UserId)
+		if err != nil {
+			app.internalServerError(w, r, err)
+			return
+		}
+
+		if !visible {
+			app.notFoundResponse(w, r, store.ErrNotFound)
+			return
+		}
+
 		ctx = context.WithValue(ctx, postCtxKey, post)
 
 		next.ServeHTTP(w, r.WithContext(ctx))
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/AlieNoori/social/internal/store"
+	"github.com/AlieNoori/social/internal/stream"
+	"github.com/go-chi/chi/v5"
+)
+
+// ReactionEvent is streamed to the author of a post when someone reacts to
+// it.
+type ReactionEvent struct {
+	PostId int        `json:"post_id"`
+	Kind   string     `json:"kind"`
+	User   store.User `json:"user"`
+}
+
+// ReactToPost godoc
+//
+//	@Summary		Reacts to a post
+//	@Description	Sets the reaction of the user to a post, replacing a previous one
+//	@Tags			posts
+//	@Accept			json
+//	@Produce		json
+//	@Param			postID	path		int		true	"Post ID"
+//	@Param			kind	path		string	true	"Reaction kind"	Enums(like, love, laugh, wow, sad, angry)
+//	@Success		204		{string}	string	"Reaction set"
+//	@Failure		400		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/posts/{postID}/reactions/{kind} [put]
+func (app *application) reactToPostHandler(w http.ResponseWriter, r *http.Request) {
+	kind, err := reactionKindParam(r)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	user := getUserFromCtx(r)
+	post := getPostFromCtx(r)
+
+	if err := app.store.Reactions.Set(r.Context(), post.ID, user.ID, kind); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if post.UserId != user.ID {
+		app.publish([]int{post.UserId}, stream.EventReaction, &ReactionEvent{
+			PostId: post.ID,
+			Kind:   kind,
+			User:   store.User{ID: user.ID, UserName: user.UserName},
+		})
+	}
+
+	app.notify(r.Context(), &store.Notification{
+		UserId:  post.UserId,
+		ActorId: user.ID,
+		Kind:    store.NotificationReaction,
+		PostId:  &post.ID,
+	})
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// RemoveReaction godoc
+//
+//	@Summary		Removes a reaction
+//	@Description	Removes the reaction of the user from a post
+//	@Tags			posts
+//	@Accept			json
+//	@Produce		json
+//	@Param			postID	path		int		true	"Post ID"
+//	@Param			kind	path		string	true	"Reaction kind"	Enums(like, love, laugh, wow, sad, angry)
+//	@Success		204		{string}	string	"Reaction removed"
+//	@Failure		400		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/posts/{postID}/reactions/{kind} [delete]
+func (app *application) removeReactionHandler(w http.ResponseWriter, r *http.Request) {
+	kind, err := reactionKindParam(r)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	user := getUserFromCtx(r)
+	post := getPostFromCtx(r)
+
+	if err := app.store.Reactions.Remove(r.Context(), post.ID, user.ID, kind); err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func reactionKindParam(r *http.Request) (string, error) {
+	kind := chi.URLParam(r, "kind")
+	if !store.IsValidReactionKind(kind) {
+		return "", fmt.Errorf("invalid reaction %q, must be one of: %s", kind, strings.Join(store.ReactionKinds, ", "))
+	}
+
+	return kind, nil
+}
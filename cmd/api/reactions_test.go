@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/AlieNoori/social/internal/store"
+)
+
+// memoryReactionStore keeps the reaction of each user to a post in memory.
+type memoryReactionStore struct {
+	reactions map[int]string
+}
+
+func (s *memoryReactionStore) Set(_ context.Context, _, userId int, kind string) error {
+	s.reactions[userId] = kind
+	return nil
+}
+
+func (s *memoryReactionStore) Remove(_ context.Context, _, userId int, kind string) error {
+	if s.reactions[userId] != kind {
+		return store.ErrNotFound
+	}
+	delete(s.reactions, userId)
+
+	return nil
+}
+
+func (s *memoryReactionStore) GetForPost(context.Context, int, int) (store.ReactionCounts, *string, error) {
+	return store.ReactionCounts{}, nil, nil
+}
+
+func TestReactionHandlers(t *testing.T) {
+	app := NewTestApplication(t, config{})
+	reactions := &memoryReactionStore{reactions: make(map[int]string)}
+	app.store.Reactions = reactions
+	mux := app.mount()
+
+	testToken, err := app.authenticator.GenerateToken(nil)
+	if err != nil {
+		t.Fatalf("error: %s\n", err.Error())
+	}
+
+	request := func(t *testing.T, method, url string) int {
+		t.Helper()
+
+		req, err := http.NewRequest(method, "http://localhost:8080"+url, nil)
+		if err != nil {
+			t.Fatalf("error: %s\n", err.Error())
+		}
+		req.Header.Set("Autorization", "Bearer "+testToken)
+
+		return executeRequest(req, mux).Code
+	}
+
+	t.Run("should reject unknown reaction kinds", func(t *testing.T) {
+		checkResponse(t, http.StatusBadRequest, request(t, http.MethodPut, "/v1/posts/1/reactions/meh"))
+		checkResponse(t, http.StatusBadRequest, request(t, http.MethodDelete, "/v1/posts/1/reactions/meh"))
+	})
+
+	t.Run("should replace the reaction of the user", func(t *testing.T) {
+		checkResponse(t, http.StatusNoContent, request(t, http.MethodPut, "/v1/posts/1/reactions/like"))
+		checkResponse(t, http.StatusNoContent, request(t, http.MethodPut, "/v1/posts/1/reactions/wow"))
+
+		if reactions.reactions[205] != "wow" {
+			t.Errorf("expected reaction wow and got %q", reactions.reactions[205])
+		}
+	})
+
+	t.Run("should not remove a kind the user did not react with", func(t *testing.T) {
+		checkResponse(t, http.StatusNotFound, request(t, http.MethodDelete, "/v1/posts/1/reactions/like"))
+		checkResponse(t, http.StatusNoContent, request(t, http.MethodDelete, "/v1/posts/1/reactions/wow"))
+		checkResponse(t, http.StatusNotFound, request(t, http.MethodDelete, "/v1/posts/1/reactions/wow"))
+	})
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/AlieNoori/social/internal/store"
+)
+
+// SearchPosts godoc
+//
+//	@Summary		Searches posts
+//	@Description	Full-text search of the posts visible to the authenticated user, ranked by relevance and recency
+//	@Tags			search
+//	@Accept			json
+//	@Produce		json
+//	@Param			q		query		string	true	"Search query, supports quoted phrases, OR and -excluded words"
+//	@Param			tags	query		string	false	"Tags"
+//	@Param			limit	query		int		false	"Limit"
+//	@Param			cursor	query		string	false	"Cursor of the next page"
+//	@Success		200		{object}	[]store.PostSearchResult
+//	@Failure		400		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/search/posts [get]
+func (app *application) searchPostsHandler(w http.ResponseWriter, r *http.Request) {
+	sq := store.PostSearchQuery{
+		Limit: 20,
+	}
+
+	if err := sq.Parse(r); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := validate.Struct(sq); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	results, err := app.store.Posts.Search(r.Context(), getUserFromCtx(r).ID, sq)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	var nextCursor *string
+	if len(results) == sq.Limit {
+		last := results[len(results)-1]
+		cursor := store.SearchCursor{AsOf: sq.AsOf, Score: last.Score, ID: last.ID}.Encode()
+		nextCursor = &cursor
+	}
+
+	if err := app.writePaginatedResponse(w, http.StatusOK, results, nextCursor); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+type UserSearchQuery struct {
+	Query string `validate:"required,max=50"`
+	Limit int    `validate:"gte=1,lte=20"`
+}
+
+// SearchUsers godoc
+//
+//	@Summary		Searches users
+//	@Description	Finds users by username for type-ahead, prefix matches first, then by similarity and follower count
+//	@Tags			search
+//	@Accept			json
+//	@Produce		json
+//	@Param			q		query		string	true	"Username or the start of it"
+//	@Param			limit	query		int		false	"Limit"
+//	@Success		200		{object}	[]store.UserSearchResult
+//	@Failure		400		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/search/users [get]
+func (app *application) searchUsersHandler(w http.ResponseWriter, r *http.Request) {
+	qv := r.URL.Query()
+	uq := UserSearchQuery{
+		Query: strings.TrimSpace(qv.Get("q")),
+		Limit: 10,
+	}
+
+	if v := qv.Get("limit"); v != "" {
+		l, err := strconv.Atoi(v)
+		if err != nil {
+			app.badRequestResponse(w, r, err)
+			return
+		}
+		uq.Limit = l
+	}
+
+	if err := validate.Struct(uq); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	users, err := app.store.Users.Search(r.Context(), getUserFromCtx(r).ID, uq.Query, uq.Limit)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.writeResponse(w, http.StatusOK, users); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestSearchHandlers(t *testing.T) {
+	app := NewTestApplication(t, config{})
+	mux := app.mount()
+	testToken, err := app.authenticator.GenerateToken(nil)
+	if err != nil {
+		t.Fatalf("error: %s\n", err.Error())
+	}
+
+	for url, want := range map[string]int{
+		"/v1/search/posts?q=golang":         http.StatusOK,
+		"/v1/search/posts":                  http.StatusBadRequest,
+		"/v1/search/posts?q=go&cursor=nope": http.StatusBadRequest,
+		"/v1/search/users?q=al":             http.StatusOK,
+		"/v1/search/users?q=+":              http.StatusBadRequest,
+		"/v1/search/users?q=al&limit=100":   http.StatusBadRequest,
+	} {
+		t.Run(url, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodGet, "http://localhost:8080"+url, nil)
+			if err != nil {
+				t.Fatalf("error: %s\n", err.Error())
+			}
+
+			req.Header.Set("Autorization", "Bearer "+testToken)
+
+			rr := executeRequest(req, mux)
+
+			checkResponse(t, want, rr.Code)
+		})
+	}
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/AlieNoori/social/internal/stream"
+)
+
+// streamRetry tells clients how long to wait before reconnecting, in
+// milliseconds.
+const streamRetry = 3000
+
+// publish pushes an event to the connected clients of the users in the
+// background, delivery is best effort and events published once shutdown
+// started are dropped.
+func (app *application) publish(userIds []int, eventType string, data any) {
+	if len(userIds) == 0 {
+		return
+	}
+
+	app.jobs.Go(func() {
+		if err := app.broker.Publish(context.Background(), userIds, eventType, data); err != nil {
+			app.logger.Errorw("error publishing event", "type", eventType, "error", err)
+		}
+	})
+}
+
+// Stream godoc
+//
+//	@Summary		Streams events
+//	@Description	Streams new posts of followed users, comments and reactions on the user's posts and notifications as Server-Sent Events. A client reconnecting with the Last-Event-ID header, or the last_event_id query parameter, receives the events it missed.
+//	@Tags			stream
+//	@Produce		text/event-stream
+//	@Param			Last-Event-ID	header		int		false	"ID of the last event received"
+//	@Param			last_event_id	query		int		false	"ID of the last event received"
+//	@Success		200				{string}	string	"Event stream"
+//	@Failure		400				{object}	error
+//	@Failure		500				{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/stream [get]
+func (app *application) streamHandler(w http.ResponseWriter, r *http.Request) {
+	lastEventId, err := parseLastEventId(r)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	user := getUserFromCtx(r)
+	ctx := r.Context()
+
+	// subscribe before replaying so no event falls between the two, events
+	// received twice are skipped by their ID
+	sub := app.broker.Subscribe(user.ID)
+	defer sub.Close()
+
+	var missed []stream.Event
+	if lastEventId > 0 {
+		missed, err = app.broker.Replay(ctx, user.ID, lastEventId)
+		if err != nil {
+			app.internalServerError(w, r, err)
+			return
+		}
+	}
+
+	// the server write timeout would end the stream
+	rc := http.NewResponseController(w)
+	if err := rc.SetWriteDeadline(time.Time{}); err != nil && !errors.Is(err, http.ErrNotSupported) {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	if _, err := fmt.Fprintf(w, "retry: %d\n\n", streamRetry); err != nil {
+		return
+	}
+
+	for _, event := range missed {
+		if err := writeEvent(w, event); err != nil {
+			return
+		}
+		lastEventId = event.ID
+	}
+
+	if err := rc.Flush(); err != nil {
+		return
+	}
+
+	heartbeat := time.NewTicker(app.config.stream.heartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case event, ok := <-sub.Events:
+			if !ok {
+				return
+			}
+			if event.ID <= lastEventId {
+				continue
+			}
+			if err := writeEvent(w, event); err != nil {
+				return
+			}
+			lastEventId = event.ID
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(w, ": heartbeat\n\n"); err != nil {
+				return
+			}
+		}
+
+		if err := rc.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+func writeEvent(w http.ResponseWriter, event stream.Event) error {
+	data, err := json.Marshal(event.Data)
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", event.ID, event.Type, data)
+
+	return err
+}
+
+func parseLastEventId(r *http.Request) (int64, error) {
+	raw := r.Header.Get("Last-Event-ID")
+	if raw == "" {
+		raw = r.URL.Query().Get("last_event_id")
+	}
+
+	if raw == "" {
+		return 0, nil
+	}
+
+	id, err := strconv.ParseInt(raw, 10, 64)
+	if err != nil || id < 0 {
+		return 0, errors.New("invalid last event id")
+	}
+
+	return id, nil
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/AlieNoori/social/internal/stream"
+)
+
+func TestStreamHandler(t *testing.T) {
+	app := NewTestApplication(t, config{stream: streamConfig{heartbeat: time.Minute}})
+	ts := httptest.NewServer(app.mount())
+	defer ts.Close()
+
+	testToken, err := app.authenticator.GenerateToken(nil)
+	if err != nil {
+		t.Fatalf("error: %s\n", err.Error())
+	}
+
+	// the test authenticator always authenticates user 205
+	const userId = 205
+	ctx := context.Background()
+
+	t.Run("should reject an invalid last event id", func(t *testing.T) {
+		req, _ := http.NewRequest(http.MethodGet, ts.URL+"/v1/stream?last_event_id=abc", nil)
+		req.Header.Set("Autorization", "Bearer "+testToken)
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("error: %s\n", err.Error())
+		}
+		resp.Body.Close()
+
+		checkResponse(t, http.StatusBadRequest, resp.StatusCode)
+	})
+
+	t.Run("should resume after the last event and stream new ones", func(t *testing.T) {
+		for _, id := range []int{1, 2} {
+			if err := app.broker.Publish(ctx, []int{userId}, stream.EventPost, map[string]int{"id": id}); err != nil {
+				t.Fatalf("error: %s\n", err.Error())
+			}
+		}
+
+		req, _ := http.NewRequest(http.MethodGet, ts.URL+"/v1/stream", nil)
+		req.Header.Set("Autorization", "Bearer "+testToken)
+		req.Header.Set("Last-Event-ID", "1")
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("error: %s\n", err.Error())
+		}
+		defer resp.Body.Close()
+
+		checkResponse(t, http.StatusOK, resp.StatusCode)
+		if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+			t.Fatalf("expected an event stream and got %q", ct)
+		}
+
+		reader := bufio.NewReader(resp.Body)
+
+		if got := readEvent(t, reader); got != "id: 2\nevent: post\ndata: {\"id\":2}\n" {
+			t.Errorf("unexpected replayed event %q", got)
+		}
+
+		if err := app.broker.Publish(ctx, []int{userId}, stream.EventNotification, map[string]int{"id": 3}); err != nil {
+			t.Fatalf("error: %s\n", err.Error())
+		}
+
+		if got := readEvent(t, reader); got != "id: 3\nevent: notification\ndata: {\"id\":3}\n" {
+			t.Errorf("unexpected live event %q", got)
+		}
+
+		app.broker.Close()
+
+		if _, err := reader.ReadString('\n'); err == nil {
+			t.Error("expected the stream to end when the broker is closed")
+		}
+	})
+}
+
+// readEvent returns the next event of the stream, skipping the retry hint
+// and comments.
+func readEvent(t *testing.T, reader *bufio.Reader) string {
+	t.Helper()
+
+	var event strings.Builder
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			t.Fatalf("error: %s\n", err.Error())
+		}
+
+		if line == "\n" {
+			if event.Len() > 0 {
+				return event.String()
+			}
+			continue
+		}
+
+		if strings.HasPrefix(line, "retry:") || strings.HasPrefix(line, ":") {
+			continue
+		}
+
+		event.WriteString(line)
+	}
+}
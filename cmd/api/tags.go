@@ -0,0 +1,161 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/AlieNoori/social/internal/store"
+	"github.com/go-chi/chi/v5"
+)
+
+// trendingTagsLimit is the number of tags listed per trending window.
+const trendingTagsLimit = 10
+
+// GetTagPosts godoc
+//
+//	@Summary		Fetches the posts of a tag
+//	@Description	Fetches a page of the posts with a tag, newest first. Without a token only public posts are listed
+//	@Tags			tags
+//	@Accept			json
+//	@Produce		json
+//	@Param			tag		path		string	true	"Tag"
+//	@Param			limit	query		int		false	"Limit"
+//	@Param			cursor	query		string	false	"Cursor of the next page"
+//	@Success		200		{object}	[]store.PostWithMetadata
+//	@Failure		400		{object}	error
+//	@Failure		500		{object}	error
+//	@Router			/tags/{tag}/posts [get]
+func (app *application) getTagPostsHandler(w http.ResponseWriter, r *http.Request) {
+	tags := store.NormalizeTags([]string{chi.URLParam(r, "tag")})
+	if len(tags) == 0 {
+		app.badRequestResponse(w, r, errors.New("invalid tag"))
+		return
+	}
+
+	limit := 20
+	var cursor *store.FeedCursor
+	qv := r.URL.Query()
+
+	if v := qv.Get("limit"); v != "" {
+		l, err := strconv.Atoi(v)
+		if err != nil || l < 1 || l > 20 {
+			app.badRequestResponse(w, r, errors.New("limit must be between 1 and 20"))
+			return
+		}
+		limit = l
+	}
+
+	if v := qv.Get("cursor"); v != "" {
+		c, err := store.DecodeFeedCursor(v)
+		if err != nil {
+			app.badRequestResponse(w, r, err)
+			return
+		}
+		cursor = c
+	}
+
+	// anonymous viewers have no id, so visibleTo only lets public posts through
+	viewerId := 0
+	if user, ok := r.Context().Value(userCtxKey).(*store.User); ok {
+		viewerId = user.ID
+	}
+
+	posts, err := app.store.Posts.GetByTag(r.Context(), viewerId, tags[0], cursor, limit)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	var nextCursor *string
+	if len(posts) == limit {
+		last := posts[len(posts)-1]
+		c := store.FeedCursor{CreatedAt: last.CreatedAt, ID: last.ID}.Encode()
+		nextCursor = &c
+	}
+
+	if err := app.writePaginatedResponse(w, http.StatusOK, posts, nextCursor); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// GetTrendingTags godoc
+//
+//	@Summary		Fetches the trending tags
+//	@Description	Fetches the tags that grew the most during the window compared to the window before it
+//	@Tags			tags
+//	@Accept			json
+//	@Produce		json
+//	@Param			window	query		string	false	"Window, 1h or 24h"
+//	@Success		200		{object}	[]store.TrendingTag
+//	@Failure		400		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/tags/trending [get]
+func (app *application) getTrendingTagsHandler(w http.ResponseWriter, r *http.Request) {
+	window := 24 * time.Hour
+	if v := r.URL.Query().Get("window"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || !slices.Contains(app.config.trending.windows, d) {
+			app.badRequestResponse(w, r, fmt.Errorf("unsupported window %q", v))
+			return
+		}
+		window = d
+	}
+
+	tags, err := app.getTrendingTags(r.Context(), window)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.writeResponse(w, http.StatusOK, tags); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// getTrendingTags serves the trending tags from the cache kept warm by the
+// refresh job, computing them when they are not cached yet.
+func (app *application) getTrendingTags(ctx context.Context, window time.Duration) ([]store.TrendingTag, error) {
+	if !app.config.redisCfg.enabled {
+		return app.store.Posts.GetTrendingTags(ctx, window, trendingTagsLimit)
+	}
+
+	tags, err := app.cacheStore.Trending.Get(ctx, window)
+	if err != nil {
+		app.logger.Errorw("error reading cached trending tags", "window", window, "error", err)
+	}
+	if tags != nil {
+		return tags, nil
+	}
+
+	tags, err = app.store.Posts.GetTrendingTags(ctx, window, trendingTagsLimit)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := app.cacheStore.Trending.Set(ctx, window, tags); err != nil {
+		app.logger.Errorw("error caching trending tags", "window", window, "error", err)
+	}
+
+	return tags, nil
+}
+
+func (app *application) refreshTrendingTags(ctx context.Context) error {
+	for _, window := range app.config.trending.windows {
+		tags, err := app.store.Posts.GetTrendingTags(ctx, window, trendingTagsLimit)
+		if err != nil {
+			return err
+		}
+
+		if err := app.cacheStore.Trending.Set(ctx, window, tags); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/AlieNoori/social/internal/store"
+)
+
+type viewerPostStore struct {
+	store.MockPostStore
+	viewerId int
+}
+
+func (s *viewerPostStore) GetByTag(_ context.Context, viewerId int, _ string, _ *store.FeedCursor, _ int) ([]store.PostWithMetadata, error) {
+	s.viewerId = viewerId
+	return nil, nil
+}
+
+func TestTagsHandlers(t *testing.T) {
+	app := NewTestApplication(t, config{
+		trending: trendingConfig{windows: []time.Duration{time.Hour, time.Hour * 24}},
+	})
+	mux := app.mount()
+	testToken, err := app.authenticator.GenerateToken(nil)
+	if err != nil {
+		t.Fatalf("error: %s\n", err.Error())
+	}
+
+	for url, want := range map[string]int{
+		"/v1/tags/trending":           http.StatusOK,
+		"/v1/tags/trending?window=1h": http.StatusOK,
+		"/v1/tags/trending?window=7h": http.StatusBadRequest,
+		"/v1/tags/GoLang/posts":       http.StatusOK,
+		"/v1/tags/%23/posts":          http.StatusBadRequest,
+		"/v1/tags/go/posts?limit=50":  http.StatusBadRequest,
+	} {
+		t.Run(url, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodGet, "http://localhost:8080"+url, nil)
+			if err != nil {
+				t.Fatalf("error: %s\n", err.Error())
+			}
+
+			req.Header.Set("Autorization", "Bearer "+testToken)
+
+			rr := executeRequest(req, mux)
+
+			checkResponse(t, want, rr.Code)
+		})
+	}
+}
+
+func TestTagPostsViewer(t *testing.T) {
+	app := NewTestApplication(t, config{})
+	posts := &viewerPostStore{viewerId: -1}
+	app.store.Posts = posts
+	mux := app.mount()
+
+	t.Run("anonymous viewers get the public timeline", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodGet, "http://localhost:8080/v1/tags/go/posts", nil)
+		if err != nil {
+			t.Fatalf("error: %s\n", err.Error())
+		}
+
+		rr := executeRequest(req, mux)
+
+		checkResponse(t, http.StatusOK, rr.Code)
+		if posts.viewerId != 0 {
+			t.Errorf("expected viewer 0, got %d", posts.viewerId)
+		}
+	})
+
+	t.Run("authenticated viewers see what they may see", func(t *testing.T) {
+		testToken, err := app.authenticator.GenerateToken(nil)
+		if err != nil {
+			t.Fatalf("error: %s\n", err.Error())
+		}
+
+		req, err := http.NewRequest(http.MethodGet, "http://localhost:8080/v1/tags/go/posts", nil)
+		if err != nil {
+			t.Fatalf("error: %s\n", err.Error())
+		}
+		req.Header.Set("Autorization", "Bearer "+testToken)
+
+		rr := executeRequest(req, mux)
+
+		checkResponse(t, http.StatusOK, rr.Code)
+		if posts.viewerId == 0 {
+			t.Errorf("expected the authenticated viewer, got 0")
+		}
+	})
+
+	t.Run("invalid tokens are still rejected", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodGet, "http://localhost:8080/v1/tags/go/posts", nil)
+		if err != nil {
+			t.Fatalf("error: %s\n", err.Error())
+		}
+		req.Header.Set("Autorization", "Bearer invalid")
+
+		rr := executeRequest(req, mux)
+
+		checkResponse(t, http.StatusUnauthorized, rr.Code)
+	})
+}
@@ -4,11 +4,14 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/AlieNoori/social/internal/auth"
 	"github.com/AlieNoori/social/internal/ratelimiter"
 	"github.com/AlieNoori/social/internal/store"
 	"github.com/AlieNoori/social/internal/store/cache"
+	"github.com/AlieNoori/social/internal/stream"
+	"github.com/AlieNoori/social/internal/webhook"
 	"go.uber.org/zap"
 )
 
@@ -34,6 +37,8 @@ func NewTestApplication(t *testing.T, cfg config) *application {
 		cacheStore:    mockCacheStore,
 		rateLimiter:   rateLimiter,
 		authenticator: testAuth,
+		broker:        stream.NewMemoryBroker(),
+		webhookSender: webhook.NewSender(time.Second),
 	}
 }
 
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"context"
+
+	"github.com/AlieNoori/social/internal/store"
+	"github.com/AlieNoori/social/internal/store/cache"
+	"github.com/AlieNoori/social/internal/stream"
+)
+
+// fanOutPost pushes a new post to the cached timelines and the connected
+// clients of its author and of their followers. Authors above the celebrity
+// threshold are not fanned out, their followers read the feed from the
+// database instead.
+func (app *application) fanOutPost(ctx context.Context, post *store.Post) error {
+	entry := cache.TimelineEntry{PostId: post.ID, CreatedAt: post.CreatedAt}
+	userIds := []int{post.UserId}
+
+	followers, err := app.store.Followers.CountFollowers(ctx, post.UserId)
+	if err != nil {
+		return err
+	}
+
+	if followers <= app.config.timeline.celebrityThreshold {
+		ids, err := app.store.Followers.GetFollowerIds(ctx, post.UserId)
+		if err != nil {
+			return err
+		}
+		userIds = append(userIds, ids...)
+	}
+
+	if err := app.broker.Publish(ctx, userIds, stream.EventPost, post); err != nil {
+		return err
+	}
+
+	if !app.config.redisCfg.enabled {
+		return nil
+	}
+
+	return app.cacheStore.Timelines.Push(ctx, userIds, entry)
+}
+
+// getCachedFeed serves a page of the feed from the cached timeline. ok is
+// false when the page has to be read from the database, either because the
+// query is not a plain newest first page, the user follows a celebrity or
+// the timeline is not cached.
+func (app *application) getCachedFeed(ctx context.Context, userId int, fq store.PaginatedFeedQeury) ([]store.PostWithMetadata, *string, bool) {
+	if !app.config.redisCfg.enabled ||
+		fq.Sort != "desc" || fq.Search != "" || len(fq.Tags) > 0 ||
+		fq.Since != nil || fq.Until != nil {
+		return nil, nil, false
+	}
+
+	popular, err := app.store.Followers.FollowsPopular(ctx, userId, app.config.timeline.celebrityThreshold)
+	if err != nil {
+		app.logger.Errorw("error checking followed users", "user", userId, "error", err)
+		return nil, nil, false
+	}
+	if popular {
+		return nil, nil, false
+	}
+
+	entries, ok, err := app.cacheStore.Timelines.Get(ctx, userId, fq.Cursor, fq.Offset, fq.Limit)
+	if err != nil {
+		app.logger.Errorw("error reading cached timeline", "user", userId, "error", err)
+		return nil, nil, false
+	}
+	if !ok {
+		app.warmTimeline(userId)
+		return nil, nil, false
+	}
+
+	ids := make([]int, len(entries))
+	for i, entry := range entries {
+		ids[i] = entry.PostId
+	}
+
+	feed, err := app.store.Posts.GetByIds(ctx, userId, ids)
+	if err != nil {
+		app.logger.Errorw("error hydrating cached timeline", "user", userId, "error", err)
+		return nil, nil, false
+	}
+
+	// the cursor comes from the timeline rather than the hydrated posts so
+	// deleted posts do not end the pagination early
+	var nextCursor *string
+	if len(entries) == fq.Limit {
+		last := entries[len(entries)-1]
+		cursor := store.FeedCursor{CreatedAt: last.CreatedAt, ID: last.PostId}.Encode()
+		nextCursor = &cursor
+	}
+
+	return feed, nextCursor, true
+}
+
+// warmTimeline rebuilds the cached timeline of a user from the database.
+func (app *application) warmTimeline(userId int) {
+	app.jobs.Go(func() {
+		ctx := context.Background()
+
+		feed, err := app.store.Posts.GetUserFeed(ctx, userId, store.PaginatedFeedQeury{
+			Limit: cache.TimelineMaxLen,
+			Sort:  "desc",
+		})
+		if err != nil {
+			app.logger.Errorw("error warming timeline", "user", userId, "error", err)
+			return
+		}
+
+		entries := make([]cache.TimelineEntry, len(feed))
+		for i, post := range feed {
+			entries[i] = cache.TimelineEntry{PostId: post.ID, CreatedAt: post.CreatedAt}
+		}
+
+		if err := app.cacheStore.Timelines.Set(ctx, userId, entries); err != nil {
+			app.logger.Errorw("error warming timeline", "user", userId, "error", err)
+		}
+	})
+}
+
+// invalidateTimeline drops the cached timeline of a user after the set of
+// users they follow changed, it is rebuilt on the next read.
+func (app *application) invalidateTimeline(ctx context.Context, userId int) {
+	if !app.config.redisCfg.enabled {
+		return
+	}
+
+	if err := app.cacheStore.Timelines.Delete(ctx, userId); err != nil {
+		app.logger.Errorw("error invalidating timeline", "user", userId, "error", err)
+	}
+}
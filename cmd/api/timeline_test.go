@@ -0,0 +1,216 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/AlieNoori/social/internal/store"
+	"github.com/AlieNoori/social/internal/store/cache"
+)
+
+// recordingTimelineStore records the changes to cached timelines and serves
+// the timelines it holds.
+type recordingTimelineStore struct {
+	cache.MockTimelineStore
+	mu        sync.Mutex
+	timelines map[int][]cache.TimelineEntry
+	pushed    []int
+	reads     int
+	deleted   []int
+}
+
+func (s *recordingTimelineStore) Get(_ context.Context, userId int, _ *store.FeedCursor, _, _ int) ([]cache.TimelineEntry, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.reads++
+	entries, ok := s.timelines[userId]
+
+	return entries, ok, nil
+}
+
+func (s *recordingTimelineStore) Set(_ context.Context, userId int, entries []cache.TimelineEntry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.timelines[userId] = entries
+
+	return nil
+}
+
+func (s *recordingTimelineStore) Push(_ context.Context, userIds []int, _ cache.TimelineEntry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.pushed = append(s.pushed, userIds...)
+
+	return nil
+}
+
+func (s *recordingTimelineStore) Delete(_ context.Context, userId int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.deleted = append(s.deleted, userId)
+	delete(s.timelines, userId)
+
+	return nil
+}
+
+// countingFollowerStore gives every author the same followers and reports
+// whether the users follow someone above the celebrity threshold.
+type countingFollowerStore struct {
+	store.MockFollowerStore
+	followerIds []int
+	popular     bool
+}
+
+func (s *countingFollowerStore) GetFollowerIds(context.Context, int) ([]int, error) {
+	return s.followerIds, nil
+}
+
+func (s *countingFollowerStore) CountFollowers(context.Context, int) (int, error) {
+	return len(s.followerIds), nil
+}
+
+func (s *countingFollowerStore) FollowsPopular(context.Context, int, int) (bool, error) {
+	return s.popular, nil
+}
+
+func newTimelineTestApplication(t *testing.T, celebrityThreshold int) (*application, *recordingTimelineStore, *countingFollowerStore) {
+	t.Helper()
+
+	app := NewTestApplication(t, config{
+		redisCfg: redisConfig{enabled: true},
+		timeline: timelineConfig{celebrityThreshold: celebrityThreshold},
+	})
+
+	timelines := &recordingTimelineStore{timelines: make(map[int][]cache.TimelineEntry)}
+	followers := &countingFollowerStore{followerIds: []int{2, 3}}
+	app.cacheStore.Timelines = timelines
+	app.store.Followers = followers
+
+	return app, timelines, followers
+}
+
+func TestFanOutPost(t *testing.T) {
+	post := &store.Post{ID: 10, UserId: 1, CreatedAt: time.Now()}
+
+	t.Run("should push the post to the author and their followers", func(t *testing.T) {
+		app, timelines, _ := newTimelineTestApplication(t, 2)
+
+		if err := app.fanOutPost(context.Background(), post); err != nil {
+			t.Fatalf("error: %s\n", err.Error())
+		}
+
+		if want := []int{1, 2, 3}; !slices.Equal(timelines.pushed, want) {
+			t.Errorf("expected the post to be pushed to %v and got %v", want, timelines.pushed)
+		}
+	})
+
+	t.Run("should only push the post of a celebrity to the author", func(t *testing.T) {
+		app, timelines, _ := newTimelineTestApplication(t, 1)
+
+		if err := app.fanOutPost(context.Background(), post); err != nil {
+			t.Fatalf("error: %s\n", err.Error())
+		}
+
+		if want := []int{1}; !slices.Equal(timelines.pushed, want) {
+			t.Errorf("expected the post to be pushed to %v and got %v", want, timelines.pushed)
+		}
+	})
+}
+
+func TestGetCachedFeed(t *testing.T) {
+	ctx := context.Background()
+	fq := store.PaginatedFeedQeury{Limit: 20, Sort: "desc"}
+
+	t.Run("should serve a cached timeline", func(t *testing.T) {
+		app, timelines, _ := newTimelineTestApplication(t, 10)
+		timelines.timelines[205] = []cache.TimelineEntry{{PostId: 10, CreatedAt: time.Now()}}
+
+		if _, _, ok := app.getCachedFeed(ctx, 205, fq); !ok {
+			t.Error("expected the feed to be served from the cache")
+		}
+	})
+
+	t.Run("should read the feed from the database when following a celebrity", func(t *testing.T) {
+		app, timelines, followers := newTimelineTestApplication(t, 10)
+		followers.popular = true
+		timelines.timelines[205] = []cache.TimelineEntry{{PostId: 10, CreatedAt: time.Now()}}
+
+		if _, _, ok := app.getCachedFeed(ctx, 205, fq); ok {
+			t.Error("expected the feed not to be served from the cache")
+		}
+
+		if timelines.reads != 0 {
+			t.Errorf("expected the cached timeline not to be read and got %d reads", timelines.reads)
+		}
+	})
+
+	t.Run("should cache an empty timeline after a miss", func(t *testing.T) {
+		app, timelines, _ := newTimelineTestApplication(t, 10)
+
+		if _, _, ok := app.getCachedFeed(ctx, 205, fq); ok {
+			t.Fatal("expected a miss on a timeline that is not cached")
+		}
+		app.jobs.Wait()
+
+		if entries, ok := timelines.timelines[205]; !ok || len(entries) != 0 {
+			t.Fatalf("expected an empty timeline to be cached and got %v", entries)
+		}
+
+		if _, _, ok := app.getCachedFeed(ctx, 205, fq); !ok {
+			t.Error("expected the empty timeline to be served from the cache")
+		}
+	})
+}
+
+func TestInvalidateTimeline(t *testing.T) {
+	app, timelines, _ := newTimelineTestApplication(t, 10)
+	mux := app.mount()
+
+	testToken, err := app.authenticator.GenerateToken(nil)
+	if err != nil {
+		t.Fatalf("error: %s\n", err.Error())
+	}
+
+	t.Run("should drop the timeline of a user after unfollowing", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodPut, "http://localhost:8080/v1/users/190/unfollow", nil)
+		if err != nil {
+			t.Fatalf("error: %s\n", err.Error())
+		}
+
+		req.Header.Set("Autorization", "Bearer "+testToken)
+
+		rr := executeRequest(req, mux)
+
+		checkResponse(t, http.StatusNoContent, rr.Code)
+
+		if want := []int{205}; !slices.Equal(timelines.deleted, want) {
+			t.Errorf("expected the timelines of %v to be dropped and got %v", want, timelines.deleted)
+		}
+	})
+
+	t.Run("should drop the timeline of the follower on a follow event", func(t *testing.T) {
+		timelines.deleted = nil
+
+		event := store.OutboxEvent{
+			Event:   store.OutboxUserFollowed,
+			Payload: json.RawMessage(`{"follower_id":205,"followed_id":190}`),
+		}
+
+		if err := app.invalidateFollowerTimeline(context.Background(), event); err != nil {
+			t.Fatalf("error: %s\n", err.Error())
+		}
+
+		if want := []int{205}; !slices.Equal(timelines.deleted, want) {
+			t.Errorf("expected the timelines of %v to be dropped and got %v", want, timelines.deleted)
+		}
+	})
+}
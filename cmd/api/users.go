@@ -1,11 +1,13 @@
 package main
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
 
 	"github.com/AlieNoori/social/internal/store"
 	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
 )
 
 type userKey string
@@ -15,7 +17,7 @@ const userCtxKey userKey = "user"
 // GetUser godoc
 //
 //	@Summary		Fetches a user profile
-//	@Description	Fetches a user profile by ID
+//	@Description	Fetches a user profile by ID with its follower, following and post counts, and how it relates to the authenticated user
 //	@Tags			users
 //	@Accept			json
 //	@Produce		json
@@ -37,26 +39,111 @@ func (app *application) getUserHandler(w http.ResponseWriter, r *http.Request) {
 
 	user, err := app.getUser(ctx, userId)
 	if err != nil {
-		app.badRequestResponse(w, r, err)
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	// counts change too often to be cached with the user, they are read on
+	// every profile view
+	user.Counts, err = app.store.Users.GetCounts(ctx, user.ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
 		return
 	}
 
+	if viewer := getUserFromCtx(r); viewer.ID != user.ID {
+		user.Relationship, err = app.store.Followers.GetRelationship(ctx, viewer.ID, user.ID)
+		if err != nil {
+			app.internalServerError(w, r, err)
+			return
+		}
+	}
+
 	if err := app.writeResponse(w, http.StatusOK, user); err != nil {
 		app.internalServerError(w, r, err)
 	}
 }
 
+type UpdateSettingsPayload struct {
+	IsPrivate *bool   `json:"is_private"`
+	Language  *string `json:"language" validate:"omitempty,bcp47_language_tag,max=35"`
+}
+
+// UpdateSettings godoc
+//
+//	@Summary		Updates the account settings
+//	@Description	Updates the settings of the authenticated user, making an account public approves its pending follow requests and the language is used for emails
+//	@Tags			users
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		UpdateSettingsPayload	true	"Settings payload"
+//	@Success		204		{string}	string					"Settings updated"
+//	@Failure		400		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/settings [patch]
+func (app *application) updateSettingsHandler(w http.ResponseWriter, r *http.Request) {
+	var payload UpdateSettingsPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if payload.IsPrivate == nil && payload.Language == nil {
+		app.badRequestResponse(w, r, errors.New("no settings to update"))
+		return
+	}
+
+	user := getUserFromCtx(r)
+	ctx := r.Context()
+
+	if payload.Language != nil {
+		if err := app.store.Users.SetLanguage(ctx, user.ID, *payload.Language); err != nil {
+			app.internalServerError(w, r, err)
+			return
+		}
+	}
+
+	if payload.IsPrivate != nil {
+		if err := app.store.Users.SetPrivate(ctx, user.ID, *payload.IsPrivate); err != nil {
+			app.internalServerError(w, r, err)
+			return
+		}
+	}
+
+	if app.config.redisCfg.enabled {
+		if err := app.cacheStore.Users.Delete(ctx, user.ID); err != nil {
+			app.logger.Errorw("error invalidating cached user", "user", user.ID, "error", err)
+		}
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
 // FollowUser godoc
 //
 //	@Summary		Follows a user
-//	@Description	Follows a user by ID
+//	@Description	Follows a user by ID, private users receive a follow request to approve instead
 //	@Tags			users
 //	@Accept			json
 //	@Produce		json
 //	@Param			userID	path		int		true	"User ID"
+//	@Success		202		{string}	string	"Follow requested"
 //	@Success		204		{string}	string	"User followed"
 //	@Failure		400		{object}	error	"User payload missing"
+//	@Failure		403		{object}	error	"User blocked"
 //	@Failure		404		{object}	error	"User not found"
+//	@Failure		409		{object}	error	"Already followed or requested"
 //	@Security		ApiKeyAuth
 //	@Router			/users/{userID}/follow [put]
 func (app *application) followUserHandler(w http.ResponseWriter, r *http.Request) {
@@ -64,13 +151,47 @@ func (app *application) followUserHandler(w http.ResponseWriter, r *http.Request
 	followedId, err := strconv.Atoi(chi.URLParam(r, "userID"))
 	if err != nil {
 		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+
+	followed, err := app.getUser(ctx, followedId)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if followed.IsPrivate && followed.ID != followerUser.ID {
+		if err := app.store.Followers.RequestFollow(ctx, followerUser.ID, followed.ID); err != nil {
+			switch err {
+			case store.ErrConflict:
+				app.conflictResponse(w, r, err)
+			case store.ErrBlocked:
+				app.forbiddenResponse(w, r)
+			default:
+				app.internalServerError(w, r, err)
+			}
+			return
+		}
+
+		w.WriteHeader(http.StatusAccepted)
+		return
 	}
 
-	if err := app.store.Followers.Follow(r.Context(), followerUser.ID, followedId); err != nil {
+	if err := app.store.Followers.Follow(ctx, followerUser.ID, followedId); err != nil {
 		switch err {
 		case store.ErrConflict:
 			app.conflictResponse(w, r, err)
 			return
+		case store.ErrBlocked:
+			app.forbiddenResponse(w, r)
+			return
 		default:
 			app.internalServerError(w, r, err)
 			return
@@ -85,7 +206,7 @@ func (app *application) followUserHandler(w http.ResponseWriter, r *http.Request
 // UnfollowUser gdoc
 //
 //	@Summary		Unfollow a user
-//	@Description	Unfollow a user by ID
+//	@Description	Unfollow a user by ID, or cancel the pending follow request
 //	@Tags			users
 //	@Accept			json
 //	@Produce		json
@@ -100,6 +221,7 @@ func (app *application) unfollowUserHandler(w http.ResponseWriter, r *http.Reque
 	unfollowedId, err := strconv.Atoi(chi.URLParam(r, "userID"))
 	if err != nil {
 		app.badRequestResponse(w, r, err)
+		return
 	}
 
 	if err := app.store.Followers.Unfollow(r.Context(), followerUser.ID, unfollowedId); err != nil {
@@ -107,6 +229,8 @@ func (app *application) unfollowUserHandler(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
+	app.invalidateTimeline(r.Context(), followerUser.ID)
+
 	if err := app.writeResponse(w, http.StatusNoContent, nil); err != nil {
 		app.internalServerError(w, r, err)
 	}
@@ -127,7 +251,7 @@ func (app *application) unfollowUserHandler(w http.ResponseWriter, r *http.Reque
 func (app *application) activateUserHandler(w http.ResponseWriter, r *http.Request) {
 	token := chi.URLParam(r, "token")
 
-	if err := app.store.Users.Activate(r.Context(), token); err != nil {
+	if _, err := app.store.Users.Activate(r.Context(), token); err != nil {
 		switch err {
 		case store.ErrNotFound:
 			app.notFoundResponse(w, r, err)
@@ -143,6 +267,51 @@ func (app *application) activateUserHandler(w http.ResponseWriter, r *http.Reque
 	}
 }
 
+type ResendActivationPayload struct {
+	Email string `json:"email" validate:"required,email,max=250"`
+}
+
+// ResendActivation godoc
+//
+//	@Summary		Resends the activation email
+//	@Description	Issues a new invitation for an inactive account, invalidating older ones
+//	@Tags			users
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		ResendActivationPayload	true	"User email"
+//	@Success		202		{string}	string					"Invitation resent"
+//	@Failure		400		{object}	error
+//	@Failure		500		{object}	error
+//	@Router			/users/activate/resend [post]
+func (app *application) resendActivationHandler(w http.ResponseWriter, r *http.Request) {
+	var payload ResendActivationPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	plainToken := uuid.New().String()
+
+	// the email is queued along with the invitation and sent in the background
+	if _, err := app.store.Users.ReissueInvitation(r.Context(), payload.Email, plainToken, app.config.mail.exp, app.invitationEmail(plainToken)); err != nil {
+		// unknown and already activated accounts get the same response
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			w.WriteHeader(http.StatusAccepted)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
 // func (app *application) userContextMiaddleWare(next http.Handler) http.Handler {
 // 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 // 		idParam := chi.URLParam(r, "userID")
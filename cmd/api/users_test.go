@@ -1,10 +1,56 @@
 package main
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
 	"net/http"
 	"testing"
+	"time"
+
+	"github.com/AlieNoori/social/internal/auth"
+	"github.com/AlieNoori/social/internal/store"
+	"github.com/golang-jwt/jwt/v5"
 )
 
+func TestTokenAuthMiddleware(t *testing.T) {
+	app := NewTestApplication(t, config{})
+	app.authenticator = auth.NewJWTAuthenticator("secret", "gophersocial", "gophersocial")
+	mux := app.mount()
+
+	t.Run("should reject tokens without a valid session id", func(t *testing.T) {
+		for _, sid := range []any{nil, "", "not-a-uuid", 42} {
+			claims := jwt.MapClaims{
+				"sub": 205,
+				"exp": time.Now().Add(time.Hour).Unix(),
+				"iat": time.Now().Unix(),
+				"nbf": time.Now().Unix(),
+				"iss": "gophersocial",
+				"aud": "gophersocial",
+			}
+			if sid != nil {
+				claims["sid"] = sid
+			}
+
+			token, err := app.authenticator.GenerateToken(claims)
+			if err != nil {
+				t.Fatalf("error: %s\n", err.Error())
+			}
+
+			req, err := http.NewRequest(http.MethodGet, "http://localhost:8080/v1/users/190", nil)
+			if err != nil {
+				t.Fatalf("error: %s\n", err.Error())
+			}
+
+			req.Header.Set("Autorization", "Bearer "+token)
+
+			rr := executeRequest(req, mux)
+
+			checkResponse(t, http.StatusUnauthorized, rr.Code)
+		}
+	})
+}
+
 func TestGetUserHandler(t *testing.T) {
 	withRedis := config{
 		redisCfg: redisConfig{
@@ -43,5 +89,123 @@ func TestGetUserHandler(t *testing.T) {
 		rr := executeRequest(req, mux)
 
 		checkResponse(t, http.StatusOK, rr.Code)
+
+		var body struct {
+			Data struct {
+				Counts       *struct{} `json:"counts"`
+				Relationship *struct{} `json:"relationship"`
+			} `json:"data"`
+		}
+		if err := json.NewDecoder(rr.Body).Decode(&body); err != nil {
+			t.Fatalf("error: %s\n", err.Error())
+		}
+
+		if body.Data.Counts == nil || body.Data.Relationship == nil {
+			t.Errorf("expected the profile to have counts and a relationship")
+		}
+	})
+}
+
+func TestGetFollowersHandler(t *testing.T) {
+	app := NewTestApplication(t, config{})
+	mux := app.mount()
+	testToken, err := app.authenticator.GenerateToken(nil)
+	if err != nil {
+		t.Fatalf("error: %s\n", err.Error())
+	}
+
+	for _, list := range []string{"followers", "following"} {
+		t.Run("should list "+list, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodGet, "http://localhost:8080/v1/users/190/"+list, nil)
+			if err != nil {
+				t.Fatalf("error: %s\n", err.Error())
+			}
+
+			req.Header.Set("Autorization", "Bearer "+testToken)
+
+			rr := executeRequest(req, mux)
+
+			checkResponse(t, http.StatusOK, rr.Code)
+		})
+	}
+
+	t.Run("should reject an invalid cursor", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodGet, "http://localhost:8080/v1/users/190/followers?cursor=nope", nil)
+		if err != nil {
+			t.Fatalf("error: %s\n", err.Error())
+		}
+
+		req.Header.Set("Autorization", "Bearer "+testToken)
+
+		rr := executeRequest(req, mux)
+
+		checkResponse(t, http.StatusBadRequest, rr.Code)
+	})
+}
+
+// inviteUserStore only reissues invitations for its inactive users.
+type inviteUserStore struct {
+	store.MockUserStore
+	inactive map[string]*store.User
+	queued   []*store.QueuedEmail
+}
+
+func (s *inviteUserStore) ReissueInvitation(_ context.Context, email, _ string, _ time.Duration, invitationEmail store.EmailFunc) (*store.User, error) {
+	user, ok := s.inactive[email]
+	if !ok {
+		return nil, store.ErrNotFound
+	}
+
+	queued, err := invitationEmail(user)
+	if err != nil {
+		return nil, err
+	}
+	s.queued = append(s.queued, queued)
+
+	return user, nil
+}
+
+func TestResendActivationHandler(t *testing.T) {
+	app := NewTestApplication(t, config{})
+	users := &inviteUserStore{
+		inactive: map[string]*store.User{"pending@example.com": {ID: 1, UserName: "pending", Email: "pending@example.com"}},
+	}
+	app.store.Users = users
+	mux := app.mount()
+
+	resend := func(t *testing.T, email string) int {
+		t.Helper()
+
+		body, err := json.Marshal(ResendActivationPayload{Email: email})
+		if err != nil {
+			t.Fatalf("error: %s\n", err.Error())
+		}
+
+		req, err := http.NewRequest(http.MethodPost, "http://localhost:8080/v1/users/activate/resend", bytes.NewReader(body))
+		if err != nil {
+			t.Fatalf("error: %s\n", err.Error())
+		}
+
+		return executeRequest(req, mux).Code
+	}
+
+	t.Run("should accept an unknown or active account without an email", func(t *testing.T) {
+		checkResponse(t, http.StatusAccepted, resend(t, "active@example.com"))
+
+		if len(users.queued) != 0 {
+			t.Errorf("expected no email and got %d", len(users.queued))
+		}
+	})
+
+	t.Run("should email a new invitation to an inactive account", func(t *testing.T) {
+		checkResponse(t, http.StatusAccepted, resend(t, "pending@example.com"))
+
+		if len(users.queued) != 1 || users.queued[0].Email != "pending@example.com" {
+			t.Errorf("expected one email to pending@example.com and got %+v", users.queued)
+		}
+	})
+
+	t.Run("should reject an invalid email", func(t *testing.T) {
+		checkResponse(t, http.StatusBadRequest, resend(t, "pending"))
 	})
 }
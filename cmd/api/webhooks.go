@@ -0,0 +1,396 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/AlieNoori/social/internal/store"
+	"github.com/AlieNoori/social/internal/webhook"
+	"github.com/go-chi/chi/v5"
+)
+
+type webhookKey string
+
+const webhookCtxKey webhookKey = "webhook"
+
+type CreateWebhookPayload struct {
+	URL    string   `json:"url" validate:"required,http_url,max=2048"`
+	Events []string `json:"events" validate:"required,min=1"`
+	Active *bool    `json:"active"`
+}
+
+type UpdateWebhookPayload struct {
+	URL    *string   `json:"url" validate:"omitempty,http_url,max=2048"`
+	Events *[]string `json:"events" validate:"omitempty,min=1"`
+	Active *bool     `json:"active"`
+}
+
+// webhookEnvelope is the body of a delivery. The ID identifies the delivery
+// across retries so receivers can ignore duplicates.
+type webhookEnvelope struct {
+	ID        int             `json:"id"`
+	Event     string          `json:"event"`
+	CreatedAt time.Time       `json:"created_at"`
+	Data      json.RawMessage `json:"data"`
+}
+
+// deliverWebhooks sends a batch of due deliveries. Failed deliveries are
+// retried with an exponential backoff until they run out of attempts.
+func (app *application) deliverWebhooks(ctx context.Context) error {
+	// a claimed delivery is picked up again if it is not settled before the
+	// sender times out with some margin
+	lease := app.config.webhooks.timeout + time.Minute
+
+	deliveries, err := app.store.Webhooks.ClaimDue(ctx, app.config.webhooks.batchSize, lease)
+	if err != nil {
+		return err
+	}
+
+	var wg sync.WaitGroup
+	for _, d := range deliveries {
+		wg.Add(1)
+		go func(d store.DueDelivery) {
+			defer wg.Done()
+
+			if err := app.deliverWebhook(ctx, d); err != nil {
+				app.logger.Errorw("error settling webhook delivery", "delivery", d.ID, "error", err)
+			}
+		}(d)
+	}
+	wg.Wait()
+
+	return nil
+}
+
+func (app *application) deliverWebhook(ctx context.Context, d store.DueDelivery) error {
+	body, err := json.Marshal(webhookEnvelope{ID: d.ID, Event: d.Event, CreatedAt: d.CreatedAt, Data: d.Payload})
+	if err != nil {
+		return err
+	}
+
+	status, err := app.webhookSender.Send(ctx, webhook.Delivery{
+		ID:     d.ID,
+		URL:    d.URL,
+		Secret: d.Secret,
+		Event:  d.Event,
+		Body:   body,
+	})
+	if err == nil {
+		return app.store.Webhooks.MarkDelivered(ctx, d.ID, status)
+	}
+
+	return app.store.Webhooks.MarkFailed(ctx, d.ID, status, err.Error(), webhook.Retry.RetryAt(d.Attempts))
+}
+
+func validateWebhookEvents(events []string) error {
+	for _, event := range events {
+		if !store.IsValidWebhookEvent(event) {
+			return fmt.Errorf("invalid event %q, must be one of: %s", event, strings.Join(store.WebhookEvents, ", "))
+		}
+	}
+
+	return nil
+}
+
+// CreateWebhook godoc
+//
+//	@Summary		Creates a webhook
+//	@Description	Subscribes an endpoint to platform events. The secret signing the deliveries is only returned here.
+//	@Tags			webhooks
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		CreateWebhookPayload	true	"Webhook payload"
+//	@Success		201		{object}	store.Webhook
+//	@Failure		400		{object}	error
+//	@Failure		403		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/admin/webhooks [post]
+func (app *application) createWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	var payload CreateWebhookPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := validateWebhookEvents(payload.Events); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	secret, err := webhook.NewSecret()
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	wh := &store.Webhook{
+		URL:    payload.URL,
+		Secret: secret,
+		Events: payload.Events,
+		Active: payload.Active == nil || *payload.Active,
+	}
+
+	if err := app.store.Webhooks.Create(r.Context(), wh); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.writeResponse(w, http.StatusCreated, wh); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// GetWebhooks godoc
+//
+//	@Summary		Fetches the webhooks
+//	@Description	Fetches every webhook subscription
+//	@Tags			webhooks
+//	@Accept			json
+//	@Produce		json
+//	@Success		200	{object}	[]store.Webhook
+//	@Failure		403	{object}	error
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/admin/webhooks [get]
+func (app *application) getWebhooksHandler(w http.ResponseWriter, r *http.Request) {
+	webhooks, err := app.store.Webhooks.GetAll(r.Context())
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.writeResponse(w, http.StatusOK, webhooks); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// GetWebhook godoc
+//
+//	@Summary		Fetches a webhook
+//	@Description	Fetches a webhook subscription by ID
+//	@Tags			webhooks
+//	@Accept			json
+//	@Produce		json
+//	@Param			webhookID	path		int	true	"Webhook ID"
+//	@Success		200			{object}	store.Webhook
+//	@Failure		403			{object}	error
+//	@Failure		404			{object}	error
+//	@Failure		500			{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/admin/webhooks/{webhookID} [get]
+func (app *application) getWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	if err := app.writeResponse(w, http.StatusOK, getWebhookFromCtx(r)); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// UpdateWebhook godoc
+//
+//	@Summary		Updates a webhook
+//	@Description	Updates the endpoint, the events or the state of a webhook subscription
+//	@Tags			webhooks
+//	@Accept			json
+//	@Produce		json
+//	@Param			webhookID	path		int						true	"Webhook ID"
+//	@Param			payload		body		UpdateWebhookPayload	true	"Webhook payload"
+//	@Success		200			{object}	store.Webhook
+//	@Failure		400			{object}	error
+//	@Failure		403			{object}	error
+//	@Failure		404			{object}	error
+//	@Failure		500			{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/admin/webhooks/{webhookID} [patch]
+func (app *application) updateWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	wh := getWebhookFromCtx(r)
+
+	var payload UpdateWebhookPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if payload.URL != nil {
+		wh.URL = *payload.URL
+	}
+
+	if payload.Events != nil {
+		if err := validateWebhookEvents(*payload.Events); err != nil {
+			app.badRequestResponse(w, r, err)
+			return
+		}
+		wh.Events = *payload.Events
+	}
+
+	if payload.Active != nil {
+		wh.Active = *payload.Active
+	}
+
+	if err := app.store.Webhooks.Update(r.Context(), wh); err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if err := app.writeResponse(w, http.StatusOK, wh); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// DeleteWebhook godoc
+//
+//	@Summary		Deletes a webhook
+//	@Description	Deletes a webhook subscription along with its deliveries
+//	@Tags			webhooks
+//	@Accept			json
+//	@Produce		json
+//	@Param			webhookID	path		int	true	"Webhook ID"
+//	@Success		204			{string}	string
+//	@Failure		403			{object}	error
+//	@Failure		404			{object}	error
+//	@Failure		500			{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/admin/webhooks/{webhookID} [delete]
+func (app *application) deleteWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	if err := app.store.Webhooks.Delete(r.Context(), getWebhookFromCtx(r).ID); err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetWebhookDeliveries godoc
+//
+//	@Summary		Fetches the deliveries of a webhook
+//	@Description	Fetches a page of the delivery log of a webhook, most recent first
+//	@Tags			webhooks
+//	@Accept			json
+//	@Produce		json
+//	@Param			webhookID	path		int		true	"Webhook ID"
+//	@Param			limit		query		int		false	"Limit"
+//	@Param			cursor		query		string	false	"Cursor of the next page"
+//	@Success		200			{object}	[]store.WebhookDelivery
+//	@Failure		400			{object}	error
+//	@Failure		403			{object}	error
+//	@Failure		404			{object}	error
+//	@Failure		500			{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/admin/webhooks/{webhookID}/deliveries [get]
+func (app *application) getWebhookDeliveriesHandler(w http.ResponseWriter, r *http.Request) {
+	cursor, limit, err := parseConnectionsPage(r)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	deliveries, err := app.store.Webhooks.GetDeliveries(r.Context(), getWebhookFromCtx(r).ID, cursor, limit)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	var nextCursor *string
+	if len(deliveries) == limit {
+		last := deliveries[len(deliveries)-1]
+		c := store.FeedCursor{CreatedAt: last.CreatedAt, ID: last.ID}.Encode()
+		nextCursor = &c
+	}
+
+	if err := app.writePaginatedResponse(w, http.StatusOK, deliveries, nextCursor); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// RedeliverWebhook godoc
+//
+//	@Summary		Redelivers a webhook delivery
+//	@Description	Queues a dead or delivered delivery again with a fresh set of attempts
+//	@Tags			webhooks
+//	@Accept			json
+//	@Produce		json
+//	@Param			webhookID	path		int	true	"Webhook ID"
+//	@Param			deliveryID	path		int	true	"Delivery ID"
+//	@Success		202			{string}	string
+//	@Failure		400			{object}	error
+//	@Failure		403			{object}	error
+//	@Failure		404			{object}	error
+//	@Failure		500			{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/admin/webhooks/{webhookID}/deliveries/{deliveryID}/redeliver [post]
+func (app *application) redeliverWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	deliveryId, err := strconv.Atoi(chi.URLParam(r, "deliveryID"))
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := app.store.Webhooks.Redeliver(r.Context(), getWebhookFromCtx(r).ID, deliveryId); err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func (app *application) webhooksContextMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		webhookID, err := strconv.Atoi(chi.URLParam(r, "webhookID"))
+		if err != nil {
+			app.badRequestResponse(w, r, err)
+			return
+		}
+
+		ctx := r.Context()
+
+		wh, err := app.store.Webhooks.GetById(ctx, webhookID)
+		if err != nil {
+			switch {
+			case errors.Is(err, store.ErrNotFound):
+				app.notFoundResponse(w, r, err)
+			default:
+				app.internalServerError(w, r, err)
+			}
+			return
+		}
+
+		ctx = context.WithValue(ctx, webhookCtxKey, wh)
+
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+func getWebhookFromCtx(r *http.Request) *store.Webhook {
+	return r.Context().Value(webhookCtxKey).(*store.Webhook)
+}
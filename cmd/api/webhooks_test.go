@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/AlieNoori/social/internal/store"
+	"github.com/AlieNoori/social/internal/webhook"
+	"github.com/AlieNoori/social/internal/webhook/webhooktest"
+)
+
+type settledDelivery struct {
+	status  int
+	retryAt *time.Time
+	dead    bool
+}
+
+// recordingWebhookStore hands out the given deliveries once and records how
+// they were settled.
+type recordingWebhookStore struct {
+	store.MockWebhookStore
+	due     []store.DueDelivery
+	settled map[int]settledDelivery
+}
+
+func (s *recordingWebhookStore) ClaimDue(context.Context, int, time.Duration) ([]store.DueDelivery, error) {
+	due := s.due
+	s.due = nil
+	return due, nil
+}
+
+func (s *recordingWebhookStore) MarkDelivered(_ context.Context, id, status int) error {
+	s.settled[id] = settledDelivery{status: status}
+	return nil
+}
+
+func (s *recordingWebhookStore) MarkFailed(_ context.Context, id, status int, _ string, retryAt *time.Time) error {
+	s.settled[id] = settledDelivery{status: status, retryAt: retryAt, dead: retryAt == nil}
+	return nil
+}
+
+func TestWebhooksAdmin(t *testing.T) {
+	app := NewTestApplication(t, config{})
+	mux := app.mount()
+	testToken, err := app.authenticator.GenerateToken(nil)
+	if err != nil {
+		t.Fatalf("error: %s\n", err.Error())
+	}
+
+	t.Run("should only allow admins", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodPost, "http://localhost:8080/v1/admin/webhooks",
+			strings.NewReader(`{"url":"https://example.com/hook","events":["post.created"]}`))
+		if err != nil {
+			t.Fatalf("error: %s\n", err.Error())
+		}
+
+		req.Header.Set("Autorization", "Bearer "+testToken)
+
+		rr := executeRequest(req, mux)
+
+		checkResponse(t, http.StatusForbidden, rr.Code)
+	})
+}
+
+func TestDeliverWebhooks(t *testing.T) {
+	receiver := webhooktest.NewReceiver("secret")
+	defer receiver.Close()
+
+	app := NewTestApplication(t, config{webhooks: webhooksConfig{batchSize: 10, timeout: time.Second}})
+	webhooks := &recordingWebhookStore{settled: make(map[int]settledDelivery)}
+	app.store.Webhooks = webhooks
+
+	delivery := func(id, attempts int, secret string) store.DueDelivery {
+		return store.DueDelivery{
+			WebhookDelivery: store.WebhookDelivery{
+				ID:        id,
+				Event:     store.WebhookPostCreated,
+				Payload:   json.RawMessage(`{"id":42}`),
+				Attempts:  attempts,
+				CreatedAt: time.Now(),
+			},
+			URL:    receiver.URL,
+			Secret: secret,
+		}
+	}
+
+	webhooks.due = []store.DueDelivery{
+		delivery(1, 1, "secret"),
+		delivery(2, 1, "wrong"),
+		delivery(3, webhook.MaxAttempts, "wrong"),
+	}
+
+	if err := app.deliverWebhooks(context.Background()); err != nil {
+		t.Fatalf("error: %s\n", err.Error())
+	}
+
+	if got := webhooks.settled[1]; got.status != http.StatusOK || got.retryAt != nil || got.dead {
+		t.Errorf("expected delivery 1 to be delivered and got %+v", got)
+	}
+
+	if got := webhooks.settled[2]; got.status != http.StatusUnauthorized || got.retryAt == nil {
+		t.Errorf("expected delivery 2 to be retried and got %+v", got)
+	}
+
+	if got := webhooks.settled[3]; !got.dead {
+		t.Errorf("expected delivery 3 to be dead and got %+v", got)
+	}
+
+	received := receiver.Deliveries()
+	if len(received) != 1 {
+		t.Fatalf("expected a single delivery and got %d", len(received))
+	}
+
+	var envelope webhookEnvelope
+	if err := json.Unmarshal(received[0].Body, &envelope); err != nil {
+		t.Fatalf("error: %s\n", err.Error())
+	}
+
+	if envelope.ID != 1 || envelope.Event != store.WebhookPostCreated || string(envelope.Data) != `{"id":42}` {
+		t.Errorf("unexpected envelope %+v", envelope)
+	}
+}
@@ -0,0 +1,3 @@
+ALTER TABLE users ADD COLUMN failed_login_attempts INT NOT NULL DEFAULT 0;
+
+ALTER TABLE users ADD COLUMN locked_until TIMESTAMP(0) WITH TIME ZONE;
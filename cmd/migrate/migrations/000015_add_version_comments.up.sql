@@ -0,0 +1,5 @@
+ALTER TABLE comments
+ADD COLUMN version integer NOT NULL DEFAULT 0;
+
+ALTER TABLE comments
+ADD COLUMN updated_at TIMESTAMP(0) WITH TIME ZONE NOT NULL DEFAULT NOW();
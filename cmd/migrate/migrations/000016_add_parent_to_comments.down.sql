@@ -0,0 +1,7 @@
+DROP INDEX IF EXISTS idx_comments_parent_id;
+
+ALTER TABLE comments
+DROP COLUMN deleted_at;
+
+ALTER TABLE comments
+DROP COLUMN parent_id;
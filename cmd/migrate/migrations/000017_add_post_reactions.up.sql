@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS post_reactions (
+    post_id bigint REFERENCES posts(id) ON DELETE CASCADE NOT NULL,
+    user_id bigint REFERENCES users(id) ON DELETE CASCADE NOT NULL,
+    kind VARCHAR(20) NOT NULL CHECK (kind IN ('like', 'love', 'laugh', 'wow', 'sad', 'angry')),
+    created_at TIMESTAMP(0) WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (post_id, user_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_post_reactions_post_id_kind ON post_reactions (post_id, kind);
@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS user_blocks (
+    blocker_id bigint REFERENCES users(id) ON DELETE CASCADE NOT NULL,
+    blocked_id bigint REFERENCES users(id) ON DELETE CASCADE NOT NULL,
+    created_at TIMESTAMP(0) WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (blocker_id, blocked_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_user_blocks_blocked_id ON user_blocks (blocked_id);
+
+CREATE TABLE IF NOT EXISTS user_mutes (
+    muter_id bigint REFERENCES users(id) ON DELETE CASCADE NOT NULL,
+    muted_id bigint REFERENCES users(id) ON DELETE CASCADE NOT NULL,
+    created_at TIMESTAMP(0) WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (muter_id, muted_id)
+);
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS follow_requests;
+
+ALTER TABLE IF EXISTS users DROP COLUMN IF EXISTS is_private;
@@ -0,0 +1,10 @@
+ALTER TABLE IF EXISTS users ADD COLUMN is_private boolean NOT NULL DEFAULT false;
+
+CREATE TABLE IF NOT EXISTS follow_requests (
+    requester_id bigint REFERENCES users(id) ON DELETE CASCADE NOT NULL,
+    target_id bigint REFERENCES users(id) ON DELETE CASCADE NOT NULL,
+    created_at TIMESTAMP(0) WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (requester_id, target_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_follow_requests_target_id_created_at ON follow_requests (target_id, created_at DESC, requester_id DESC);
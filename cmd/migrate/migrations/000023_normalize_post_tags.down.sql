@@ -0,0 +1,2 @@
+-- the original spelling of the tags is not kept, only the index is dropped
+DROP INDEX IF EXISTS idx_posts_created_at;
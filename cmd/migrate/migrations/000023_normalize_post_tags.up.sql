@@ -0,0 +1,8 @@
+UPDATE posts SET tags = ARRAY(
+    SELECT DISTINCT lower(regexp_replace(trim(both from ltrim(trim(tag), '#')), '\s+', ' ', 'g'))
+    FROM unnest(tags) AS tag
+    WHERE ltrim(trim(tag), '#') <> ''
+)
+WHERE tags IS NOT NULL;
+
+CREATE INDEX IF NOT EXISTS idx_posts_created_at ON posts (created_at DESC, id DESC);
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_notifications_unread;
+
+DROP INDEX IF EXISTS idx_notifications_event;
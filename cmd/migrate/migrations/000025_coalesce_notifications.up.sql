@@ -0,0 +1,12 @@
+DELETE FROM notifications AS n
+USING notifications AS newer
+WHERE n.user_id = newer.user_id AND n.actor_id = newer.actor_id AND n.kind = newer.kind AND
+    COALESCE(n.post_id, 0) = COALESCE(newer.post_id, 0) AND
+    COALESCE(n.comment_id, 0) = COALESCE(newer.comment_id, 0) AND
+    n.id < newer.id;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_notifications_event ON notifications (
+    user_id, actor_id, kind, COALESCE(post_id, 0), COALESCE(comment_id, 0)
+);
+
+CREATE INDEX IF NOT EXISTS idx_notifications_unread ON notifications (user_id) WHERE read_at IS NULL;
@@ -0,0 +1,7 @@
+DROP INDEX IF EXISTS idx_webhook_deliveries_idempotency_key;
+
+ALTER TABLE webhook_deliveries DROP COLUMN IF EXISTS idempotency_key;
+
+DROP TABLE IF EXISTS outbox_handled;
+
+DROP TABLE IF EXISTS outbox;
@@ -0,0 +1,26 @@
+CREATE TABLE IF NOT EXISTS outbox (
+    id bigserial PRIMARY KEY,
+    event VARCHAR(50) NOT NULL,
+    idempotency_key text NOT NULL UNIQUE,
+    payload jsonb NOT NULL,
+    attempts int NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMP(0) WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    last_error text,
+    processed_at TIMESTAMP(0) WITH TIME ZONE,
+    created_at TIMESTAMP(0) WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_outbox_due ON outbox (next_attempt_at, id) WHERE processed_at IS NULL;
+
+CREATE INDEX IF NOT EXISTS idx_outbox_processed_at ON outbox (processed_at) WHERE processed_at IS NOT NULL;
+
+CREATE TABLE IF NOT EXISTS outbox_handled (
+    event_id bigint REFERENCES outbox(id) ON DELETE CASCADE NOT NULL,
+    handler VARCHAR(50) NOT NULL,
+    handled_at TIMESTAMP(0) WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (event_id, handler)
+);
+
+ALTER TABLE webhook_deliveries ADD COLUMN IF NOT EXISTS idempotency_key text;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_webhook_deliveries_idempotency_key ON webhook_deliveries (webhook_id, idempotency_key);
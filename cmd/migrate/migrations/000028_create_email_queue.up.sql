@@ -0,0 +1,16 @@
+CREATE TABLE IF NOT EXISTS email_queue (
+    id bigserial PRIMARY KEY,
+    template VARCHAR(255) NOT NULL,
+    username VARCHAR(255) NOT NULL,
+    email citext NOT NULL,
+    data jsonb NOT NULL,
+    idempotency_key text NOT NULL UNIQUE,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending',
+    attempts int NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMP(0) WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    last_error text,
+    created_at TIMESTAMP(0) WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    sent_at TIMESTAMP(0) WITH TIME ZONE
+);
+
+CREATE INDEX IF NOT EXISTS idx_email_queue_due ON email_queue (next_attempt_at) WHERE status = 'pending';
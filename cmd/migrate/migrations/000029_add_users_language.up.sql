@@ -0,0 +1,3 @@
+ALTER TABLE users ADD COLUMN IF NOT EXISTS language VARCHAR(35) NOT NULL DEFAULT 'en';
+
+ALTER TABLE email_queue ADD COLUMN IF NOT EXISTS locale VARCHAR(35) NOT NULL DEFAULT 'en';
@@ -0,0 +1,7 @@
+DROP INDEX IF EXISTS idx_email_queue_settled;
+
+ALTER TABLE email_queue DROP COLUMN IF EXISTS settled_at;
+
+UPDATE email_queue SET data = '{}' WHERE data IS NULL;
+
+ALTER TABLE email_queue ALTER COLUMN data SET NOT NULL;
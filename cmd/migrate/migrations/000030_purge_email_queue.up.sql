@@ -0,0 +1,5 @@
+ALTER TABLE email_queue ALTER COLUMN data DROP NOT NULL;
+
+ALTER TABLE email_queue ADD COLUMN IF NOT EXISTS settled_at TIMESTAMP(0) WITH TIME ZONE;
+
+CREATE INDEX IF NOT EXISTS idx_email_queue_settled ON email_queue (settled_at) WHERE settled_at IS NOT NULL;
@@ -0,0 +1,21 @@
+ALTER TABLE users ADD COLUMN IF NOT EXISTS follower_count int NOT NULL DEFAULT 0;
+
+UPDATE users AS u SET follower_count = (
+    SELECT COUNT(*) FROM followers AS f WHERE f.follower_id = u.id
+);
+
+-- a row (user_id, follower_id) means user_id follows follower_id
+CREATE OR REPLACE FUNCTION update_follower_count() RETURNS trigger AS $$
+BEGIN
+    IF TG_OP = 'INSERT' THEN
+        UPDATE users SET follower_count = follower_count + 1 WHERE id = NEW.follower_id;
+    ELSE
+        UPDATE users SET follower_count = follower_count - 1 WHERE id = OLD.follower_id;
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER followers_update_follower_count
+AFTER INSERT OR DELETE ON followers
+FOR EACH ROW EXECUTE FUNCTION update_follower_count();
@@ -6,3 +6,15 @@ type Authenticator interface {
 	GenerateToken(claims jwt.Claims) (string, error)
 	ValidateToken(token string) (*jwt.Token, error)
 }
+
+// JWKSProvider is implemented by authenticators whose tokens can be verified
+// by third parties with a published set of public keys.
+type JWKSProvider interface {
+	JWKS() (JWKSet, error)
+}
+
+// Reloader is implemented by authenticators whose keys can be reloaded while
+// the server is running.
+type Reloader interface {
+	Reload() error
+}
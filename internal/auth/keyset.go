@@ -0,0 +1,331 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync/atomic"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// KeySetAuthenticator signs tokens with an asymmetric key and validates them
+// against every key of the set that has not been retired, so a new signing key
+// can be introduced without invalidating tokens issued with the previous one.
+// The set is read from disk again by Reload, which allows keys to be rotated
+// without a restart.
+type KeySetAuthenticator struct {
+	path string
+	set  atomic.Pointer[keySet]
+	aud  string
+	iss  string
+}
+
+type keySet struct {
+	active string
+	keys   map[string]*signingKey
+}
+
+type signingKey struct {
+	kid    string
+	method jwt.SigningMethod
+	// private is nil for keys that are only used to verify tokens
+	private crypto.Signer
+	public  crypto.PublicKey
+	retired bool
+}
+
+// keySetFile is the manifest describing the keys on disk. Each key has either
+// a private key, or only a public key when it is used for verification but
+// never for signing, e.g. one held by another issuer. Key paths are relative
+// to the manifest.
+//
+//	{
+//	  "active": "2025-02",
+//	  "keys": [
+//	    {"kid": "2025-02", "private_key": "2025-02.pem"},
+//	    {"kid": "2024-11", "public_key": "2024-11.pub.pem"},
+//	    {"kid": "2024-08", "private_key": "2024-08.pem", "retired": true}
+//	  ]
+//	}
+type keySetFile struct {
+	Active string `json:"active"`
+	Keys   []struct {
+		Kid        string `json:"kid"`
+		PrivateKey string `json:"private_key"`
+		PublicKey  string `json:"public_key"`
+		Retired    bool   `json:"retired"`
+	} `json:"keys"`
+}
+
+// JWK is the public part of a signing key as published in a JWKS document.
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+type JWKSet struct {
+	Keys []JWK `json:"keys"`
+}
+
+func NewKeySetAuthenticator(path, aud, iss string) (*KeySetAuthenticator, error) {
+	a := &KeySetAuthenticator{
+		path: path,
+		aud:  aud,
+		iss:  iss,
+	}
+
+	if err := a.Reload(); err != nil {
+		return nil, err
+	}
+
+	return a, nil
+}
+
+// Reload reads the keyset from disk and replaces the current one. When the
+// keyset cannot be loaded the current one is kept.
+func (a *KeySetAuthenticator) Reload() error {
+	set, err := loadKeySet(a.path)
+	if err != nil {
+		return err
+	}
+
+	a.set.Store(set)
+
+	return nil
+}
+
+func loadKeySet(path string) (*keySet, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var file keySetFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("parsing keyset %s: %w", path, err)
+	}
+
+	set := &keySet{
+		active: file.Active,
+		keys:   make(map[string]*signingKey, len(file.Keys)),
+	}
+
+	for _, k := range file.Keys {
+		var key *signingKey
+
+		switch {
+		case k.PrivateKey != "" && k.PublicKey != "":
+			return nil, fmt.Errorf("key %q: only one of private_key and public_key can be set", k.Kid)
+		case k.PrivateKey != "":
+			key, err = loadSigningKey(k.Kid, keyPath(path, k.PrivateKey))
+		case k.PublicKey != "":
+			key, err = loadVerifyingKey(k.Kid, keyPath(path, k.PublicKey))
+		default:
+			return nil, fmt.Errorf("key %q: no private_key or public_key set", k.Kid)
+		}
+		if err != nil {
+			return nil, err
+		}
+		key.retired = k.Retired
+
+		set.keys[k.Kid] = key
+	}
+
+	active, ok := set.keys[set.active]
+	if !ok {
+		return nil, fmt.Errorf("active key %q is not part of the keyset", set.active)
+	}
+	if active.retired {
+		return nil, fmt.Errorf("active key %q is retired", set.active)
+	}
+	if active.private == nil {
+		return nil, fmt.Errorf("active key %q has no private key", set.active)
+	}
+
+	return set, nil
+}
+
+func keyPath(manifest, path string) string {
+	if filepath.IsAbs(path) {
+		return path
+	}
+
+	return filepath.Join(filepath.Dir(manifest), path)
+}
+
+func readPEM(kid, path string) (*pem.Block, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("key %q: no PEM data found in %s", kid, path)
+	}
+
+	return block, nil
+}
+
+func loadSigningKey(kid, path string) (*signingKey, error) {
+	block, err := readPEM(kid, path)
+	if err != nil {
+		return nil, err
+	}
+
+	var private any
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		private, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	default:
+		private, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("key %q: %w", kid, err)
+	}
+
+	signer, ok := private.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("key %q: unsupported key type %T", kid, private)
+	}
+
+	key, err := newSigningKey(kid, signer.Public())
+	if err != nil {
+		return nil, err
+	}
+	key.private = signer
+
+	return key, nil
+}
+
+func loadVerifyingKey(kid, path string) (*signingKey, error) {
+	block, err := readPEM(kid, path)
+	if err != nil {
+		return nil, err
+	}
+
+	var public any
+	switch block.Type {
+	case "RSA PUBLIC KEY":
+		public, err = x509.ParsePKCS1PublicKey(block.Bytes)
+	default:
+		public, err = x509.ParsePKIXPublicKey(block.Bytes)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("key %q: %w", kid, err)
+	}
+
+	return newSigningKey(kid, public)
+}
+
+func newSigningKey(kid string, public crypto.PublicKey) (*signingKey, error) {
+	switch public.(type) {
+	case *rsa.PublicKey:
+		return &signingKey{kid: kid, method: jwt.SigningMethodRS256, public: public}, nil
+	case ed25519.PublicKey:
+		return &signingKey{kid: kid, method: jwt.SigningMethodEdDSA, public: public}, nil
+	default:
+		return nil, fmt.Errorf("key %q: unsupported key type %T", kid, public)
+	}
+}
+
+func (a *KeySetAuthenticator) GenerateToken(claims jwt.Claims) (string, error) {
+	set := a.set.Load()
+	key := set.keys[set.active]
+
+	token := jwt.NewWithClaims(key.method, claims)
+	token.Header["kid"] = key.kid
+
+	tokenString, err := token.SignedString(key.private)
+	if err != nil {
+		return "", err
+	}
+
+	return tokenString, nil
+}
+
+func (a *KeySetAuthenticator) ValidateToken(token string) (*jwt.Token, error) {
+	return jwt.Parse(
+		token,
+		func(t *jwt.Token) (any, error) {
+			kid, _ := t.Header["kid"].(string)
+
+			key, ok := a.set.Load().keys[kid]
+			if !ok || key.retired {
+				return nil, fmt.Errorf("unknown signing key %q", kid)
+			}
+
+			if t.Method.Alg() != key.method.Alg() {
+				return nil, fmt.Errorf("unexpected signing method %v", t.Header["alg"])
+			}
+
+			return key.public, nil
+		},
+		jwt.WithExpirationRequired(),
+		jwt.WithAudience(a.aud),
+		jwt.WithIssuer(a.iss),
+		jwt.WithValidMethods([]string{jwt.SigningMethodRS256.Alg(), jwt.SigningMethodEdDSA.Alg()}),
+	)
+}
+
+// JWKS returns the public keys of every non-retired key in the set.
+func (a *KeySetAuthenticator) JWKS() (JWKSet, error) {
+	keys := a.set.Load().keys
+	set := JWKSet{Keys: make([]JWK, 0, len(keys))}
+
+	for _, key := range keys {
+		if key.retired {
+			continue
+		}
+
+		jwk, err := key.jwk()
+		if err != nil {
+			return JWKSet{}, err
+		}
+
+		set.Keys = append(set.Keys, jwk)
+	}
+
+	sort.Slice(set.Keys, func(i, j int) bool { return set.Keys[i].Kid < set.Keys[j].Kid })
+
+	return set, nil
+}
+
+func (k *signingKey) jwk() (JWK, error) {
+	jwk := JWK{
+		Kid: k.kid,
+		Use: "sig",
+		Alg: k.method.Alg(),
+	}
+
+	switch public := k.public.(type) {
+	case *rsa.PublicKey:
+		jwk.Kty = "RSA"
+		jwk.N = base64.RawURLEncoding.EncodeToString(public.N.Bytes())
+		jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(public.E)).Bytes())
+	case ed25519.PublicKey:
+		jwk.Kty = "OKP"
+		jwk.Crv = "Ed25519"
+		jwk.X = base64.RawURLEncoding.EncodeToString(public)
+	default:
+		return JWK{}, fmt.Errorf("key %q: unsupported public key type %T", k.kid, public)
+	}
+
+	return jwk, nil
+}
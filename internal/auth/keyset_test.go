@@ -0,0 +1,235 @@
+package auth
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func writeTestKey(t *testing.T, dir, kid string, key any) {
+	t.Helper()
+
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatalf("error: %s\n", err.Error())
+	}
+
+	data := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+	if err := os.WriteFile(filepath.Join(dir, kid+".pem"), data, 0o600); err != nil {
+		t.Fatalf("error: %s\n", err.Error())
+	}
+}
+
+func writeTestPublicKey(t *testing.T, dir, kid string, key any) {
+	t.Helper()
+
+	der, err := x509.MarshalPKIXPublicKey(key)
+	if err != nil {
+		t.Fatalf("error: %s\n", err.Error())
+	}
+
+	data := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})
+	if err := os.WriteFile(filepath.Join(dir, kid+".pub.pem"), data, 0o600); err != nil {
+		t.Fatalf("error: %s\n", err.Error())
+	}
+}
+
+func writeTestKeySet(t *testing.T, dir, manifest string) string {
+	t.Helper()
+
+	path := filepath.Join(dir, "keyset.json")
+	if err := os.WriteFile(path, []byte(manifest), 0o600); err != nil {
+		t.Fatalf("error: %s\n", err.Error())
+	}
+
+	return path
+}
+
+func testClaimsFor(aud string) jwt.MapClaims {
+	return jwt.MapClaims{
+		"sub": 1,
+		"aud": aud,
+		"iss": aud,
+		"exp": time.Now().Add(time.Hour).Unix(),
+	}
+}
+
+func TestKeySetAuthenticator(t *testing.T) {
+	dir := t.TempDir()
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("error: %s\n", err.Error())
+	}
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("error: %s\n", err.Error())
+	}
+
+	writeTestKey(t, dir, "old", rsaKey)
+	writeTestKey(t, dir, "new", edKey)
+
+	before, err := NewKeySetAuthenticator(writeTestKeySet(t, dir, `{
+		"active": "old",
+		"keys": [{"kid": "old", "private_key": "old.pem"}]
+	}`), "test", "test")
+	if err != nil {
+		t.Fatalf("error: %s\n", err.Error())
+	}
+
+	oldToken, err := before.GenerateToken(testClaimsFor("test"))
+	if err != nil {
+		t.Fatalf("error: %s\n", err.Error())
+	}
+
+	t.Run("should keep accepting tokens of the previous key after rotation", func(t *testing.T) {
+		rotated, err := NewKeySetAuthenticator(writeTestKeySet(t, dir, `{
+			"active": "new",
+			"keys": [
+				{"kid": "new", "private_key": "new.pem"},
+				{"kid": "old", "private_key": "old.pem"}
+			]
+		}`), "test", "test")
+		if err != nil {
+			t.Fatalf("error: %s\n", err.Error())
+		}
+
+		if _, err := rotated.ValidateToken(oldToken); err != nil {
+			t.Errorf("expected old token to be valid, got %v", err)
+		}
+
+		newToken, err := rotated.GenerateToken(testClaimsFor("test"))
+		if err != nil {
+			t.Fatalf("error: %s\n", err.Error())
+		}
+
+		token, err := rotated.ValidateToken(newToken)
+		if err != nil {
+			t.Fatalf("expected new token to be valid, got %v", err)
+		}
+
+		if kid := token.Header["kid"]; kid != "new" {
+			t.Errorf("expected kid to be %q and got %v", "new", kid)
+		}
+
+		set, err := rotated.JWKS()
+		if err != nil {
+			t.Fatalf("error: %s\n", err.Error())
+		}
+
+		if len(set.Keys) != 2 {
+			t.Errorf("expected 2 published keys and got %d", len(set.Keys))
+		}
+	})
+
+	t.Run("should reject tokens signed with a retired key", func(t *testing.T) {
+		retired, err := NewKeySetAuthenticator(writeTestKeySet(t, dir, `{
+			"active": "new",
+			"keys": [
+				{"kid": "new", "private_key": "new.pem"},
+				{"kid": "old", "private_key": "old.pem", "retired": true}
+			]
+		}`), "test", "test")
+		if err != nil {
+			t.Fatalf("error: %s\n", err.Error())
+		}
+
+		if _, err := retired.ValidateToken(oldToken); err == nil {
+			t.Error("expected token of a retired key to be rejected")
+		}
+
+		set, err := retired.JWKS()
+		if err != nil {
+			t.Fatalf("error: %s\n", err.Error())
+		}
+
+		keys := set.Keys
+		if len(keys) != 1 || keys[0].Kid != "new" || keys[0].Kty != "OKP" {
+			t.Errorf("expected only the active Ed25519 key to be published and got %+v", keys)
+		}
+	})
+
+	t.Run("should verify tokens with a public-only key", func(t *testing.T) {
+		writeTestPublicKey(t, dir, "old", &rsaKey.PublicKey)
+
+		verifyOnly, err := NewKeySetAuthenticator(writeTestKeySet(t, dir, `{
+			"active": "new",
+			"keys": [
+				{"kid": "new", "private_key": "new.pem"},
+				{"kid": "old", "public_key": "old.pub.pem"}
+			]
+		}`), "test", "test")
+		if err != nil {
+			t.Fatalf("error: %s\n", err.Error())
+		}
+
+		if _, err := verifyOnly.ValidateToken(oldToken); err != nil {
+			t.Errorf("expected old token to be valid, got %v", err)
+		}
+
+		set, err := verifyOnly.JWKS()
+		if err != nil {
+			t.Fatalf("error: %s\n", err.Error())
+		}
+
+		if len(set.Keys) != 2 || set.Keys[1].Kid != "old" || set.Keys[1].Kty != "RSA" {
+			t.Errorf("expected the public-only RSA key to be published and got %+v", set.Keys)
+		}
+	})
+
+	t.Run("should reject a public-only active key", func(t *testing.T) {
+		_, err := NewKeySetAuthenticator(writeTestKeySet(t, dir, `{
+			"active": "old",
+			"keys": [{"kid": "old", "public_key": "old.pub.pem"}]
+		}`), "test", "test")
+		if err == nil {
+			t.Error("expected an active key without a private key to be rejected")
+		}
+	})
+
+	t.Run("should pick up keyset changes on reload", func(t *testing.T) {
+		path := writeTestKeySet(t, dir, `{
+			"active": "old",
+			"keys": [{"kid": "old", "private_key": "old.pem"}]
+		}`)
+
+		a, err := NewKeySetAuthenticator(path, "test", "test")
+		if err != nil {
+			t.Fatalf("error: %s\n", err.Error())
+		}
+
+		writeTestKeySet(t, dir, `{
+			"active": "new",
+			"keys": [{"kid": "new", "private_key": "new.pem"}]
+		}`)
+		if err := a.Reload(); err != nil {
+			t.Fatalf("error: %s\n", err.Error())
+		}
+
+		if _, err := a.ValidateToken(oldToken); err == nil {
+			t.Error("expected token of a removed key to be rejected")
+		}
+
+		writeTestKeySet(t, dir, `{"active": "missing", "keys": []}`)
+		if err := a.Reload(); err == nil {
+			t.Fatal("expected an invalid keyset to fail to reload")
+		}
+
+		token, err := a.GenerateToken(testClaimsFor("test"))
+		if err != nil {
+			t.Fatalf("error: %s\n", err.Error())
+		}
+
+		if _, err := a.ValidateToken(token); err != nil {
+			t.Errorf("expected the previous keyset to stay in use, got %v", err)
+		}
+	})
+}
@@ -14,6 +14,7 @@ var testClaims = jwt.MapClaims{
 	"aud": "test-aud",
 	"iss": "test-aud",
 	"sub": 205,
+	"sid": "7d0f2c1e-3b6a-4c8e-9f1d-2a5b8c4e6f10",
 	"exp": time.Now().Add(time.Hour).Unix(),
 }
 
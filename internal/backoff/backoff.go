@@ -0,0 +1,36 @@
+// Package backoff computes when a failed job is attempted again.
+package backoff
+
+import "time"
+
+// Policy retries a job with a delay that doubles from Base after each failed
+// attempt, up to Max.
+type Policy struct {
+	Base time.Duration
+	Max  time.Duration
+	// MaxAttempts is the number of attempts after which the job is given
+	// up, 0 retries it forever.
+	MaxAttempts int
+}
+
+// Delay returns how long to wait before the next attempt after the given
+// number of failed attempts.
+func (p Policy) Delay(attempts int) time.Duration {
+	delay := p.Base
+	for i := 1; i < attempts && delay < p.Max; i++ {
+		delay *= 2
+	}
+
+	return min(delay, p.Max)
+}
+
+// RetryAt returns when to make the next attempt after the given number of
+// failed attempts, or nil when the job ran out of attempts.
+func (p Policy) RetryAt(attempts int) *time.Time {
+	if p.MaxAttempts > 0 && attempts >= p.MaxAttempts {
+		return nil
+	}
+
+	t := time.Now().Add(p.Delay(attempts))
+	return &t
+}
@@ -0,0 +1,34 @@
+package backoff
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPolicy(t *testing.T) {
+	p := Policy{Base: time.Second, Max: time.Minute, MaxAttempts: 3}
+
+	t.Run("should double the delay up to the max", func(t *testing.T) {
+		want := map[int]time.Duration{0: time.Second, 1: time.Second, 2: 2 * time.Second, 6: 32 * time.Second, 7: time.Minute, 100: time.Minute}
+
+		for attempts, delay := range want {
+			if got := p.Delay(attempts); got != delay {
+				t.Errorf("expected a delay of %s after %d attempts and got %s", delay, attempts, got)
+			}
+		}
+	})
+
+	t.Run("should give up after the max attempts", func(t *testing.T) {
+		if p.RetryAt(2) == nil {
+			t.Error("expected a retry after 2 attempts")
+		}
+
+		if retryAt := p.RetryAt(3); retryAt != nil {
+			t.Errorf("expected no retry after 3 attempts and got %v", retryAt)
+		}
+
+		if (Policy{Base: time.Second, Max: time.Minute}).RetryAt(1000) == nil {
+			t.Error("expected a policy without max attempts to retry forever")
+		}
+	})
+}
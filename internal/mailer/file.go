@@ -0,0 +1,66 @@
+package mailer
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// FileMailer is a development mailer that writes each email to a .eml file
+// in a directory, or to a writer such as stdout, instead of sending it.
+type FileMailer struct {
+	fromEmail string
+	dir       string
+
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// NewFileMailer returns a mailer that writes emails into dir, creating it if
+// needed. The files can be opened with any mail client.
+func NewFileMailer(dir, fromEmail string) (*FileMailer, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+
+	return &FileMailer{dir: dir, fromEmail: fromEmail}, nil
+}
+
+// NewLogMailer returns a mailer that writes emails to w.
+func NewLogMailer(w io.Writer, fromEmail string) *FileMailer {
+	return &FileMailer{w: w, fromEmail: fromEmail}
+}
+
+func (m *FileMailer) Send(templateFileName, locale, username, email string, data any, isSandbox bool) (int, error) {
+	rendered, err := RenderEmailTemplate(templateFileName, locale, data)
+	if err != nil {
+		return -1, err
+	}
+
+	now := time.Now()
+	msg, err := buildMessage(m.fromEmail, username, email, rendered, now)
+	if err != nil {
+		return -1, err
+	}
+
+	if m.dir == "" {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+
+		if _, err := fmt.Fprintf(m.w, "%s\r\n", msg); err != nil {
+			return -1, err
+		}
+
+		return 0, nil
+	}
+
+	name := fmt.Sprintf("%d-%s.eml", now.UnixNano(), email)
+	if err := os.WriteFile(filepath.Join(m.dir, filepath.Base(name)), msg, 0o644); err != nil {
+		return -1, err
+	}
+
+	return 0, nil
+}
@@ -0,0 +1,56 @@
+package mailer
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFileMailer(t *testing.T) {
+	t.Run("should write each email to its own file", func(t *testing.T) {
+		dir := filepath.Join(t.TempDir(), "mail")
+		m, err := NewFileMailer(dir, "noreply@gophersocial.test")
+		if err != nil {
+			t.Fatalf("error: %s\n", err.Error())
+		}
+
+		for range 2 {
+			if _, err := m.Send(PasswordResetTemplate, DefaultLocale, "gopher", "gopher@example.com", sampleData[PasswordResetTemplate], true); err != nil {
+				t.Fatalf("error: %s\n", err.Error())
+			}
+		}
+
+		files, err := filepath.Glob(filepath.Join(dir, "*.eml"))
+		if err != nil {
+			t.Fatalf("error: %s\n", err.Error())
+		}
+
+		if len(files) != 2 {
+			t.Fatalf("expected 2 emails and got %d", len(files))
+		}
+
+		msg, err := os.ReadFile(files[0])
+		if err != nil {
+			t.Fatalf("error: %s\n", err.Error())
+		}
+
+		if !strings.Contains(string(msg), "Subject: Reset your GopherSocial password") {
+			t.Errorf("unexpected message:\n%s", msg)
+		}
+	})
+
+	t.Run("should write emails to the log writer", func(t *testing.T) {
+		buf := new(bytes.Buffer)
+		m := NewLogMailer(buf, "noreply@gophersocial.test")
+
+		if _, err := m.Send(PasswordResetTemplate, DefaultLocale, "gopher", "gopher@example.com", sampleData[PasswordResetTemplate], true); err != nil {
+			t.Fatalf("error: %s\n", err.Error())
+		}
+
+		if !strings.Contains(buf.String(), "To: \"gopher\" <gopher@example.com>") {
+			t.Errorf("unexpected output:\n%s", buf.String())
+		}
+	})
+}
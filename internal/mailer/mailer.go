@@ -3,49 +3,106 @@ package mailer
 import (
 	"bytes"
 	"embed"
+	htmltemplate "html/template"
+	"io/fs"
 	"path"
-	"text/template"
+	"strings"
+	texttemplate "text/template"
 )
 
 const (
-	FromName            = "GopherSocial"
-	maxRetries          = 5
-	UserWelcomeTemplate = "user_invitatoin.gotmpl"
+	FromName              = "GopherSocial"
+	UserWelcomeTemplate   = "user_invitation.gotmpl"
+	PasswordResetTemplate = "password_reset.gotmpl"
+
+	// DefaultLocale is used when a template has no variant for the locale of
+	// the recipient.
+	DefaultLocale = "en"
+
+	layoutFile = "templates/layout.gotmpl"
 )
 
 //go:embed templates/*
 var FS embed.FS
 
+// Email is a rendered email with an HTML and a plain-text alternative.
 type Email struct {
 	Subject string
-	Body    string
+	HTML    string
+	Text    string
 }
 
+// Client sends an email rendered from one of the embedded templates. Sending
+// is attempted once, retries are left to the email queue.
 type Client interface {
-	Send(templateFile, username, email string, data any, isSandbox bool) (int, error)
+	Send(templateFile, locale, username, email string, data any, isSandbox bool) (int, error)
 }
 
-func RenderEmailTemplate(templateFileName string, data any) (Email, error) {
-	path := path.Join("./templates", templateFileName)
-	tpl, err := template.ParseFS(FS, path)
+// RenderEmailTemplate renders the subject and both bodies of a template in
+// the given locale, falling back to the default locale. Each template defines
+// "subject", "html" and "text" blocks which are wrapped in the shared layout.
+func RenderEmailTemplate(templateFileName, locale string, data any) (Email, error) {
+	locale = ResolveLocale(templateFileName, locale)
+	files := []string{
+		layoutFile,
+		path.Join("templates", locale, "common.gotmpl"),
+		path.Join("templates", locale, templateFileName),
+	}
+
+	textTpl, err := texttemplate.New(templateFileName).Option("missingkey=error").ParseFS(FS, files...)
+	if err != nil {
+		return Email{}, err
+	}
+
+	htmlTpl, err := htmltemplate.New(templateFileName).Option("missingkey=error").ParseFS(FS, files...)
 	if err != nil {
 		return Email{}, err
 	}
 
 	subject := new(bytes.Buffer)
-	if err := tpl.ExecuteTemplate(subject, "subject", nil); err != nil {
+	if err := textTpl.ExecuteTemplate(subject, "subject", data); err != nil {
 		return Email{}, err
 	}
 
-	body := new(bytes.Buffer)
-	if err := tpl.ExecuteTemplate(body, "body", nil); err != nil {
+	text := new(bytes.Buffer)
+	if err := textTpl.ExecuteTemplate(text, "layout.text", data); err != nil {
+		return Email{}, err
+	}
+
+	html := new(bytes.Buffer)
+	if err := htmlTpl.ExecuteTemplate(html, "layout.html", data); err != nil {
 		return Email{}, err
 	}
 
 	email := Email{
-		Subject: subject.String(),
-		Body:    body.String(),
+		Subject: strings.TrimSpace(subject.String()),
+		HTML:    html.String(),
+		Text:    text.String(),
 	}
 
 	return email, nil
 }
+
+// ResolveLocale returns the locale a template is rendered in. A region
+// specific locale such as de-AT falls back to its language, and a language
+// without a variant of the template falls back to DefaultLocale.
+func ResolveLocale(templateFileName, locale string) string {
+	locale = strings.ToLower(strings.ReplaceAll(locale, "_", "-"))
+
+	candidates := []string{locale}
+	if base, _, ok := strings.Cut(locale, "-"); ok {
+		candidates = append(candidates, base)
+	}
+
+	for _, candidate := range candidates {
+		if candidate == "" {
+			continue
+		}
+
+		if _, err := fs.Stat(FS, path.Join("templates", candidate, templateFileName)); err == nil {
+			return candidate
+		}
+	}
+
+	return DefaultLocale
+}
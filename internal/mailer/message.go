@@ -0,0 +1,63 @@
+package mailer
+
+import (
+	"bytes"
+	"fmt"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"net/textproto"
+	"time"
+)
+
+// buildMessage formats a rendered email as an RFC 5322 multipart/alternative
+// message, as sent over SMTP or written out by the file mailer. The plain-text
+// part comes first so clients that can show HTML prefer it.
+func buildMessage(fromEmail, username, email string, rendered Email, sentAt time.Time) ([]byte, error) {
+	body := new(bytes.Buffer)
+	parts := multipart.NewWriter(body)
+
+	for _, alt := range []struct {
+		contentType string
+		content     string
+	}{
+		{"text/plain; charset=UTF-8", rendered.Text},
+		{"text/html; charset=UTF-8", rendered.HTML},
+	} {
+		part, err := parts.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {alt.contentType},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		qp := quotedprintable.NewWriter(part)
+		if _, err := qp.Write([]byte(alt.content)); err != nil {
+			return nil, err
+		}
+		if err := qp.Close(); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := parts.Close(); err != nil {
+		return nil, err
+	}
+
+	from := mail.Address{Name: FromName, Address: fromEmail}
+	to := mail.Address{Name: username, Address: email}
+
+	msg := new(bytes.Buffer)
+	fmt.Fprintf(msg, "From: %s\r\n", from.String())
+	fmt.Fprintf(msg, "To: %s\r\n", to.String())
+	fmt.Fprintf(msg, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", rendered.Subject))
+	fmt.Fprintf(msg, "Date: %s\r\n", sentAt.Format(time.RFC1123Z))
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	fmt.Fprintf(msg, "Content-Type: multipart/alternative; boundary=%s\r\n", parts.Boundary())
+	msg.WriteString("\r\n")
+	msg.Write(body.Bytes())
+
+	return msg.Bytes(), nil
+}
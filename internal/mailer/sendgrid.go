@@ -2,7 +2,6 @@ package mailer
 
 import (
 	"fmt"
-	"time"
 
 	"github.com/sendgrid/sendgrid-go"
 	"github.com/sendgrid/sendgrid-go/helpers/mail"
@@ -24,16 +23,16 @@ func NewSendGrid(apiKey, fromEmail string) *SendGridMailer {
 	}
 }
 
-func (m *SendGridMailer) Send(templateFileName, username, email string, data any, isSandbox bool) (int, error) {
+func (m *SendGridMailer) Send(templateFileName, locale, username, email string, data any, isSandbox bool) (int, error) {
 	from := mail.NewEmail(FromName, m.fromEmail)
 	to := mail.NewEmail(username, email)
 
-	Email, err := RenderEmailTemplate(templateFileName, data)
+	Email, err := RenderEmailTemplate(templateFileName, locale, data)
 	if err != nil {
 		return -1, err
 	}
 
-	message := mail.NewSingleEmail(from, Email.Subject, to, "", Email.Body)
+	message := mail.NewSingleEmail(from, Email.Subject, to, Email.Text, Email.HTML)
 
 	message.SetMailSettings(&mail.MailSettings{
 		SandboxMode: &mail.Setting{
@@ -41,16 +40,14 @@ func (m *SendGridMailer) Send(templateFileName, username, email string, data any
 		},
 	})
 
-	var retryErr error
-	for i := 0; i < maxRetries; i++ {
-		response, retryErr := m.client.Send(message)
-		if retryErr != nil {
-			time.Sleep(time.Second * time.Duration(i+1))
-			continue
-		}
+	response, err := m.client.Send(message)
+	if err != nil {
+		return -1, err
+	}
 
-		return response.StatusCode, retryErr
+	if response.StatusCode >= 400 {
+		return response.StatusCode, fmt.Errorf("sendgrid responded with status %d: %s", response.StatusCode, response.Body)
 	}
 
-	return -1, fmt.Errorf("failed to send email after %d attempts, error: %v", maxRetries, retryErr)
+	return response.StatusCode, nil
 }
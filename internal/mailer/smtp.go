@@ -0,0 +1,52 @@
+package mailer
+
+import (
+	"net"
+	"net/smtp"
+	"strconv"
+	"time"
+)
+
+// smtpOK is the reply code of an accepted message.
+const smtpOK = 250
+
+type SMTPMailer struct {
+	addr      string
+	fromEmail string
+	auth      smtp.Auth
+}
+
+// NewSMTP returns a mailer that hands emails to an SMTP server. Username and
+// password are optional, without them the server is used unauthenticated.
+func NewSMTP(host string, port int, username, password, fromEmail string) *SMTPMailer {
+	var auth smtp.Auth
+	if username != "" {
+		auth = smtp.PlainAuth("", username, password, host)
+	}
+
+	return &SMTPMailer{
+		addr:      net.JoinHostPort(host, strconv.Itoa(port)),
+		fromEmail: fromEmail,
+		auth:      auth,
+	}
+}
+
+// Send delivers the email to the SMTP server. SMTP has no sandbox, so
+// isSandbox is ignored; point the mailer at a local server instead.
+func (m *SMTPMailer) Send(templateFileName, locale, username, email string, data any, isSandbox bool) (int, error) {
+	rendered, err := RenderEmailTemplate(templateFileName, locale, data)
+	if err != nil {
+		return -1, err
+	}
+
+	msg, err := buildMessage(m.fromEmail, username, email, rendered, time.Now())
+	if err != nil {
+		return -1, err
+	}
+
+	if err := smtp.SendMail(m.addr, m.auth, m.fromEmail, []string{email}, msg); err != nil {
+		return -1, err
+	}
+
+	return smtpOK, nil
+}
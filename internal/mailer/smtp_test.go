@@ -0,0 +1,138 @@
+package mailer
+
+import (
+	"bufio"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+type smtpMessage struct {
+	from string
+	to   []string
+	data string
+}
+
+// fakeSMTPServer accepts a single SMTP session and reports the message it
+// received, it speaks just enough of the protocol for net/smtp.
+func fakeSMTPServer(t *testing.T) (string, int, <-chan smtpMessage) {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("error: %s\n", err.Error())
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	messages := make(chan smtpMessage, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		r := bufio.NewReader(conn)
+		reply := func(line string) { conn.Write([]byte(line + "\r\n")) }
+
+		var msg smtpMessage
+		reply("220 localhost ESMTP")
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			line = strings.TrimRight(line, "\r\n")
+			cmd := strings.ToUpper(line)
+
+			switch {
+			case strings.HasPrefix(cmd, "EHLO"), strings.HasPrefix(cmd, "HELO"):
+				reply("250 localhost")
+			case strings.HasPrefix(cmd, "MAIL FROM:"):
+				msg.from = strings.Trim(line[len("MAIL FROM:"):], "<>")
+				reply("250 OK")
+			case strings.HasPrefix(cmd, "RCPT TO:"):
+				msg.to = append(msg.to, strings.Trim(line[len("RCPT TO:"):], "<>"))
+				reply("250 OK")
+			case cmd == "DATA":
+				reply("354 End data with <CR><LF>.<CR><LF>")
+				var data strings.Builder
+				for {
+					l, err := r.ReadString('\n')
+					if err != nil {
+						return
+					}
+					if l == ".\r\n" {
+						break
+					}
+					data.WriteString(l)
+				}
+				msg.data = data.String()
+				reply("250 OK")
+				messages <- msg
+			case cmd == "QUIT":
+				reply("221 Bye")
+				return
+			default:
+				reply("502 Command not implemented")
+			}
+		}
+	}()
+
+	host, port, _ := net.SplitHostPort(ln.Addr().String())
+	p, _ := strconv.Atoi(port)
+
+	return host, p, messages
+}
+
+func TestSMTPMailer(t *testing.T) {
+	t.Run("should deliver the rendered email to the server", func(t *testing.T) {
+		host, port, messages := fakeSMTPServer(t)
+		m := NewSMTP(host, port, "", "", "noreply@gophersocial.test")
+
+		status, err := m.Send(PasswordResetTemplate, DefaultLocale, "gopher", "gopher@example.com", sampleData[PasswordResetTemplate], true)
+		if err != nil {
+			t.Fatalf("error: %s\n", err.Error())
+		}
+
+		if status != smtpOK {
+			t.Errorf("expected status %d and got %d", smtpOK, status)
+		}
+
+		msg := <-messages
+		if msg.from != "noreply@gophersocial.test" {
+			t.Errorf("unexpected sender %q", msg.from)
+		}
+
+		if len(msg.to) != 1 || msg.to[0] != "gopher@example.com" {
+			t.Errorf("unexpected recipients %v", msg.to)
+		}
+
+		for _, want := range []string{
+			"To: \"gopher\" <gopher@example.com>\r\n",
+			"Subject: Reset your GopherSocial password\r\n",
+			"Content-Type: multipart/alternative; boundary=",
+			"Content-Type: text/plain; charset=UTF-8\r\n",
+			"Content-Type: text/html; charset=UTF-8\r\n",
+		} {
+			if !strings.Contains(msg.data, want) {
+				t.Errorf("expected message to contain %q, got:\n%s", want, msg.data)
+			}
+		}
+	})
+
+	t.Run("should fail when the server is unreachable", func(t *testing.T) {
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatalf("error: %s\n", err.Error())
+		}
+		addr := ln.Addr().(*net.TCPAddr)
+		ln.Close()
+
+		m := NewSMTP("127.0.0.1", addr.Port, "", "", "noreply@gophersocial.test")
+		if _, err := m.Send(PasswordResetTemplate, DefaultLocale, "gopher", "gopher@example.com", sampleData[PasswordResetTemplate], true); err == nil {
+			t.Error("expected an error")
+		}
+	})
+}
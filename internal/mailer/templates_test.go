@@ -0,0 +1,117 @@
+package mailer
+
+import (
+	"flag"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "update the golden files")
+
+// sampleData holds the data each template is rendered with, it mirrors what
+// the API queues for the template.
+var sampleData = map[string]map[string]any{
+	UserWelcomeTemplate: {
+		"Username":      "gopher",
+		"ActivationURL": "http://localhost:4000/confirm/8a1f6f0e-5a57-4f0a-9a7c-0c6f2f6c8e7d",
+	},
+	PasswordResetTemplate: {
+		"Username":  "gopher",
+		"ResetURL":  "http://localhost:4000/reset-password/1b4e28ba-2fa1-11d2-883f-0016d3cca427",
+		"ExpiresIn": "1h0m0s",
+	},
+}
+
+// TestTemplatesGolden renders every template in every locale and compares it
+// with testdata/<locale>/<template>.golden. Run `go test ./internal/mailer
+// -update` to regenerate the files after changing a template.
+func TestTemplatesGolden(t *testing.T) {
+	templates, err := fs.Glob(FS, "templates/*/*.gotmpl")
+	if err != nil {
+		t.Fatalf("error: %s\n", err.Error())
+	}
+
+	for _, file := range templates {
+		locale, name := path.Base(path.Dir(file)), path.Base(file)
+		if name == "common.gotmpl" {
+			continue
+		}
+
+		t.Run(locale+"/"+name, func(t *testing.T) {
+			data, ok := sampleData[name]
+			if !ok {
+				t.Fatalf("no sample data for template %s", name)
+			}
+
+			email, err := RenderEmailTemplate(name, locale, data)
+			if err != nil {
+				t.Fatalf("error: %s\n", err.Error())
+			}
+
+			got := "Subject: " + email.Subject + "\n\n--- text ---\n" + email.Text + "\n--- html ---\n" + email.HTML
+			golden := filepath.Join("testdata", locale, strings.TrimSuffix(name, ".gotmpl")+".golden")
+
+			if *update {
+				if err := os.MkdirAll(filepath.Dir(golden), 0o755); err != nil {
+					t.Fatalf("error: %s\n", err.Error())
+				}
+				if err := os.WriteFile(golden, []byte(got), 0o644); err != nil {
+					t.Fatalf("error: %s\n", err.Error())
+				}
+			}
+
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatalf("error: %s\n", err.Error())
+			}
+
+			if got != string(want) {
+				t.Errorf("rendered email does not match %s, got:\n%s", golden, got)
+			}
+		})
+	}
+}
+
+func TestRenderEmailTemplate(t *testing.T) {
+	t.Run("should fail when the data misses a field", func(t *testing.T) {
+		if _, err := RenderEmailTemplate(UserWelcomeTemplate, DefaultLocale, map[string]any{"Username": "gopher"}); err == nil {
+			t.Error("expected an error")
+		}
+	})
+
+	t.Run("should escape the data in the html part only", func(t *testing.T) {
+		data := map[string]any{"Username": "<b>gopher</b>", "ActivationURL": "http://localhost:4000/confirm/x"}
+
+		email, err := RenderEmailTemplate(UserWelcomeTemplate, DefaultLocale, data)
+		if err != nil {
+			t.Fatalf("error: %s\n", err.Error())
+		}
+
+		if !strings.Contains(email.HTML, "&lt;b&gt;gopher&lt;/b&gt;") {
+			t.Errorf("expected the html part to escape the username, got:\n%s", email.HTML)
+		}
+
+		if !strings.Contains(email.Text, "Hi <b>gopher</b>,") {
+			t.Errorf("expected the text part to keep the username, got:\n%s", email.Text)
+		}
+	})
+}
+
+func TestResolveLocale(t *testing.T) {
+	for locale, want := range map[string]string{
+		"de":    "de",
+		"de-AT": "de",
+		"de_CH": "de",
+		"EN-us": "en",
+		"fr":    DefaultLocale,
+		"":      DefaultLocale,
+	} {
+		if got := ResolveLocale(UserWelcomeTemplate, locale); got != want {
+			t.Errorf("expected %q to resolve to %q and got %q", locale, want, got)
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+)
+
+var ErrBlocked = errors.New("one of the users has blocked the other")
+
+// notBlockedBy filters out rows whose author, in column, has blocked the
+// user passed as the given placeholder.
+func notBlockedBy(column, viewerPlaceholder string) string {
+	return `NOT EXISTS (
+		SELECT 1 FROM user_blocks AS b WHERE b.blocker_id = ` + column + ` AND b.blocked_id = ` + viewerPlaceholder + `
+	)`
+}
+
+// notMutedBy filters out rows whose author, in column, has been muted by the
+// user passed as the given placeholder.
+func notMutedBy(column, viewerPlaceholder string) string {
+	return `NOT EXISTS (
+		SELECT 1 FROM user_mutes AS m WHERE m.muter_id = ` + viewerPlaceholder + ` AND m.muted_id = ` + column + `
+	)`
+}
+
+type BlockStore struct {
+	db *sql.DB
+}
+
+// Block blocks blockedId for blockerId and removes the follows and follow
+// requests between them in both directions.
+func (s *BlockStore) Block(ctx context.Context, blockerId, blockedId int) error {
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, queryTimeoutDuration)
+		defer cancel()
+
+		if _, err := tx.ExecContext(ctx, `
+		INSERT INTO user_blocks (blocker_id,blocked_id) VALUES ($1,$2)
+		ON CONFLICT DO NOTHING
+		`, blockerId, blockedId); err != nil {
+			return err
+		}
+
+		if _, err := tx.ExecContext(ctx, `
+		DELETE FROM followers
+		WHERE (user_id = $1 AND follower_id = $2) OR (user_id = $2 AND follower_id = $1)
+		`, blockerId, blockedId); err != nil {
+			return err
+		}
+
+		_, err := tx.ExecContext(ctx, `
+		DELETE FROM follow_requests
+		WHERE (requester_id = $1 AND target_id = $2) OR (requester_id = $2 AND target_id = $1)
+		`, blockerId, blockedId)
+
+		return err
+	})
+}
+
+func (s *BlockStore) Unblock(ctx context.Context, blockerId, blockedId int) error {
+	query := `DELETE FROM user_blocks WHERE blocker_id = $1 AND blocked_id = $2`
+
+	ctx, cancel := context.WithTimeout(ctx, queryTimeoutDuration)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, query, blockerId, blockedId)
+
+	return err
+}
+
+// Mute hides the posts of mutedId from the feed of muterId, the muted user is
+// not told about it.
+func (s *BlockStore) Mute(ctx context.Context, muterId, mutedId int) error {
+	query := `
+	INSERT INTO user_mutes (muter_id,muted_id) VALUES ($1,$2)
+	ON CONFLICT DO NOTHING
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, queryTimeoutDuration)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, query, muterId, mutedId)
+
+	return err
+}
+
+func (s *BlockStore) Unmute(ctx context.Context, muterId, mutedId int) error {
+	query := `DELETE FROM user_mutes WHERE muter_id = $1 AND muted_id = $2`
+
+	ctx, cancel := context.WithTimeout(ctx, queryTimeoutDuration)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, query, muterId, mutedId)
+
+	return err
+}
@@ -0,0 +1,63 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestBlock(t *testing.T) {
+	db := newTestDB(t)
+	s := NewStorage(db)
+	ctx := context.Background()
+
+	users := createTestUsers(t, s, db, "blocker", "blocked")
+	blocker, blocked := users[0], users[1]
+
+	for _, pair := range [][2]*User{{blocker, blocked}, {blocked, blocker}} {
+		if err := s.Followers.Follow(ctx, pair[0].ID, pair[1].ID); err != nil {
+			t.Fatalf("error: %s\n", err.Error())
+		}
+	}
+
+	if err := s.Blocks.Block(ctx, blocker.ID, blocked.ID); err != nil {
+		t.Fatalf("error: %s\n", err.Error())
+	}
+
+	t.Run("should remove the follows in both directions", func(t *testing.T) {
+		for _, user := range []*User{blocker, blocked} {
+			counts, err := s.Users.GetCounts(ctx, user.ID)
+			if err != nil {
+				t.Fatalf("error: %s\n", err.Error())
+			}
+
+			if counts.Followers != 0 || counts.Following != 0 {
+				t.Errorf("expected user %d to have no follows left and got %+v", user.ID, counts)
+			}
+		}
+	})
+
+	t.Run("should prevent new follows in both directions", func(t *testing.T) {
+		for _, pair := range [][2]*User{{blocker, blocked}, {blocked, blocker}} {
+			if err := s.Followers.Follow(ctx, pair[0].ID, pair[1].ID); !errors.Is(err, ErrBlocked) {
+				t.Errorf("expected %v and got %v", ErrBlocked, err)
+			}
+		}
+	})
+
+	t.Run("should hide the blocker's posts from the blocked user", func(t *testing.T) {
+		post := &Post{UserId: blocker.ID, Title: "title", Content: "content", Tags: []string{}}
+		if err := s.Posts.Create(ctx, post); err != nil {
+			t.Fatalf("error: %s\n", err.Error())
+		}
+
+		posts, err := s.Posts.GetByIds(ctx, blocked.ID, []int{post.ID})
+		if err != nil {
+			t.Fatalf("error: %s\n", err.Error())
+		}
+
+		if len(posts) != 0 {
+			t.Errorf("expected the post to be hidden and got %+v", posts)
+		}
+	})
+}
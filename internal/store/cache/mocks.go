@@ -2,13 +2,16 @@ package cache
 
 import (
 	"context"
+	"time"
 
 	"github.com/AlieNoori/social/internal/store"
 )
 
 func NewMockStore() Storage {
 	return Storage{
-		Users: &MockUserStore{},
+		Users:     &MockUserStore{},
+		Timelines: &MockTimelineStore{},
+		Trending:  &MockTrendingStore{},
 	}
 }
 
@@ -21,3 +24,29 @@ func (m *MockUserStore) Get(context.Context, int) (*store.User, error) {
 func (m *MockUserStore) Set(context.Context, *store.User) error {
 	return nil
 }
+
+func (m *MockUserStore) Delete(context.Context, int) error {
+	return nil
+}
+
+type MockTimelineStore struct{}
+
+func (m *MockTimelineStore) Get(context.Context, int, *store.FeedCursor, int, int) ([]TimelineEntry, bool, error) {
+	return nil, false, nil
+}
+
+func (m *MockTimelineStore) Set(context.Context, int, []TimelineEntry) error { return nil }
+
+func (m *MockTimelineStore) Push(context.Context, []int, TimelineEntry) error { return nil }
+
+func (m *MockTimelineStore) Delete(context.Context, int) error { return nil }
+
+type MockTrendingStore struct{}
+
+func (m *MockTrendingStore) Get(context.Context, time.Duration) ([]store.TrendingTag, error) {
+	return nil, nil
+}
+
+func (m *MockTrendingStore) Set(context.Context, time.Duration, []store.TrendingTag) error {
+	return nil
+}
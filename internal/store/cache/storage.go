@@ -12,17 +12,30 @@ type Storage struct {
 	Users interface {
 		Get(context.Context, int) (*store.User, error)
 		Set(context.Context, *store.User) error
+		Delete(context.Context, int) error
 	}
 	RateLimit interface {
 		Incrementor(context.Context, string) error
 		Get(context.Context, string) (int, error)
 		Set(context.Context, string, time.Duration) error
 	}
+	Timelines interface {
+		Get(context.Context, int, *store.FeedCursor, int, int) ([]TimelineEntry, bool, error)
+		Set(context.Context, int, []TimelineEntry) error
+		Push(context.Context, []int, TimelineEntry) error
+		Delete(context.Context, int) error
+	}
+	Trending interface {
+		Get(context.Context, time.Duration) ([]store.TrendingTag, error)
+		Set(context.Context, time.Duration, []store.TrendingTag) error
+	}
 }
 
 func NewRedisStorage(rdb *redis.Client) Storage {
 	return Storage{
 		Users:     &UserStore{rdb},
 		RateLimit: &RateLimitStore{rdb},
+		Timelines: &TimelineStore{rdb},
+		Trending:  &TrendingStore{rdb},
 	}
 }
@@ -0,0 +1,186 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/AlieNoori/social/internal/store"
+	"github.com/go-redis/redis/v8"
+)
+
+const (
+	// TimelineMaxLen is the number of most recent posts kept in a timeline,
+	// older pages are served from the database.
+	TimelineMaxLen  = 800
+	TimelineExpTime = 24 * time.Hour
+)
+
+// TimelineEntry is a post in a precomputed timeline, ordered by CreatedAt.
+type TimelineEntry struct {
+	PostId    int
+	CreatedAt time.Time
+}
+
+type TimelineStore struct {
+	rdb *redis.Client
+}
+
+// pushScript adds a post to the timelines that are already cached and trims
+// them, timelines that are not cached are left to be warmed on the next read.
+var pushScript = redis.NewScript(`
+for _, key in ipairs(KEYS) do
+	if redis.call('EXISTS', key) == 1 then
+		redis.call('ZADD', key, ARGV[1], ARGV[2])
+		redis.call('ZREMRANGEBYRANK', key, 0, -tonumber(ARGV[3]) - 1)
+	end
+end
+return 0
+`)
+
+// pushBatchSize bounds the number of timelines updated by a single script
+// call so a post of a popular author does not block redis.
+const pushBatchSize = 500
+
+// timelineSentinel is stored in every timeline written by Set, so an empty
+// feed is cached too. Its zero score keeps it out of pages and makes it the
+// first entry trimmed once the timeline is full.
+var timelineSentinel = &redis.Z{Score: 0, Member: timelineMember(0)}
+
+func timelineKey(userId int) string {
+	return fmt.Sprintf("timeline/%d", userId)
+}
+
+func timelineScore(t time.Time) float64 {
+	return float64(t.UnixMicro())
+}
+
+// timelineMember zero pads the post id. Posts published in the same second
+// share a score and redis orders them by member, so padding keeps them in id
+// order like the feed query.
+func timelineMember(postId int) string {
+	return fmt.Sprintf("%019d", postId)
+}
+
+// Get returns a page of the timeline of a user, newest first, starting after
+// the cursor when one is given. ok is false when the timeline is not cached or
+// the page goes past the posts it holds.
+func (s *TimelineStore) Get(ctx context.Context, userId int, cursor *store.FeedCursor, offset, limit int) ([]TimelineEntry, bool, error) {
+	key := timelineKey(userId)
+
+	max := "+inf"
+	count := limit
+	if cursor != nil {
+		// the bound is inclusive as other posts may share the second of the
+		// cursor, the ones up to and including the cursor are dropped below
+		max = strconv.FormatInt(cursor.CreatedAt.UnixMicro(), 10)
+		offset = 0
+
+		ties, err := s.rdb.ZCount(ctx, key, max, max).Result()
+		if err != nil {
+			return nil, false, err
+		}
+		count += int(ties)
+	}
+
+	pipe := s.rdb.Pipeline()
+	card := pipe.ZCard(ctx, key)
+	page := pipe.ZRevRangeByScoreWithScores(ctx, key, &redis.ZRangeBy{
+		Min:    "(0",
+		Max:    max,
+		Offset: int64(offset),
+		Count:  int64(count),
+	})
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, false, err
+	}
+
+	size := card.Val()
+	members := page.Val()
+
+	entries := make([]TimelineEntry, 0, len(members))
+	for _, member := range members {
+		id, err := strconv.Atoi(member.Member.(string))
+		if err != nil {
+			return nil, false, err
+		}
+
+		entries = append(entries, TimelineEntry{
+			PostId:    id,
+			CreatedAt: time.UnixMicro(int64(member.Score)),
+		})
+	}
+
+	entries = afterCursor(entries, cursor)
+	if len(entries) > limit {
+		entries = entries[:limit]
+	}
+
+	if size == 0 || (len(entries) < limit && size >= TimelineMaxLen) {
+		return nil, false, nil
+	}
+
+	return entries, true, nil
+}
+
+// afterCursor drops the entries at or before the cursor from a page read with
+// an inclusive bound. Being newest first, they can only lead the page.
+func afterCursor(entries []TimelineEntry, cursor *store.FeedCursor) []TimelineEntry {
+	if cursor == nil {
+		return entries
+	}
+
+	i := 0
+	for i < len(entries) &&
+		entries[i].CreatedAt.UnixMicro() == cursor.CreatedAt.UnixMicro() &&
+		entries[i].PostId >= cursor.ID {
+		i++
+	}
+
+	return entries[i:]
+}
+
+// Set replaces the timeline of a user with the given entries, which may be
+// empty.
+func (s *TimelineStore) Set(ctx context.Context, userId int, entries []TimelineEntry) error {
+	key := timelineKey(userId)
+
+	members := make([]*redis.Z, 0, len(entries)+1)
+	members = append(members, timelineSentinel)
+	for _, entry := range entries {
+		members = append(members, &redis.Z{Score: timelineScore(entry.CreatedAt), Member: timelineMember(entry.PostId)})
+	}
+
+	pipe := s.rdb.TxPipeline()
+	pipe.Del(ctx, key)
+	pipe.ZAdd(ctx, key, members...)
+	pipe.ZRemRangeByRank(ctx, key, 0, -TimelineMaxLen-1)
+	pipe.Expire(ctx, key, TimelineExpTime)
+	_, err := pipe.Exec(ctx)
+
+	return err
+}
+
+// Push adds a post to the cached timelines of the given users.
+func (s *TimelineStore) Push(ctx context.Context, userIds []int, entry TimelineEntry) error {
+	for start := 0; start < len(userIds); start += pushBatchSize {
+		end := min(start+pushBatchSize, len(userIds))
+
+		keys := make([]string, 0, end-start)
+		for _, userId := range userIds[start:end] {
+			keys = append(keys, timelineKey(userId))
+		}
+
+		err := pushScript.Run(ctx, s.rdb, keys, timelineScore(entry.CreatedAt), timelineMember(entry.PostId), TimelineMaxLen).Err()
+		if err != nil && err != redis.Nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *TimelineStore) Delete(ctx context.Context, userId int) error {
+	return s.rdb.Del(ctx, timelineKey(userId)).Err()
+}
@@ -0,0 +1,148 @@
+package cache
+
+import (
+	"context"
+	"os"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/AlieNoori/social/internal/store"
+	"github.com/go-redis/redis/v8"
+)
+
+// newTestRedis connects to the redis in TEST_REDIS_ADDR, tests that need it
+// are skipped when it is not set.
+func newTestRedis(t *testing.T) *redis.Client {
+	t.Helper()
+
+	addr := os.Getenv("TEST_REDIS_ADDR")
+	if addr == "" {
+		t.Skip("TEST_REDIS_ADDR is not set")
+	}
+
+	rdb, err := NewRedisClient(addr, "", 0)
+	if err != nil {
+		t.Fatalf("error: %s\n", err.Error())
+	}
+	t.Cleanup(func() { rdb.Close() })
+
+	return rdb
+}
+
+func postIds(entries []TimelineEntry) []int {
+	ids := make([]int, len(entries))
+	for i, entry := range entries {
+		ids[i] = entry.PostId
+	}
+
+	return ids
+}
+
+func TestAfterCursor(t *testing.T) {
+	second := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	entries := []TimelineEntry{
+		{PostId: 12, CreatedAt: second},
+		{PostId: 10, CreatedAt: second},
+		{PostId: 9, CreatedAt: second},
+		{PostId: 4, CreatedAt: second.Add(-time.Second)},
+	}
+
+	t.Run("should keep the posts of the cursor second that follow it", func(t *testing.T) {
+		got := postIds(afterCursor(entries, &store.FeedCursor{CreatedAt: second, ID: 10}))
+		if want := []int{9, 4}; !slices.Equal(got, want) {
+			t.Errorf("expected %v and got %v", want, got)
+		}
+	})
+
+	t.Run("should keep every post of an older second", func(t *testing.T) {
+		got := postIds(afterCursor(entries[3:], &store.FeedCursor{CreatedAt: second, ID: 9}))
+		if want := []int{4}; !slices.Equal(got, want) {
+			t.Errorf("expected %v and got %v", want, got)
+		}
+	})
+}
+
+func TestTimelineStore(t *testing.T) {
+	rdb := newTestRedis(t)
+	s := &TimelineStore{rdb}
+	ctx := context.Background()
+
+	const userId = -1
+	t.Cleanup(func() { s.Delete(ctx, userId) })
+
+	second := time.Now().Truncate(time.Second)
+	entries := []TimelineEntry{
+		{PostId: 11, CreatedAt: second},
+		{PostId: 9, CreatedAt: second},
+		{PostId: 10, CreatedAt: second},
+		{PostId: 3, CreatedAt: second.Add(-time.Second)},
+		{PostId: 100, CreatedAt: second.Add(-time.Second)},
+	}
+	if err := s.Set(ctx, userId, entries); err != nil {
+		t.Fatalf("error: %s\n", err.Error())
+	}
+
+	t.Run("should page through posts of the same second in id order", func(t *testing.T) {
+		var got []int
+		var cursor *store.FeedCursor
+
+		for range 5 {
+			page, ok, err := s.Get(ctx, userId, cursor, 0, 2)
+			if err != nil {
+				t.Fatalf("error: %s\n", err.Error())
+			}
+			if !ok {
+				t.Fatal("expected the timeline to be cached")
+			}
+
+			got = append(got, postIds(page)...)
+			if len(page) < 2 {
+				break
+			}
+
+			last := page[len(page)-1]
+			cursor = &store.FeedCursor{CreatedAt: last.CreatedAt, ID: last.PostId}
+		}
+
+		if want := []int{11, 10, 9, 100, 3}; !slices.Equal(got, want) {
+			t.Errorf("expected %v and got %v", want, got)
+		}
+	})
+
+	t.Run("should cache an empty timeline", func(t *testing.T) {
+		const emptyUserId = -2
+		t.Cleanup(func() { s.Delete(ctx, emptyUserId) })
+
+		if _, ok, err := s.Get(ctx, emptyUserId, nil, 0, 2); err != nil || ok {
+			t.Fatalf("expected the timeline not to be cached and got %v, %v", ok, err)
+		}
+
+		if err := s.Set(ctx, emptyUserId, nil); err != nil {
+			t.Fatalf("error: %s\n", err.Error())
+		}
+
+		page, ok, err := s.Get(ctx, emptyUserId, nil, 0, 2)
+		if err != nil {
+			t.Fatalf("error: %s\n", err.Error())
+		}
+
+		if !ok || len(page) != 0 {
+			t.Errorf("expected a cached empty timeline and got %v, %v", page, ok)
+		}
+
+		post := TimelineEntry{PostId: 1, CreatedAt: second}
+		if err := s.Push(ctx, []int{emptyUserId}, post); err != nil {
+			t.Fatalf("error: %s\n", err.Error())
+		}
+
+		page, _, err = s.Get(ctx, emptyUserId, nil, 0, 2)
+		if err != nil {
+			t.Fatalf("error: %s\n", err.Error())
+		}
+
+		if got := postIds(page); !slices.Equal(got, []int{1}) {
+			t.Errorf("expected the pushed post and got %v", got)
+		}
+	})
+}
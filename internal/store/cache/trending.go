@@ -0,0 +1,50 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/AlieNoori/social/internal/store"
+	"github.com/go-redis/redis/v8"
+)
+
+// TrendingExpTime outlives a few refreshes of the trending tags so a failed
+// refresh keeps serving the previous list.
+const TrendingExpTime = 30 * time.Minute
+
+type TrendingStore struct {
+	rdb *redis.Client
+}
+
+func trendingKey(window time.Duration) string {
+	return fmt.Sprintf("trending/%s", window)
+}
+
+// Get returns the trending tags cached for a window, or nil when there are
+// none.
+func (s *TrendingStore) Get(ctx context.Context, window time.Duration) ([]store.TrendingTag, error) {
+	data, err := s.rdb.Get(ctx, trendingKey(window)).Bytes()
+	if err == redis.Nil {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	var tags []store.TrendingTag
+	if err := json.Unmarshal(data, &tags); err != nil {
+		return nil, err
+	}
+
+	return tags, nil
+}
+
+func (s *TrendingStore) Set(ctx context.Context, window time.Duration, tags []store.TrendingTag) error {
+	data, err := json.Marshal(tags)
+	if err != nil {
+		return err
+	}
+
+	return s.rdb.Set(ctx, trendingKey(window), data, TrendingExpTime).Err()
+}
@@ -49,3 +49,9 @@ func (s *UserStore) Set(ctx context.Context, user *store.User) error {
 
 	return err
 }
+
+func (s *UserStore) Delete(ctx context.Context, userId int) error {
+	cacheKey := fmt.Sprintf("user/%d", userId)
+
+	return s.rdb.Del(ctx, cacheKey).Err()
+}
@@ -3,54 +3,226 @@ package store
 import (
 	"context"
 	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
 	"time"
+
+	"github.com/lib/pq"
 )
 
+// previewRepliesLimit is the number of replies embedded under each top-level
+// comment, the rest is loaded through the replies cursor.
+const previewRepliesLimit = 3
+
 type Comment struct {
-	ID        int       `json:"id"`
-	Content   string    `json:"content"`
-	PostId    int       `json:"post_id"`
-	UserId    int       `json:"user_id"`
-	CreatedAt time.Time `json:"created_at"`
-	User      User      `json:"user"`
+	ID            int       `json:"id"`
+	Content       string    `json:"content"`
+	PostId        int       `json:"post_id"`
+	UserId        int       `json:"user_id"`
+	ParentId      *int      `json:"parent_id"`
+	Version       int       `json:"version"`
+	Deleted       bool      `json:"deleted"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+	User          User      `json:"user"`
+	RepliesCount  int       `json:"replies_count"`
+	Replies       []Comment `json:"replies,omitempty"`
+	RepliesCursor *int      `json:"replies_cursor,omitempty"`
+	Mentions      []Mention `json:"mentions"`
 }
 
 type CommentStore struct {
 	db *sql.DB
 }
 
+const commentColumns = `c.id,c.post_id,c.user_id,c.parent_id,c.content,c.version,c.deleted_at IS NOT NULL,c.created_at,c.updated_at,u.username,u.id,
+	(SELECT COUNT(*) FROM comments AS r WHERE r.parent_id = c.id)`
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanComment(row rowScanner, comment *Comment) error {
+	if err := row.Scan(
+		&comment.ID,
+		&comment.PostId,
+		&comment.UserId,
+		&comment.ParentId,
+		&comment.Content,
+		&comment.Version,
+		&comment.Deleted,
+		&comment.CreatedAt,
+		&comment.UpdatedAt,
+		&comment.User.UserName,
+		&comment.User.ID,
+		&comment.RepliesCount,
+	); err != nil {
+		return err
+	}
+
+	// deleted comments with replies stay as tombstones so the thread keeps
+	// its shape, but nothing about the author or content is exposed
+	if comment.Deleted {
+		comment.Content = ""
+		comment.UserId = 0
+		comment.User = User{}
+	}
+
+	return nil
+}
+
 func (s *CommentStore) Create(ctx context.Context, commnet *Comment) error {
 	query := `
-	INSERT INTO comments(post_id,user_id,content) VALUES($1,$2,$3)
-	RETURNING id, created_at
+	INSERT INTO comments(post_id,user_id,content,parent_id) VALUES($1,$2,$3,$4)
+	RETURNING id, version, created_at, updated_at
+	`
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, queryTimeoutDuration)
+		defer cancel()
+
+		if err := tx.QueryRowContext(ctx, query, commnet.PostId,
+			commnet.UserId,
+			commnet.Content,
+			commnet.ParentId,
+		).Scan(
+			&commnet.ID,
+			&commnet.Version,
+			&commnet.CreatedAt,
+			&commnet.UpdatedAt,
+		); err != nil {
+			return err
+		}
+
+		var err error
+		commnet.Mentions, err = setMentions(ctx, tx, "comment_id", commnet.ID, commnet.Version, commnet.UserId, commnet.Content)
+		if err != nil {
+			return err
+		}
+
+		return addToOutbox(ctx, tx, OutboxCommentCreated, outboxKey(OutboxCommentCreated, commnet.ID), commnet)
+	})
+}
+
+func (s *CommentStore) GetById(ctx context.Context, commentId int) (*Comment, error) {
+	query := `
+	SELECT ` + commentColumns + `
+	FROM comments AS c
+	INNER JOIN users AS u ON u.id = c.user_id
+	WHERE c.id = $1
 	`
 	ctx, cancel := context.WithTimeout(ctx, queryTimeoutDuration)
 	defer cancel()
 
-	if err := s.db.QueryRowContext(ctx, query, commnet.PostId,
-		commnet.UserId,
-		commnet.Content,
-	).Scan(
-		&commnet.ID,
-		&commnet.CreatedAt,
-	); err != nil {
-		return err
+	comment := &Comment{}
+
+	err := scanComment(s.db.QueryRowContext(ctx, query, commentId), comment)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrNotFound
+		default:
+			return nil, err
+		}
 	}
 
-	return nil
+	comments := []Comment{*comment}
+	if err := s.attachMentions(ctx, comments); err != nil {
+		return nil, err
+	}
+
+	return &comments[0], nil
 }
 
-func (s *CommentStore) GetByPostId(ctx context.Context, postId int) ([]Comment, error) {
+// GetByPostId returns a page of the top-level comments of a post, each with
+// its reply count and the first replies embedded. Comments of users that
+// hide their content from the viewer are left out.
+func (s *CommentStore) GetByPostId(ctx context.Context, postId, viewerId int, page PaginatedQuery) ([]Comment, error) {
 	query := `
-	SELECT c.id,c.post_id,c.user_id,c.content, c.created_at, u.username,u.id FROM comments as c
+	SELECT ` + commentColumns + ` FROM comments as c
 INNER JOIN users as u ON u.id = c.user_id
-WHERE c.post_id = $1
-ORDER BY c.created_at DESC;
+WHERE c.post_id = $1 AND c.parent_id IS NULL AND ` + visibleTo("c.user_id", "$6") + ` AND ` + page.TimeRange.condition("c.created_at", 4, 5) + fmt.Sprintf(" ORDER BY c.created_at %s, c.id %s ", strings.ToUpper(page.Sort), strings.ToUpper(page.Sort)) + `LIMIT $2 OFFSET $3;`
+
+	ctx, cancel := context.WithTimeout(ctx, queryTimeoutDuration)
+	defer cancel()
+
+	comments, err := s.query(ctx, query, postId, page.Limit, page.Offset, page.Since, page.Until, viewerId)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.attachReplies(ctx, comments, viewerId); err != nil {
+		return nil, err
+	}
+
+	return comments, nil
+}
+
+// GetReplies returns the direct replies of a comment, oldest first, that come
+// after the given reply id and that the viewer may see.
+func (s *CommentStore) GetReplies(ctx context.Context, parentId, viewerId, afterId, limit int) ([]Comment, error) {
+	query := `
+	SELECT ` + commentColumns + ` FROM comments as c
+	INNER JOIN users as u ON u.id = c.user_id
+	WHERE c.parent_id = $1 AND c.id > $2 AND ` + visibleTo("c.user_id", "$4") + `
+	ORDER BY c.id ASC
+	LIMIT $3;
 	`
+
 	ctx, cancel := context.WithTimeout(ctx, queryTimeoutDuration)
 	defer cancel()
 
-	rows, err := s.db.QueryContext(ctx, query, postId)
+	return s.query(ctx, query, parentId, afterId, limit, viewerId)
+}
+
+func (s *CommentStore) attachReplies(ctx context.Context, comments []Comment, viewerId int) error {
+	parentIds := make([]int64, 0, len(comments))
+	for _, comment := range comments {
+		if comment.RepliesCount > 0 {
+			parentIds = append(parentIds, int64(comment.ID))
+		}
+	}
+
+	if len(parentIds) == 0 {
+		return nil
+	}
+
+	query := `
+	SELECT ` + commentColumns + ` FROM (
+		SELECT *, ROW_NUMBER() OVER (PARTITION BY parent_id ORDER BY id) AS rn
+		FROM comments
+		WHERE parent_id = ANY($1) AND ` + visibleTo("comments.user_id", "$3") + `
+	) AS c
+	INNER JOIN users as u ON u.id = c.user_id
+	WHERE c.rn <= $2
+	ORDER BY c.parent_id, c.id;
+	`
+
+	replies, err := s.query(ctx, query, pq.Array(parentIds), previewRepliesLimit, viewerId)
+	if err != nil {
+		return err
+	}
+
+	byParent := make(map[int][]Comment, len(parentIds))
+	for _, reply := range replies {
+		byParent[*reply.ParentId] = append(byParent[*reply.ParentId], reply)
+	}
+
+	for i := range comments {
+		replies := byParent[comments[i].ID]
+		comments[i].Replies = replies
+
+		if len(replies) > 0 && comments[i].RepliesCount > len(replies) {
+			cursor := replies[len(replies)-1].ID
+			comments[i].RepliesCursor = &cursor
+		}
+	}
+
+	return nil
+}
+
+func (s *CommentStore) query(ctx context.Context, query string, args ...any) ([]Comment, error) {
+	rows, err := s.db.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -60,20 +232,111 @@ ORDER BY c.created_at DESC;
 
 	for rows.Next() {
 		var comment Comment
-		comment.User = User{}
-
-		if err := rows.Scan(
-			&comment.ID,
-			&comment.PostId,
-			&comment.UserId,
-			&comment.Content,
-			&comment.User.UserName,
-			&comment.User.ID,
-		); err != nil {
+		if err := scanComment(rows, &comment); err != nil {
 			return nil, err
 		}
 		comments = append(comments, comment)
 	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if err := s.attachMentions(ctx, comments); err != nil {
+		return nil, err
+	}
 
 	return comments, nil
 }
+
+func (s *CommentStore) attachMentions(ctx context.Context, comments []Comment) error {
+	ids := make([]int64, 0, len(comments))
+	for _, comment := range comments {
+		if !comment.Deleted {
+			ids = append(ids, int64(comment.ID))
+		}
+	}
+
+	mentions, err := loadMentions(ctx, s.db, "comment_id", ids)
+	if err != nil {
+		return err
+	}
+
+	for i := range comments {
+		comments[i].Mentions = MentionEntities(comments[i].Content, mentions[comments[i].ID])
+	}
+
+	return nil
+}
+
+func (s *CommentStore) Update(ctx context.Context, comment *Comment) error {
+	query := `
+	UPDATE comments
+	SET content = $3, updated_at = NOW(), version = version + 1
+	WHERE id = $1 AND version = $2 AND deleted_at IS NULL
+	RETURNING updated_at,version
+	`
+
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, queryTimeoutDuration)
+		defer cancel()
+
+		if err := tx.QueryRowContext(ctx, query,
+			comment.ID,
+			comment.Version,
+			comment.Content,
+		).Scan(
+			&comment.UpdatedAt,
+			&comment.Version,
+		); err != nil {
+			switch {
+			case errors.Is(err, sql.ErrNoRows):
+				return ErrNotFound
+			default:
+				return err
+			}
+		}
+
+		var err error
+		comment.Mentions, err = setMentions(ctx, tx, "comment_id", comment.ID, comment.Version, comment.UserId, comment.Content)
+
+		return err
+	})
+}
+
+// Delete removes a comment. Comments that have replies are replaced by a
+// tombstone instead so the replies keep their context.
+func (s *CommentStore) Delete(ctx context.Context, commentId int) error {
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, queryTimeoutDuration)
+		defer cancel()
+
+		var hasReplies bool
+		if err := tx.QueryRowContext(ctx,
+			`SELECT EXISTS (SELECT 1 FROM comments WHERE parent_id = $1)`,
+			commentId,
+		).Scan(&hasReplies); err != nil {
+			return err
+		}
+
+		query := `DELETE FROM comments WHERE id = $1`
+		if hasReplies {
+			query = `UPDATE comments SET content = '', deleted_at = NOW() WHERE id = $1 AND deleted_at IS NULL`
+		}
+
+		res, err := tx.ExecContext(ctx, query, commentId)
+		if err != nil {
+			return err
+		}
+
+		rows, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+
+		if rows == 0 {
+			return ErrNotFound
+		}
+
+		return nil
+	})
+}
@@ -0,0 +1,223 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"testing"
+)
+
+func createTestComments(t *testing.T, s Storage, post *Post, user *User, parentId *int, n int) []*Comment {
+	t.Helper()
+
+	comments := make([]*Comment, n)
+	for i := range n {
+		comments[i] = &Comment{PostId: post.ID, UserId: user.ID, ParentId: parentId, Content: fmt.Sprintf("comment %d", i)}
+		if err := s.Comments.Create(context.Background(), comments[i]); err != nil {
+			t.Fatalf("error: %s\n", err.Error())
+		}
+	}
+
+	return comments
+}
+
+func commentIds(comments []Comment) []int {
+	ids := make([]int, len(comments))
+	for i, comment := range comments {
+		ids[i] = comment.ID
+	}
+
+	return ids
+}
+
+func TestGetCommentsByPostId(t *testing.T) {
+	db := newTestDB(t)
+	s := NewStorage(db)
+	ctx := context.Background()
+
+	users := createTestUsers(t, s, db, "commenter", "viewer")
+	commenter, viewer := users[0], users[1]
+
+	post := &Post{UserId: commenter.ID, Title: "title", Content: "content", Tags: []string{}}
+	if err := s.Posts.Create(ctx, post); err != nil {
+		t.Fatalf("error: %s\n", err.Error())
+	}
+
+	created := createTestComments(t, s, post, commenter, nil, 5)
+	want := make([]int, len(created))
+	for i, comment := range created {
+		want[i] = comment.ID
+	}
+
+	page := func(t *testing.T, sort string, limit, offset int) []int {
+		t.Helper()
+
+		comments, err := s.Comments.GetByPostId(ctx, post.ID, viewer.ID, PaginatedQuery{Limit: limit, Offset: offset, Sort: sort})
+		if err != nil {
+			t.Fatalf("error: %s\n", err.Error())
+		}
+
+		return commentIds(comments)
+	}
+
+	t.Run("should page through every comment once", func(t *testing.T) {
+		var got []int
+		for offset := 0; offset < len(want)+2; offset += 2 {
+			got = append(got, page(t, "asc", 2, offset)...)
+		}
+
+		if !slices.Equal(got, want) {
+			t.Errorf("expected comments %v and got %v", want, got)
+		}
+	})
+
+	t.Run("should sort the newest comments first", func(t *testing.T) {
+		got := page(t, "desc", 3, 0)
+
+		if !slices.Equal(got, []int{want[4], want[3], want[2]}) {
+			t.Errorf("expected the three newest comments of %v and got %v", want, got)
+		}
+	})
+
+	t.Run("should hide the comments of a private commenter", func(t *testing.T) {
+		if _, err := db.Exec(`UPDATE users SET is_private = true WHERE id = $1`, commenter.ID); err != nil {
+			t.Fatalf("error: %s\n", err.Error())
+		}
+		t.Cleanup(func() { db.Exec(`UPDATE users SET is_private = false WHERE id = $1`, commenter.ID) })
+
+		if got := page(t, "asc", 10, 0); len(got) != 0 {
+			t.Errorf("expected no visible comment and got %v", got)
+		}
+	})
+
+	t.Run("should refuse an edit based on a stale version", func(t *testing.T) {
+		comment := *created[0]
+		comment.Content = "edited"
+		if err := s.Comments.Update(ctx, &comment); err != nil {
+			t.Fatalf("error: %s\n", err.Error())
+		}
+
+		stale := *created[0]
+		stale.Content = "stale"
+		if err := s.Comments.Update(ctx, &stale); !errors.Is(err, ErrNotFound) {
+			t.Errorf("expected %v and got %v", ErrNotFound, err)
+		}
+	})
+}
+
+func TestCommentReplies(t *testing.T) {
+	db := newTestDB(t)
+	s := NewStorage(db)
+	ctx := context.Background()
+
+	users := createTestUsers(t, s, db, "threader", "replier")
+	threader, replier := users[0], users[1]
+
+	post := &Post{UserId: threader.ID, Title: "title", Content: "content", Tags: []string{}}
+	if err := s.Posts.Create(ctx, post); err != nil {
+		t.Fatalf("error: %s\n", err.Error())
+	}
+
+	parents := createTestComments(t, s, post, threader, nil, 2)
+	busy, quiet := parents[0], parents[1]
+	busyReplies := createTestComments(t, s, post, replier, &busy.ID, previewRepliesLimit+2)
+	quietReplies := createTestComments(t, s, post, replier, &quiet.ID, 1)
+
+	topLevel := func(t *testing.T) map[int]Comment {
+		t.Helper()
+
+		comments, err := s.Comments.GetByPostId(ctx, post.ID, replier.ID, PaginatedQuery{Limit: 10, Sort: "asc"})
+		if err != nil {
+			t.Fatalf("error: %s\n", err.Error())
+		}
+
+		byId := make(map[int]Comment, len(comments))
+		for _, comment := range comments {
+			byId[comment.ID] = comment
+		}
+
+		return byId
+	}
+
+	t.Run("should embed the first replies with a cursor to the rest", func(t *testing.T) {
+		comment := topLevel(t)[busy.ID]
+
+		if comment.RepliesCount != len(busyReplies) {
+			t.Errorf("expected %d replies and got %d", len(busyReplies), comment.RepliesCount)
+		}
+
+		want := []int{busyReplies[0].ID, busyReplies[1].ID, busyReplies[2].ID}
+		if got := commentIds(comment.Replies); !slices.Equal(got, want) {
+			t.Errorf("expected replies %v and got %v", want, got)
+		}
+
+		if comment.RepliesCursor == nil || *comment.RepliesCursor != want[len(want)-1] {
+			t.Fatalf("expected replies cursor %d and got %v", want[len(want)-1], comment.RepliesCursor)
+		}
+
+		rest, err := s.Comments.GetReplies(ctx, busy.ID, replier.ID, *comment.RepliesCursor, 10)
+		if err != nil {
+			t.Fatalf("error: %s\n", err.Error())
+		}
+
+		if got := commentIds(rest); !slices.Equal(got, []int{busyReplies[3].ID, busyReplies[4].ID}) {
+			t.Errorf("expected the remaining replies and got %v", got)
+		}
+	})
+
+	t.Run("should not set a cursor when every reply is embedded", func(t *testing.T) {
+		comment := topLevel(t)[quiet.ID]
+
+		if got := commentIds(comment.Replies); !slices.Equal(got, []int{quietReplies[0].ID}) {
+			t.Errorf("expected reply %d and got %v", quietReplies[0].ID, got)
+		}
+
+		if comment.RepliesCursor != nil {
+			t.Errorf("expected no replies cursor and got %d", *comment.RepliesCursor)
+		}
+	})
+
+	t.Run("should leave a tombstone for a deleted comment with replies", func(t *testing.T) {
+		if err := s.Comments.Delete(ctx, busy.ID); err != nil {
+			t.Fatalf("error: %s\n", err.Error())
+		}
+
+		tombstone, ok := topLevel(t)[busy.ID]
+		if !ok {
+			t.Fatal("expected the deleted comment to stay in the thread")
+		}
+
+		if !tombstone.Deleted || tombstone.Content != "" || tombstone.UserId != 0 || tombstone.User.UserName != "" {
+			t.Errorf("expected an anonymous tombstone and got %+v", tombstone)
+		}
+
+		if tombstone.RepliesCount != len(busyReplies) || len(tombstone.Replies) != previewRepliesLimit {
+			t.Errorf("expected the tombstone to keep its replies and got %d of %d", len(tombstone.Replies), tombstone.RepliesCount)
+		}
+
+		edit := *busy
+		edit.Content = "edited"
+		if err := s.Comments.Update(ctx, &edit); !errors.Is(err, ErrNotFound) {
+			t.Errorf("expected editing a tombstone to fail with %v and got %v", ErrNotFound, err)
+		}
+
+		if err := s.Comments.Delete(ctx, busy.ID); !errors.Is(err, ErrNotFound) {
+			t.Errorf("expected deleting a tombstone again to fail with %v and got %v", ErrNotFound, err)
+		}
+	})
+
+	t.Run("should remove a deleted comment without replies", func(t *testing.T) {
+		if err := s.Comments.Delete(ctx, quietReplies[0].ID); err != nil {
+			t.Fatalf("error: %s\n", err.Error())
+		}
+
+		if _, err := s.Comments.GetById(ctx, quietReplies[0].ID); !errors.Is(err, ErrNotFound) {
+			t.Errorf("expected %v and got %v", ErrNotFound, err)
+		}
+
+		if comment := topLevel(t)[quiet.ID]; comment.RepliesCount != 0 || len(comment.Replies) != 0 {
+			t.Errorf("expected no reply left and got %+v", comment.Replies)
+		}
+	})
+}
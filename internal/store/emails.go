@@ -0,0 +1,146 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+)
+
+const (
+	EmailPending = "pending"
+	EmailSent    = "sent"
+	// EmailDead marks an email that ran out of attempts.
+	EmailDead = "dead"
+)
+
+// QueuedEmail is an email waiting to be rendered from its template and sent
+// by the mailer workers.
+type QueuedEmail struct {
+	ID             int
+	Template       string
+	Locale         string
+	UserName       string
+	Email          string
+	Data           json.RawMessage
+	IdempotencyKey string
+	Attempts       int
+	CreatedAt      time.Time
+}
+
+type EmailStore struct {
+	db *sql.DB
+}
+
+// EmailFunc builds the email sent to a user along with a change. It runs
+// inside the transaction of the change once the recipient is known, so the
+// email is queued if and only if the change is committed.
+type EmailFunc func(*User) (*QueuedEmail, error)
+
+// queueEmail queues an email in the given transaction. Queueing an email
+// again with the same key is a no-op, so a retried producer does not send it
+// twice.
+func queueEmail(ctx context.Context, tx *sql.Tx, email *QueuedEmail) error {
+	query := `
+	INSERT INTO email_queue (template,locale,username,email,data,idempotency_key)
+	VALUES ($1,$2,$3,$4,$5,$6)
+	ON CONFLICT (idempotency_key) DO NOTHING
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, queryTimeoutDuration)
+	defer cancel()
+
+	_, err := tx.ExecContext(ctx, query,
+		email.Template,
+		email.Locale,
+		email.UserName,
+		email.Email,
+		string(email.Data),
+		email.IdempotencyKey,
+	)
+
+	return err
+}
+
+var emailQueue = queue{
+	table: "email_queue",
+	due: `
+		SELECT q.id FROM email_queue AS q
+		WHERE q.status = 'pending' AND q.next_attempt_at <= NOW()
+		ORDER BY q.next_attempt_at`,
+	returning: `q.id, q.template, q.locale, q.username, q.email, q.data, q.idempotency_key, q.attempts, q.created_at`,
+}
+
+// ClaimDue claims up to limit pending emails that are due.
+func (s *EmailStore) ClaimDue(ctx context.Context, limit int, lease time.Duration) ([]QueuedEmail, error) {
+	return claim(ctx, s.db, emailQueue, limit, lease, func(rows *sql.Rows, e *QueuedEmail) error {
+		return rows.Scan(
+			&e.ID,
+			&e.Template,
+			&e.Locale,
+			&e.UserName,
+			&e.Email,
+			&e.Data,
+			&e.IdempotencyKey,
+			&e.Attempts,
+			&e.CreatedAt,
+		)
+	})
+}
+
+// MarkSent records a sent email. Its data is cleared as it may link to a
+// one-time token.
+func (s *EmailStore) MarkSent(ctx context.Context, id int) error {
+	query := `
+	UPDATE email_queue
+	SET status = 'sent', last_error = NULL, data = NULL, sent_at = NOW(), settled_at = NOW()
+	WHERE id = $1
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, queryTimeoutDuration)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, query, id)
+
+	return err
+}
+
+// MarkFailed records a failed attempt. The email is retried at retryAt, or
+// moved to the dead state when retryAt is nil, which clears its data like
+// MarkSent.
+func (s *EmailStore) MarkFailed(ctx context.Context, id int, lastError string, retryAt *time.Time) error {
+	query := `
+	UPDATE email_queue
+	SET status = $2, last_error = $3, next_attempt_at = COALESCE($4, next_attempt_at),
+		data = CASE WHEN $4::timestamptz IS NULL THEN NULL ELSE data END,
+		settled_at = CASE WHEN $4::timestamptz IS NULL THEN NOW() END
+	WHERE id = $1
+	`
+
+	status := EmailPending
+	if retryAt == nil {
+		status = EmailDead
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, queryTimeoutDuration)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, query, id, status, lastError, retryAt)
+
+	return err
+}
+
+// PurgeSettled deletes the emails sent or dead for more than retention.
+func (s *EmailStore) PurgeSettled(ctx context.Context, retention time.Duration) (int64, error) {
+	query := `DELETE FROM email_queue WHERE settled_at < $1`
+
+	ctx, cancel := context.WithTimeout(ctx, queryTimeoutDuration)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, query, time.Now().Add(-retention))
+	if err != nil {
+		return 0, err
+	}
+
+	return res.RowsAffected()
+}
@@ -3,6 +3,7 @@ package store
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"time"
 
 	"github.com/lib/pq"
@@ -14,34 +15,347 @@ type Follower struct {
 	CreatedAt  time.Time `json:"created_at"`
 }
 
+// Relationship describes how the viewer of a profile and its owner follow
+// each other.
+type Relationship struct {
+	FollowsYou bool `json:"follows_you"`
+	YouFollow  bool `json:"you_follow"`
+	// Requested is set while the viewer waits for a private user to approve
+	// their follow request.
+	Requested bool `json:"requested"`
+}
+
+// Connection is a user in a followers, following or follow requests list,
+// FollowedAt is when the follow happened or was requested.
+type Connection struct {
+	ID         int       `json:"id"`
+	UserName   string    `json:"username"`
+	FollowedAt time.Time `json:"followed_at"`
+}
+
 type FollowerStore struct {
 	db *sql.DB
 }
 
+// Follow makes userId follow followerId, unless one of them has blocked the
+// other.
 func (s *FollowerStore) Follow(ctx context.Context, userId, followerId int) error {
-	query := `INSERT INTO followers(user_id,follower_id) VALUES($1,$2)`
+	query := `
+	INSERT INTO followers(user_id,follower_id)
+	SELECT $1::bigint,$2::bigint
+	WHERE NOT EXISTS (
+		SELECT 1 FROM user_blocks
+		WHERE (blocker_id = $1 AND blocked_id = $2) OR (blocker_id = $2 AND blocked_id = $1)
+	)
+	RETURNING created_at`
+
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, queryTimeoutDuration)
+		defer cancel()
+
+		var followedAt time.Time
+		if err := tx.QueryRowContext(ctx, query, userId, followerId).Scan(&followedAt); err != nil {
+			if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
+				return ErrConflict
+			}
+			if errors.Is(err, sql.ErrNoRows) {
+				return ErrBlocked
+			}
+			return err
+		}
+
+		return addFollowToOutbox(ctx, tx, userId, followerId, followedAt)
+	})
+}
+
+// addFollowToOutbox records that followerId started following followedId.
+// The time of the follow is part of the key so following again after an
+// unfollow is a new event.
+func addFollowToOutbox(ctx context.Context, tx *sql.Tx, followerId, followedId int, followedAt time.Time) error {
+	return addToOutbox(ctx, tx, OutboxUserFollowed,
+		outboxKey(OutboxUserFollowed, followerId, followedId, followedAt.Unix()),
+		&FollowEvent{FollowerId: followerId, FollowedId: followedId},
+	)
+}
+
+// Unfollow removes the follow of userId on followerId, or cancels the pending
+// follow request when there is one.
+func (s *FollowerStore) Unfollow(ctx context.Context, userId, followerId int) error {
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, queryTimeoutDuration)
+		defer cancel()
+
+		if _, err := tx.ExecContext(ctx, `DELETE FROM followers 
+		WHERE user_id = $1 AND follower_id = $2`, userId, followerId); err != nil {
+			return err
+		}
+
+		_, err := tx.ExecContext(ctx, `DELETE FROM follow_requests
+		WHERE requester_id = $1 AND target_id = $2`, userId, followerId)
+
+		return err
+	})
+}
+
+// GetFollowerIds returns the ids of the users following userId.
+func (s *FollowerStore) GetFollowerIds(ctx context.Context, userId int) ([]int, error) {
+	// users that muted the author are left out so nothing is pushed to them
+	query := `
+	SELECT f.user_id FROM followers AS f
+	WHERE f.follower_id = $1 AND ` + notMutedBy("f.follower_id", "f.user_id")
 
 	ctx, cancel := context.WithTimeout(ctx, queryTimeoutDuration)
 	defer cancel()
 
-	_, err := s.db.ExecContext(ctx, query, userId, followerId)
+	rows, err := s.db.QueryContext(ctx, query, userId)
 	if err != nil {
-		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
-			return ErrConflict
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := make([]int, 0)
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
 		}
+		ids = append(ids, id)
 	}
 
-	return nil
+	return ids, rows.Err()
 }
 
-func (s *FollowerStore) Unfollow(ctx context.Context, userId, followerId int) error {
-	query := `DELETE FROM followers 
-	WHERE user_id = $1 AND follower_id = $2`
+// CountFollowers returns the follower count of a user, which a trigger keeps
+// up to date on every change of the followers table.
+func (s *FollowerStore) CountFollowers(ctx context.Context, userId int) (int, error) {
+	query := `SELECT follower_count FROM users WHERE id = $1`
 
 	ctx, cancel := context.WithTimeout(ctx, queryTimeoutDuration)
 	defer cancel()
 
-	_, err := s.db.ExecContext(ctx, query, userId, followerId)
+	var count int
+	err := s.db.QueryRowContext(ctx, query, userId).Scan(&count)
 
-	return err
+	return count, err
+}
+
+// FollowsPopular reports whether userId follows anyone with more than
+// threshold followers.
+func (s *FollowerStore) FollowsPopular(ctx context.Context, userId, threshold int) (bool, error) {
+	query := `
+	SELECT EXISTS (
+		SELECT 1 FROM followers AS f
+		INNER JOIN users AS u ON u.id = f.follower_id
+		WHERE f.user_id = $1 AND u.follower_count > $2
+	)
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, queryTimeoutDuration)
+	defer cancel()
+
+	var popular bool
+	err := s.db.QueryRowContext(ctx, query, userId, threshold).Scan(&popular)
+
+	return popular, err
+}
+
+// GetFollowers returns a page of the users following userId, most recent
+// first. The cursor is the (FollowedAt, ID) of the last connection of the
+// previous page.
+func (s *FollowerStore) GetFollowers(ctx context.Context, userId int, cursor *FeedCursor, limit int) ([]Connection, error) {
+	query := `
+	SELECT u.id, u.username, f.created_at
+	FROM followers AS f
+	INNER JOIN users AS u ON u.id = f.user_id
+	WHERE f.follower_id = $1 AND u.is_active = true AND
+		($3::timestamptz IS NULL OR (f.created_at, f.user_id) < ($3, $4))
+	ORDER BY f.created_at DESC, f.user_id DESC
+	LIMIT $2
+	`
+
+	return s.connections(ctx, query, userId, cursor, limit)
+}
+
+// GetFollowing returns a page of the users followed by userId, most recent
+// first, paginated like GetFollowers.
+func (s *FollowerStore) GetFollowing(ctx context.Context, userId int, cursor *FeedCursor, limit int) ([]Connection, error) {
+	query := `
+	SELECT u.id, u.username, f.created_at
+	FROM followers AS f
+	INNER JOIN users AS u ON u.id = f.follower_id
+	WHERE f.user_id = $1 AND u.is_active = true AND
+		($3::timestamptz IS NULL OR (f.created_at, f.follower_id) < ($3, $4))
+	ORDER BY f.created_at DESC, f.follower_id DESC
+	LIMIT $2
+	`
+
+	return s.connections(ctx, query, userId, cursor, limit)
+}
+
+func (s *FollowerStore) connections(ctx context.Context, query string, userId int, cursor *FeedCursor, limit int) ([]Connection, error) {
+	var cursorTime *time.Time
+	var cursorId int
+	if cursor != nil {
+		cursorTime = &cursor.CreatedAt
+		cursorId = cursor.ID
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, queryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, userId, limit, cursorTime, cursorId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	connections := make([]Connection, 0)
+	for rows.Next() {
+		var c Connection
+		if err := rows.Scan(&c.ID, &c.UserName, &c.FollowedAt); err != nil {
+			return nil, err
+		}
+		connections = append(connections, c)
+	}
+
+	return connections, rows.Err()
+}
+
+// GetRelationship returns how viewerId and userId follow each other.
+func (s *FollowerStore) GetRelationship(ctx context.Context, viewerId, userId int) (*Relationship, error) {
+	query := `
+	SELECT
+		EXISTS (SELECT 1 FROM followers WHERE user_id = $2 AND follower_id = $1),
+		EXISTS (SELECT 1 FROM followers WHERE user_id = $1 AND follower_id = $2),
+		EXISTS (SELECT 1 FROM follow_requests WHERE requester_id = $1 AND target_id = $2)
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, queryTimeoutDuration)
+	defer cancel()
+
+	rel := &Relationship{}
+	if err := s.db.QueryRowContext(ctx, query, viewerId, userId).Scan(&rel.FollowsYou, &rel.YouFollow, &rel.Requested); err != nil {
+		return nil, err
+	}
+
+	return rel, nil
+}
+
+// RequestFollow asks the private user targetId to approve requesterId as a
+// follower.
+func (s *FollowerStore) RequestFollow(ctx context.Context, requesterId, targetId int) error {
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, queryTimeoutDuration)
+		defer cancel()
+
+		var blocked, following bool
+		if err := tx.QueryRowContext(ctx, `
+		SELECT
+			EXISTS (
+				SELECT 1 FROM user_blocks
+				WHERE (blocker_id = $1 AND blocked_id = $2) OR (blocker_id = $2 AND blocked_id = $1)
+			),
+			EXISTS (SELECT 1 FROM followers WHERE user_id = $1 AND follower_id = $2)
+		`, requesterId, targetId).Scan(&blocked, &following); err != nil {
+			return err
+		}
+
+		switch {
+		case blocked:
+			return ErrBlocked
+		case following:
+			return ErrConflict
+		}
+
+		var requestedAt time.Time
+		err := tx.QueryRowContext(ctx, `
+		INSERT INTO follow_requests (requester_id,target_id) VALUES ($1,$2)
+		RETURNING created_at
+		`, requesterId, targetId).Scan(&requestedAt)
+		if err != nil {
+			if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
+				return ErrConflict
+			}
+			return err
+		}
+
+		return addToOutbox(ctx, tx, OutboxFollowRequested,
+			outboxKey(OutboxFollowRequested, requesterId, targetId, requestedAt.Unix()),
+			&FollowEvent{FollowerId: requesterId, FollowedId: targetId},
+		)
+	})
+}
+
+// GetFollowRequests returns a page of the pending follow requests of userId,
+// most recent first, paginated like GetFollowers.
+func (s *FollowerStore) GetFollowRequests(ctx context.Context, userId int, cursor *FeedCursor, limit int) ([]Connection, error) {
+	query := `
+	SELECT u.id, u.username, fr.created_at
+	FROM follow_requests AS fr
+	INNER JOIN users AS u ON u.id = fr.requester_id
+	WHERE fr.target_id = $1 AND u.is_active = true AND
+		($3::timestamptz IS NULL OR (fr.created_at, fr.requester_id) < ($3, $4))
+	ORDER BY fr.created_at DESC, fr.requester_id DESC
+	LIMIT $2
+	`
+
+	return s.connections(ctx, query, userId, cursor, limit)
+}
+
+// ApproveFollowRequest turns the pending request of requesterId into a
+// follow of targetId.
+func (s *FollowerStore) ApproveFollowRequest(ctx context.Context, targetId, requesterId int) error {
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		if err := deleteFollowRequest(ctx, tx, targetId, requesterId); err != nil {
+			return err
+		}
+
+		ctx, cancel := context.WithTimeout(ctx, queryTimeoutDuration)
+		defer cancel()
+
+		var followedAt time.Time
+		err := tx.QueryRowContext(ctx, `
+		INSERT INTO followers (user_id,follower_id) VALUES ($1,$2)
+		ON CONFLICT DO NOTHING
+		RETURNING created_at
+		`, requesterId, targetId).Scan(&followedAt)
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			// already following, nothing happened
+			return nil
+		case err != nil:
+			return err
+		}
+
+		return addFollowToOutbox(ctx, tx, requesterId, targetId, followedAt)
+	})
+}
+
+func (s *FollowerStore) RejectFollowRequest(ctx context.Context, targetId, requesterId int) error {
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		return deleteFollowRequest(ctx, tx, targetId, requesterId)
+	})
+}
+
+func deleteFollowRequest(ctx context.Context, tx *sql.Tx, targetId, requesterId int) error {
+	query := `DELETE FROM follow_requests WHERE target_id = $1 AND requester_id = $2`
+
+	ctx, cancel := context.WithTimeout(ctx, queryTimeoutDuration)
+	defer cancel()
+
+	res, err := tx.ExecContext(ctx, query, targetId, requesterId)
+	if err != nil {
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return ErrNotFound
+	}
+
+	return nil
 }
@@ -0,0 +1,110 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+)
+
+func TestFollowerCount(t *testing.T) {
+	db := newTestDB(t)
+	s := NewStorage(db)
+	ctx := context.Background()
+
+	users := createTestUsers(t, s, db, "fan", "star")
+	fan, star := users[0], users[1]
+
+	countFollowers := func() int {
+		t.Helper()
+
+		count, err := s.Followers.CountFollowers(ctx, star.ID)
+		if err != nil {
+			t.Fatalf("error: %s\n", err.Error())
+		}
+
+		return count
+	}
+
+	if err := s.Followers.Follow(ctx, fan.ID, star.ID); err != nil {
+		t.Fatalf("error: %s\n", err.Error())
+	}
+
+	t.Run("should count a new follower", func(t *testing.T) {
+		if count := countFollowers(); count != 1 {
+			t.Errorf("expected 1 follower and got %d", count)
+		}
+
+		popular, err := s.Followers.FollowsPopular(ctx, fan.ID, 0)
+		if err != nil {
+			t.Fatalf("error: %s\n", err.Error())
+		}
+
+		if !popular {
+			t.Error("expected the fan to follow a user above the threshold")
+		}
+	})
+
+	t.Run("should stop counting a follower after unfollowing", func(t *testing.T) {
+		if err := s.Followers.Unfollow(ctx, fan.ID, star.ID); err != nil {
+			t.Fatalf("error: %s\n", err.Error())
+		}
+
+		if count := countFollowers(); count != 0 {
+			t.Errorf("expected no followers and got %d", count)
+		}
+	})
+}
+
+func TestSetPrivate(t *testing.T) {
+	db := newTestDB(t)
+	s := NewStorage(db)
+	ctx := context.Background()
+
+	users := createTestUsers(t, s, db, "requester", "private")
+	requester, private := users[0], users[1]
+
+	key := outboxKey(OutboxUserFollowed, requester.ID, private.ID) + ":%"
+	t.Cleanup(func() { db.Exec(`DELETE FROM outbox WHERE idempotency_key LIKE $1`, key) })
+
+	if err := s.Users.SetPrivate(ctx, private.ID, true); err != nil {
+		t.Fatalf("error: %s\n", err.Error())
+	}
+
+	if err := s.Followers.RequestFollow(ctx, requester.ID, private.ID); err != nil {
+		t.Fatalf("error: %s\n", err.Error())
+	}
+
+	if err := s.Users.SetPrivate(ctx, private.ID, false); err != nil {
+		t.Fatalf("error: %s\n", err.Error())
+	}
+
+	t.Run("should approve pending requests when made public", func(t *testing.T) {
+		count, err := s.Followers.CountFollowers(ctx, private.ID)
+		if err != nil {
+			t.Fatalf("error: %s\n", err.Error())
+		}
+
+		if count != 1 {
+			t.Errorf("expected 1 follower and got %d", count)
+		}
+	})
+
+	t.Run("should announce each approved request", func(t *testing.T) {
+		var data []byte
+		err := db.QueryRow(`
+		SELECT payload FROM outbox WHERE event = $1 AND idempotency_key LIKE $2
+		`, OutboxUserFollowed, key).Scan(&data)
+		if err != nil {
+			t.Fatalf("error: %s\n", err.Error())
+		}
+
+		var payload FollowEvent
+		if err := json.Unmarshal(data, &payload); err != nil {
+			t.Fatalf("error: %s\n", err.Error())
+		}
+
+		if payload.FollowerId != requester.ID || payload.FollowedId != private.ID {
+			t.Errorf("unexpected follow event %+v", payload)
+		}
+	})
+}
@@ -0,0 +1,187 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"regexp"
+	"unicode/utf8"
+
+	"github.com/lib/pq"
+)
+
+// mentionPattern matches @username when the @ does not follow a word
+// character, so email addresses are not taken for mentions.
+var mentionPattern = regexp.MustCompile(`(?:^|[^\p{L}\p{N}_@])@([\p{L}\p{N}_]+(?:\.[\p{L}\p{N}_]+)*)`)
+
+// Mention is a user mentioned in a post or comment. Start and End are the
+// code point offsets of the @username in the content.
+type Mention struct {
+	UserId   int    `json:"user_id"`
+	UserName string `json:"username"`
+	Start    int    `json:"start"`
+	End      int    `json:"end"`
+}
+
+// ParseMentions returns the usernames mentioned in content, once each.
+func ParseMentions(content string) []string {
+	usernames := make([]string, 0)
+	seen := make(map[string]bool)
+
+	for _, m := range mentionPattern.FindAllStringSubmatch(content, -1) {
+		if !seen[m[1]] {
+			seen[m[1]] = true
+			usernames = append(usernames, m[1])
+		}
+	}
+
+	return usernames
+}
+
+// MentionEntities locates the mentions of the resolved users, keyed by
+// username, in content.
+func MentionEntities(content string, users map[string]int) []Mention {
+	mentions := make([]Mention, 0)
+	if len(users) == 0 {
+		return mentions
+	}
+
+	for _, loc := range mentionPattern.FindAllStringSubmatchIndex(content, -1) {
+		username := content[loc[2]:loc[3]]
+		userId, ok := users[username]
+		if !ok {
+			continue
+		}
+
+		// the @ right before the username starts the entity
+		start := utf8.RuneCountInString(content[:loc[2]-1])
+		mentions = append(mentions, Mention{
+			UserId:   userId,
+			UserName: username,
+			Start:    start,
+			End:      start + 1 + utf8.RuneCountInString(username),
+		})
+	}
+
+	return mentions
+}
+
+// MentionEvent records the users mentioned for the first time in a post or
+// comment, who are notified once it is committed.
+type MentionEvent struct {
+	ActorId   int   `json:"actor_id"`
+	PostId    *int  `json:"post_id,omitempty"`
+	CommentId *int  `json:"comment_id,omitempty"`
+	UserIds   []int `json:"user_ids"`
+}
+
+// setMentions replaces the users mentioned in a post or comment, as selected
+// by column, with the active users mentioned in content. It runs in the
+// transaction saving the content, version tells the edits apart. The users
+// mentioned for the first time are recorded in the outbox to be notified,
+// users already mentioned before an edit are not notified again.
+func setMentions(ctx context.Context, tx *sql.Tx, column string, id, version, authorId int, content string) ([]Mention, error) {
+	ctx, cancel := context.WithTimeout(ctx, queryTimeoutDuration)
+	defer cancel()
+
+	rows, err := tx.QueryContext(ctx, `
+	SELECT id, username FROM users WHERE username = ANY($1) AND is_active = true
+	`, pq.Array(ParseMentions(content)))
+	if err != nil {
+		return nil, err
+	}
+
+	resolved := make(map[string]int)
+	userIds := make([]int64, 0)
+	for rows.Next() {
+		var userId int
+		var username string
+		if err := rows.Scan(&userId, &username); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		resolved[username] = userId
+		userIds = append(userIds, int64(userId))
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if _, err := tx.ExecContext(ctx, `
+	DELETE FROM mentions WHERE `+column+` = $1 AND user_id <> ALL($2)
+	`, id, pq.Array(userIds)); err != nil {
+		return nil, err
+	}
+
+	rows, err = tx.QueryContext(ctx, `
+	INSERT INTO mentions (`+column+`,user_id)
+	SELECT $1, unnest($2::bigint[])
+	ON CONFLICT DO NOTHING
+	RETURNING user_id
+	`, id, pq.Array(userIds))
+	if err != nil {
+		return nil, err
+	}
+
+	event := MentionEvent{ActorId: authorId, UserIds: make([]int, 0)}
+	for rows.Next() {
+		var userId int
+		if err := rows.Scan(&userId); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		event.UserIds = append(event.UserIds, userId)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if column == "post_id" {
+		event.PostId = &id
+	} else {
+		event.CommentId = &id
+	}
+
+	if len(event.UserIds) > 0 {
+		key := outboxKey(OutboxUsersMentioned, column, id, version)
+		if err := addToOutbox(ctx, tx, OutboxUsersMentioned, key, &event); err != nil {
+			return nil, err
+		}
+	}
+
+	return MentionEntities(content, resolved), nil
+}
+
+// loadMentions returns the users mentioned by each of the posts or comments,
+// as selected by column, keyed by username.
+func loadMentions(ctx context.Context, db *sql.DB, column string, ids []int64) (map[int]map[string]int, error) {
+	byId := make(map[int]map[string]int)
+	if len(ids) == 0 {
+		return byId, nil
+	}
+
+	rows, err := db.QueryContext(ctx, `
+	SELECT m.`+column+`, u.id, u.username
+	FROM mentions AS m
+	INNER JOIN users AS u ON u.id = m.user_id
+	WHERE m.`+column+` = ANY($1) AND u.is_active = true
+	`, pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id, userId int
+		var username string
+		if err := rows.Scan(&id, &userId, &username); err != nil {
+			return nil, err
+		}
+
+		if byId[id] == nil {
+			byId[id] = make(map[string]int)
+		}
+		byId[id][username] = userId
+	}
+
+	return byId, rows.Err()
+}
@@ -0,0 +1,116 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"slices"
+	"testing"
+)
+
+func TestParseMentions(t *testing.T) {
+	got := ParseMentions("hi @alice and @bob.smith, mail me at carol@example.com or ping @alice again.")
+	want := []string{"alice", "bob.smith"}
+
+	if !slices.Equal(got, want) {
+		t.Errorf("expected %v and got %v", want, got)
+	}
+}
+
+func TestMentionEntities(t *testing.T) {
+	content := "héllo @alice, not @nobody"
+
+	got := MentionEntities(content, map[string]int{"alice": 7})
+	want := []Mention{{UserId: 7, UserName: "alice", Start: 6, End: 12}}
+
+	if !slices.Equal(got, want) {
+		t.Errorf("expected %+v and got %+v", want, got)
+	}
+}
+
+func TestSetMentions(t *testing.T) {
+	db := newTestDB(t)
+	s := NewStorage(db)
+	ctx := context.Background()
+
+	users := createTestUsers(t, s, db, "author", "follower", "stranger")
+	author, follower, stranger := users[0], users[1], users[2]
+
+	for _, user := range users {
+		if _, err := db.Exec(`UPDATE users SET is_active = true WHERE id = $1`, user.ID); err != nil {
+			t.Fatalf("error: %s\n", err.Error())
+		}
+	}
+
+	if err := s.Users.SetPrivate(ctx, author.ID, true); err != nil {
+		t.Fatalf("error: %s\n", err.Error())
+	}
+
+	if err := s.Followers.Follow(ctx, follower.ID, author.ID); err != nil {
+		t.Fatalf("error: %s\n", err.Error())
+	}
+
+	post := &Post{
+		UserId:  author.ID,
+		Title:   "mentions",
+		Content: "hi @" + follower.UserName + " and @" + stranger.UserName,
+		Tags:    []string{},
+	}
+	if err := s.Posts.Create(ctx, post); err != nil {
+		t.Fatalf("error: %s\n", err.Error())
+	}
+
+	t.Run("should save the mentions with the post", func(t *testing.T) {
+		if len(post.Mentions) != 2 {
+			t.Errorf("expected 2 mentions and got %+v", post.Mentions)
+		}
+	})
+
+	var event MentionEvent
+	t.Run("should record the mentioned users in the outbox", func(t *testing.T) {
+		var data []byte
+		err := db.QueryRow(`
+		SELECT payload FROM outbox WHERE idempotency_key = $1
+		`, outboxKey(OutboxUsersMentioned, "post_id", post.ID, post.Version)).Scan(&data)
+		if err != nil {
+			t.Fatalf("error: %s\n", err.Error())
+		}
+
+		if err := json.Unmarshal(data, &event); err != nil {
+			t.Fatalf("error: %s\n", err.Error())
+		}
+
+		if len(event.UserIds) != 2 {
+			t.Errorf("expected 2 mentioned users and got %+v", event)
+		}
+	})
+
+	t.Run("should only notify users who can see the post of a private author", func(t *testing.T) {
+		notifications, err := s.Notifications.CreateMentions(ctx, event)
+		if err != nil {
+			t.Fatalf("error: %s\n", err.Error())
+		}
+
+		if len(notifications) != 1 || notifications[0].UserId != follower.ID {
+			t.Errorf("expected only the follower to be notified and got %+v", notifications)
+		}
+	})
+
+	t.Run("should not record users mentioned again in an edit", func(t *testing.T) {
+		post.Content += " again @" + follower.UserName
+		if err := s.Posts.Update(ctx, post); err != nil {
+			t.Fatalf("error: %s\n", err.Error())
+		}
+
+		var count int
+		err := db.QueryRow(`
+		SELECT COUNT(*) FROM outbox WHERE idempotency_key = $1
+		`, outboxKey(OutboxUsersMentioned, "post_id", post.ID, post.Version)).Scan(&count)
+		if err != nil {
+			t.Fatalf("error: %s\n", err.Error())
+		}
+
+		if count != 0 {
+			t.Error("expected no mention event for users already mentioned")
+		}
+	})
+}
@@ -8,28 +8,270 @@ import (
 
 func NewMockStore() Storage {
 	return Storage{
-		Users: &MockUserStore{},
+		Posts:         &MockPostStore{},
+		Users:         &MockUserStore{},
+		Sessions:      &MockSessionStore{},
+		Followers:     &MockFollowerStore{},
+		Blocks:        &MockBlockStore{},
+		Notifications: &MockNotificationStore{},
+		Roles:         &MockRoleStore{},
+		Webhooks:      &MockWebhookStore{},
+		Outbox:        &MockOutboxStore{},
+		Emails:        &MockEmailStore{},
 	}
 }
 
+type MockPostStore struct{}
+
+func (m *MockPostStore) Create(context.Context, *Post) error { return nil }
+
+func (m *MockPostStore) GetById(_ context.Context, id int) (*Post, error) {
+	return &Post{ID: id}, nil
+}
+
+func (m *MockPostStore) Delete(context.Context, int) error { return nil }
+
+func (m *MockPostStore) Update(context.Context, *Post) error { return nil }
+
+func (m *MockPostStore) GetUserFeed(context.Context, int, PaginatedFeedQeury) ([]PostWithMetadata, error) {
+	return []PostWithMetadata{}, nil
+}
+
+func (m *MockPostStore) GetByIds(context.Context, int, []int) ([]PostWithMetadata, error) {
+	return []PostWithMetadata{}, nil
+}
+
+func (m *MockPostStore) Search(context.Context, int, PostSearchQuery) ([]PostSearchResult, error) {
+	return []PostSearchResult{}, nil
+}
+
+func (m *MockPostStore) GetByTag(context.Context, int, string, *FeedCursor, int) ([]PostWithMetadata, error) {
+	return []PostWithMetadata{}, nil
+}
+
+func (m *MockPostStore) GetTrendingTags(context.Context, time.Duration, int) ([]TrendingTag, error) {
+	return []TrendingTag{}, nil
+}
+
 type MockUserStore struct{}
 
 func (m *MockUserStore) Create(context.Context, *sql.Tx, *User) error { return nil }
 
-func (m *MockUserStore) Activate(context.Context, string) error { return nil }
+func (m *MockUserStore) Activate(context.Context, string) (*User, error) {
+	return &User{IsActive: true}, nil
+}
 
-func (m *MockUserStore) GetById(context.Context, int) (*User, error) { return nil, nil }
+func (m *MockUserStore) GetById(_ context.Context, id int) (*User, error) {
+	return &User{ID: id}, nil
+}
 
 func (m *MockUserStore) GetByEmail(context.Context, string) (*User, error) { return nil, nil }
 
-func (m *MockUserStore) CreateAndInvite(context.Context, *User, string, time.Duration) error {
-	return nil
+func (m *MockUserStore) Authenticate(context.Context, string, string, int, time.Duration) (*User, error) {
+	return &User{}, nil
+}
+
+func (m *MockUserStore) CreateAndInvite(_ context.Context, user *User, _ string, _ time.Duration, email EmailFunc) error {
+	_, err := email(user)
+	return err
 }
 
 func (m *MockUserStore) createUserInvitation(context.Context, *sql.Tx, string, time.Duration, int) error {
 	return nil
 }
 
+func (m *MockUserStore) ReissueInvitation(_ context.Context, email, _ string, _ time.Duration, invitationEmail EmailFunc) (*User, error) {
+	user := &User{Email: email}
+	if _, err := invitationEmail(user); err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
+func (m *MockUserStore) PurgeInactive(context.Context, time.Duration) (int64, error) {
+	return 0, nil
+}
+
 func (m *MockUserStore) Delete(context.Context, int) error {
 	return nil
 }
+
+func (m *MockUserStore) CreatePasswordReset(_ context.Context, email, _ string, _ time.Duration, resetEmail EmailFunc) (*User, error) {
+	user := &User{Email: email}
+	if _, err := resetEmail(user); err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
+func (m *MockUserStore) ResetPassword(context.Context, string, string) error {
+	return nil
+}
+
+func (m *MockUserStore) GetCounts(context.Context, int) (*UserCounts, error) {
+	return &UserCounts{}, nil
+}
+
+func (m *MockUserStore) SetPrivate(context.Context, int, bool) error { return nil }
+
+func (m *MockUserStore) SetLanguage(context.Context, int, string) error {
+	return nil
+}
+
+func (m *MockUserStore) CanView(context.Context, int, int) (bool, error) { return true, nil }
+
+func (m *MockUserStore) Search(context.Context, int, string, int) ([]UserSearchResult, error) {
+	return []UserSearchResult{}, nil
+}
+
+type MockFollowerStore struct{}
+
+func (m *MockFollowerStore) Follow(context.Context, int, int) error { return nil }
+
+func (m *MockFollowerStore) Unfollow(context.Context, int, int) error { return nil }
+
+func (m *MockFollowerStore) GetFollowerIds(context.Context, int) ([]int, error) {
+	return []int{}, nil
+}
+
+func (m *MockFollowerStore) CountFollowers(context.Context, int) (int, error) { return 0, nil }
+
+func (m *MockFollowerStore) FollowsPopular(context.Context, int, int) (bool, error) {
+	return false, nil
+}
+
+func (m *MockFollowerStore) GetFollowers(context.Context, int, *FeedCursor, int) ([]Connection, error) {
+	return []Connection{}, nil
+}
+
+func (m *MockFollowerStore) GetFollowing(context.Context, int, *FeedCursor, int) ([]Connection, error) {
+	return []Connection{}, nil
+}
+
+func (m *MockFollowerStore) GetRelationship(context.Context, int, int) (*Relationship, error) {
+	return &Relationship{}, nil
+}
+
+func (m *MockFollowerStore) RequestFollow(context.Context, int, int) error { return nil }
+
+func (m *MockFollowerStore) GetFollowRequests(context.Context, int, *FeedCursor, int) ([]Connection, error) {
+	return []Connection{}, nil
+}
+
+func (m *MockFollowerStore) ApproveFollowRequest(context.Context, int, int) error { return nil }
+
+func (m *MockFollowerStore) RejectFollowRequest(context.Context, int, int) error { return nil }
+
+type MockBlockStore struct{}
+
+func (m *MockBlockStore) Block(context.Context, int, int) error { return nil }
+
+func (m *MockBlockStore) Unblock(context.Context, int, int) error { return nil }
+
+func (m *MockBlockStore) Mute(context.Context, int, int) error { return nil }
+
+func (m *MockBlockStore) Unmute(context.Context, int, int) error { return nil }
+
+type MockNotificationStore struct{}
+
+func (m *MockNotificationStore) Create(_ context.Context, n *Notification) error {
+	n.ID = 1
+	return nil
+}
+
+func (m *MockNotificationStore) CreateMentions(context.Context, MentionEvent) ([]Notification, error) {
+	return []Notification{}, nil
+}
+
+func (m *MockNotificationStore) GetForUser(context.Context, int, *FeedCursor, int) ([]Notification, error) {
+	return []Notification{}, nil
+}
+
+func (m *MockNotificationStore) CountUnread(context.Context, int) (int, error) { return 0, nil }
+
+func (m *MockNotificationStore) MarkRead(context.Context, int, []int) error { return nil }
+
+type MockSessionStore struct{}
+
+func (m *MockSessionStore) Create(context.Context, *Session, string, time.Duration) error {
+	return nil
+}
+
+func (m *MockSessionStore) Rotate(context.Context, string, string, time.Duration) (*Session, error) {
+	return &Session{}, nil
+}
+
+func (m *MockSessionStore) RevokeByToken(context.Context, string) error { return nil }
+
+func (m *MockSessionStore) IsActive(context.Context, string) (bool, error) { return true, nil }
+
+// roleLevels mirrors the levels seeded by the roles migration.
+var roleLevels = map[string]int{"user": 1, "moderator": 2, "admin": 3}
+
+type MockRoleStore struct{}
+
+func (m *MockRoleStore) GetByName(_ context.Context, name string) (*Role, error) {
+	return &Role{Name: name, Level: roleLevels[name]}, nil
+}
+
+type MockWebhookStore struct{}
+
+func (m *MockWebhookStore) Create(context.Context, *Webhook) error { return nil }
+
+func (m *MockWebhookStore) GetAll(context.Context) ([]Webhook, error) { return []Webhook{}, nil }
+
+func (m *MockWebhookStore) GetById(_ context.Context, id int) (*Webhook, error) {
+	return &Webhook{ID: id}, nil
+}
+
+func (m *MockWebhookStore) Update(context.Context, *Webhook) error { return nil }
+
+func (m *MockWebhookStore) Delete(context.Context, int) error { return nil }
+
+func (m *MockWebhookStore) Enqueue(context.Context, string, string, any) error { return nil }
+
+func (m *MockWebhookStore) ClaimDue(context.Context, int, time.Duration) ([]DueDelivery, error) {
+	return []DueDelivery{}, nil
+}
+
+func (m *MockWebhookStore) MarkDelivered(context.Context, int, int) error { return nil }
+
+func (m *MockWebhookStore) MarkFailed(context.Context, int, int, string, *time.Time) error {
+	return nil
+}
+
+func (m *MockWebhookStore) GetDeliveries(context.Context, int, *FeedCursor, int) ([]WebhookDelivery, error) {
+	return []WebhookDelivery{}, nil
+}
+
+func (m *MockWebhookStore) Redeliver(context.Context, int, int) error { return nil }
+
+type MockOutboxStore struct{}
+
+func (m *MockOutboxStore) ClaimDue(context.Context, int, time.Duration) ([]OutboxEvent, error) {
+	return []OutboxEvent{}, nil
+}
+
+func (m *MockOutboxStore) MarkHandled(context.Context, int, string) error { return nil }
+
+func (m *MockOutboxStore) MarkProcessed(context.Context, int) error { return nil }
+
+func (m *MockOutboxStore) MarkFailed(context.Context, int, string, time.Time) error { return nil }
+
+func (m *MockOutboxStore) PurgeProcessed(context.Context, time.Duration) (int64, error) {
+	return 0, nil
+}
+
+type MockEmailStore struct{}
+
+func (m *MockEmailStore) ClaimDue(context.Context, int, time.Duration) ([]QueuedEmail, error) {
+	return []QueuedEmail{}, nil
+}
+
+func (m *MockEmailStore) MarkSent(context.Context, int) error { return nil }
+
+func (m *MockEmailStore) MarkFailed(context.Context, int, string, *time.Time) error { return nil }
+
+func (m *MockEmailStore) PurgeSettled(context.Context, time.Duration) (int64, error) { return 0, nil }
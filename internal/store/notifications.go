@@ -0,0 +1,189 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+const (
+	NotificationFollow        = "follow"
+	NotificationFollowRequest = "follow_request"
+	NotificationComment       = "comment"
+	NotificationReply         = "reply"
+	NotificationReaction      = "reaction"
+	NotificationMention       = "mention"
+)
+
+// Notification tells a user that the actor did something of the given kind,
+// on a post or comment when the kind relates to one.
+type Notification struct {
+	ID        int       `json:"id"`
+	UserId    int       `json:"-"`
+	Kind      string    `json:"kind"`
+	ActorId   int       `json:"-"`
+	Actor     User      `json:"actor"`
+	PostId    *int      `json:"post_id"`
+	CommentId *int      `json:"comment_id"`
+	Read      bool      `json:"read"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// upsertNotification ends an INSERT INTO notifications so that repeating an
+// event, like following again after unfollowing, brings the existing
+// notification back as unread instead of adding another one.
+const upsertNotification = `
+ON CONFLICT (user_id, actor_id, kind, COALESCE(post_id, 0), COALESCE(comment_id, 0))
+DO UPDATE SET read_at = NULL, created_at = NOW()`
+
+type NotificationStore struct {
+	db *sql.DB
+}
+
+// Create records a notification unless it is about the user's own action, or
+// the user and the actor blocked one another, in which case n.ID is left 0.
+func (s *NotificationStore) Create(ctx context.Context, n *Notification) error {
+	query := `
+	INSERT INTO notifications (user_id,actor_id,kind,post_id,comment_id)
+	SELECT $1::bigint, $2::bigint, $3::varchar, $4::bigint, $5::bigint
+	WHERE $1 <> $2 AND NOT EXISTS (
+		SELECT 1 FROM user_blocks AS b
+		WHERE (b.blocker_id = $1 AND b.blocked_id = $2) OR (b.blocker_id = $2 AND b.blocked_id = $1)
+	)` + upsertNotification + `
+	RETURNING id, created_at`
+
+	ctx, cancel := context.WithTimeout(ctx, queryTimeoutDuration)
+	defer cancel()
+
+	err := s.db.QueryRowContext(ctx, query, n.UserId, n.ActorId, n.Kind, n.PostId, n.CommentId).Scan(&n.ID, &n.CreatedAt)
+	if err != nil && err != sql.ErrNoRows {
+		return err
+	}
+
+	return nil
+}
+
+// CreateMentions notifies the users mentioned in a post or comment. Like
+// Create it skips the author and blocks, and it also skips users the author
+// hides their content from, who could not open what they are mentioned in.
+// Nothing is recorded once the post or comment was deleted.
+func (s *NotificationStore) CreateMentions(ctx context.Context, e MentionEvent) ([]Notification, error) {
+	query := `
+	INSERT INTO notifications (user_id,actor_id,kind,post_id,comment_id)
+	SELECT m.user_id, $2::bigint, $3::varchar, $4::bigint, $5::bigint
+	FROM unnest($1::bigint[]) AS m(user_id)
+	WHERE m.user_id <> $2 AND NOT EXISTS (
+		SELECT 1 FROM user_blocks AS b
+		WHERE (b.blocker_id = m.user_id AND b.blocked_id = $2) OR (b.blocker_id = $2 AND b.blocked_id = m.user_id)
+	) AND ` + visibleTo("$2", "m.user_id") + ` AND
+		($4 IS NULL OR EXISTS (SELECT 1 FROM posts WHERE id = $4)) AND
+		($5 IS NULL OR EXISTS (SELECT 1 FROM comments WHERE id = $5))` + upsertNotification + `
+	RETURNING id, user_id, created_at`
+
+	ctx, cancel := context.WithTimeout(ctx, queryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, pq.Array(e.UserIds), e.ActorId, NotificationMention, e.PostId, e.CommentId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	notifications := make([]Notification, 0, len(e.UserIds))
+	for rows.Next() {
+		n := Notification{Kind: NotificationMention, ActorId: e.ActorId, PostId: e.PostId, CommentId: e.CommentId}
+		if err := rows.Scan(&n.ID, &n.UserId, &n.CreatedAt); err != nil {
+			return nil, err
+		}
+		notifications = append(notifications, n)
+	}
+
+	return notifications, rows.Err()
+}
+
+// GetForUser returns a page of the notifications of a user, most recent
+// first, paginated by (created_at, id).
+func (s *NotificationStore) GetForUser(ctx context.Context, userId int, cursor *FeedCursor, limit int) ([]Notification, error) {
+	var cursorTime *time.Time
+	var cursorId int
+	if cursor != nil {
+		cursorTime = &cursor.CreatedAt
+		cursorId = cursor.ID
+	}
+
+	query := `
+	SELECT n.id, n.kind, u.id, u.username, n.post_id, n.comment_id, n.read_at IS NOT NULL, n.created_at
+	FROM notifications AS n
+	INNER JOIN users AS u ON u.id = n.actor_id
+	WHERE n.user_id = $1 AND u.is_active = true AND
+		($3::timestamptz IS NULL OR (n.created_at, n.id) < ($3, $4))
+	ORDER BY n.created_at DESC, n.id DESC
+	LIMIT $2
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, queryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, userId, limit, cursorTime, cursorId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	notifications := make([]Notification, 0)
+	for rows.Next() {
+		n := Notification{UserId: userId}
+		if err := rows.Scan(
+			&n.ID,
+			&n.Kind,
+			&n.Actor.ID,
+			&n.Actor.UserName,
+			&n.PostId,
+			&n.CommentId,
+			&n.Read,
+			&n.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		n.ActorId = n.Actor.ID
+
+		notifications = append(notifications, n)
+	}
+
+	return notifications, rows.Err()
+}
+
+func (s *NotificationStore) CountUnread(ctx context.Context, userId int) (int, error) {
+	query := `SELECT COUNT(*) FROM notifications WHERE user_id = $1 AND read_at IS NULL`
+
+	ctx, cancel := context.WithTimeout(ctx, queryTimeoutDuration)
+	defer cancel()
+
+	var count int
+	err := s.db.QueryRowContext(ctx, query, userId).Scan(&count)
+
+	return count, err
+}
+
+// MarkRead marks the given notifications of a user as read, or all of them
+// when ids is empty.
+func (s *NotificationStore) MarkRead(ctx context.Context, userId int, ids []int) error {
+	query := `
+	UPDATE notifications SET read_at = NOW()
+	WHERE user_id = $1 AND read_at IS NULL AND (cardinality($2::bigint[]) = 0 OR id = ANY($2))
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, queryTimeoutDuration)
+	defer cancel()
+
+	notificationIds := make([]int64, len(ids))
+	for i, id := range ids {
+		notificationIds[i] = int64(id)
+	}
+
+	_, err := s.db.ExecContext(ctx, query, userId, pq.Array(notificationIds))
+
+	return err
+}
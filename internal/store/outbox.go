@@ -0,0 +1,175 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// Domain events written to the outbox. Events sharing a name with a webhook
+// event carry the same payload.
+const (
+	OutboxUserInvited            = "user.invited"
+	OutboxUserActivated          = "user.activated"
+	OutboxPasswordResetRequested = "password.reset_requested"
+	OutboxPostCreated            = "post.created"
+	OutboxPostDeleted            = "post.deleted"
+	OutboxCommentCreated         = "comment.created"
+	OutboxUserFollowed           = "user.followed"
+	OutboxFollowRequested        = "follow.requested"
+	OutboxUsersMentioned         = "users.mentioned"
+)
+
+// TokenEvent records that a one-time token was issued to a user. Only the
+// hash of the token is recorded, the email linking to the plain token is
+// queued in the same transaction.
+type TokenEvent struct {
+	UserId    int    `json:"user_id"`
+	TokenHash string `json:"token_hash"`
+}
+
+type FollowEvent struct {
+	FollowerId int `json:"follower_id"`
+	FollowedId int `json:"followed_id"`
+}
+
+// OutboxEvent is a domain event committed along with the change it describes.
+// The idempotency key identifies the event across redeliveries, handlers
+// with external side effects use it to avoid repeating them.
+type OutboxEvent struct {
+	ID             int
+	Event          string
+	IdempotencyKey string
+	Payload        json.RawMessage
+	Attempts       int
+	CreatedAt      time.Time
+	// Handled lists the handlers that already succeeded for the event.
+	Handled []string
+}
+
+// addToOutbox records an event in the transaction of the change it describes,
+// so the event exists if and only if the change was committed. An event with
+// the same key is only recorded once.
+func addToOutbox(ctx context.Context, tx *sql.Tx, event, key string, data any) error {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	query := `
+	INSERT INTO outbox (event,idempotency_key,payload)
+	VALUES ($1,$2,$3)
+	ON CONFLICT (idempotency_key) DO NOTHING
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, queryTimeoutDuration)
+	defer cancel()
+
+	_, err = tx.ExecContext(ctx, query, event, key, string(payload))
+
+	return err
+}
+
+func outboxKey(event string, parts ...any) string {
+	key := event
+	for _, part := range parts {
+		key += fmt.Sprintf(":%v", part)
+	}
+
+	return key
+}
+
+type OutboxStore struct {
+	db *sql.DB
+}
+
+var outboxQueue = queue{
+	table: "outbox",
+	due: `
+		SELECT q.id FROM outbox AS q
+		WHERE q.processed_at IS NULL AND q.next_attempt_at <= NOW()
+		ORDER BY q.id`,
+	returning: `q.id, q.event, q.idempotency_key, q.payload, q.attempts, q.created_at,
+		ARRAY(SELECT h.handler FROM outbox_handled AS h WHERE h.event_id = q.id)`,
+}
+
+// ClaimDue claims up to limit unprocessed events that are due, oldest first.
+func (s *OutboxStore) ClaimDue(ctx context.Context, limit int, lease time.Duration) ([]OutboxEvent, error) {
+	events, err := claim(ctx, s.db, outboxQueue, limit, lease, func(rows *sql.Rows, e *OutboxEvent) error {
+		return rows.Scan(
+			&e.ID,
+			&e.Event,
+			&e.IdempotencyKey,
+			&e.Payload,
+			&e.Attempts,
+			&e.CreatedAt,
+			pq.Array(&e.Handled),
+		)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// the update does not order its output
+	slices.SortFunc(events, func(a, b OutboxEvent) int { return a.ID - b.ID })
+
+	return events, nil
+}
+
+// MarkHandled records that a handler succeeded for an event so it is not run
+// again when another handler fails.
+func (s *OutboxStore) MarkHandled(ctx context.Context, id int, handler string) error {
+	query := `
+	INSERT INTO outbox_handled (event_id,handler) VALUES ($1,$2)
+	ON CONFLICT DO NOTHING
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, queryTimeoutDuration)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, query, id, handler)
+
+	return err
+}
+
+func (s *OutboxStore) MarkProcessed(ctx context.Context, id int) error {
+	query := `UPDATE outbox SET processed_at = NOW(), last_error = NULL WHERE id = $1`
+
+	ctx, cancel := context.WithTimeout(ctx, queryTimeoutDuration)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, query, id)
+
+	return err
+}
+
+func (s *OutboxStore) MarkFailed(ctx context.Context, id int, lastError string, retryAt time.Time) error {
+	query := `UPDATE outbox SET last_error = $2, next_attempt_at = $3 WHERE id = $1`
+
+	ctx, cancel := context.WithTimeout(ctx, queryTimeoutDuration)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, query, id, lastError, retryAt)
+
+	return err
+}
+
+// PurgeProcessed deletes the events processed more than retention ago.
+func (s *OutboxStore) PurgeProcessed(ctx context.Context, retention time.Duration) (int64, error) {
+	query := `DELETE FROM outbox WHERE processed_at < $1`
+
+	ctx, cancel := context.WithTimeout(ctx, queryTimeoutDuration)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, query, time.Now().Add(-retention))
+	if err != nil {
+		return 0, err
+	}
+
+	return res.RowsAffected()
+}
@@ -1,20 +1,142 @@
 package store
 
 import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
 	"strconv"
 	"strings"
 	"time"
 )
 
+// TimeRange bounds a list by creation time. Both ends are optional and
+// inclusive.
+type TimeRange struct {
+	Since *time.Time `json:"since"`
+	Until *time.Time `json:"until"`
+}
+
+// Parse reads the since and until query parameters, in RFC 3339 or
+// time.DateTime format.
+func (tr *TimeRange) Parse(r *http.Request) error {
+	qv := r.URL.Query()
+
+	since := qv.Get("since")
+	if since != "" {
+		t, err := parseTime(since)
+		if err != nil {
+			return fmt.Errorf("invalid since: %w", err)
+		}
+
+		tr.Since = &t
+	}
+
+	until := qv.Get("until")
+	if until != "" {
+		t, err := parseTime(until)
+		if err != nil {
+			return fmt.Errorf("invalid until: %w", err)
+		}
+
+		tr.Until = &t
+	}
+
+	if tr.Since != nil && tr.Until != nil && tr.Since.After(*tr.Until) {
+		return errors.New("since must not be after until")
+	}
+
+	return nil
+}
+
+// condition returns the SQL bounding column by the range, with the since and
+// until values expected at the given placeholder positions.
+func (tr TimeRange) condition(column string, sincePos, untilPos int) string {
+	return fmt.Sprintf(
+		"($%[2]d::timestamptz IS NULL OR %[1]s >= $%[2]d) AND ($%[3]d::timestamptz IS NULL OR %[1]s <= $%[3]d)",
+		column, sincePos, untilPos,
+	)
+}
+
+type PaginatedQuery struct {
+	Limit  int    `json:"limit" validate:"gte=1,lte=50"`
+	Offset int    `json:"offset" validate:"gte=0"`
+	Sort   string `json:"sort" validate:"oneof=asc desc"`
+	TimeRange
+}
+
+func (pq *PaginatedQuery) Parse(r *http.Request) error {
+	qv := r.URL.Query()
+	limit := qv.Get("limit")
+	if limit != "" {
+		l, err := strconv.Atoi(limit)
+		if err != nil {
+			return err
+		}
+
+		pq.Limit = l
+	}
+
+	offset := qv.Get("offset")
+	if offset != "" {
+		off, err := strconv.Atoi(offset)
+		if err != nil {
+			return err
+		}
+
+		pq.Offset = off
+	}
+
+	sort := qv.Get("sort")
+	if sort != "" {
+		pq.Sort = sort
+	}
+
+	return pq.TimeRange.Parse(r)
+}
+
+// FeedCursor points at the last post of a feed page. The next page starts
+// right after it in (created_at, id) order, so posts published while a user
+// scrolls neither shift nor repeat entries. Followers lists page the same way
+// by the time of the follow and the user id.
+type FeedCursor struct {
+	CreatedAt time.Time `json:"t"`
+	ID        int       `json:"id"`
+}
+
+// Encode returns the cursor as an opaque string clients pass back as is.
+func (c FeedCursor) Encode() string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func DecodeFeedCursor(s string) (*FeedCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, errInvalidCursor
+	}
+
+	var c FeedCursor
+	if err := json.Unmarshal(data, &c); err != nil || c.ID < 1 {
+		return nil, errInvalidCursor
+	}
+
+	return &c, nil
+}
+
+var errInvalidCursor = errors.New("invalid cursor")
+
+// PaginatedFeedQeury pages through the feed either by offset or, when Cursor
+// is set, by keyset in which case Offset is ignored.
 type PaginatedFeedQeury struct {
-	Limit  int      `json:"limit" validate:"gte=1,lte=20"`
-	Offset int      `json:"offset" validate:"gte=0"`
-	Sort   string   `json:"sort" validate:"oneof=asc desc"`
-	Tags   []string `json:"tags" validate:"max=5"`
-	Search string   `json:"search" validate:"max=100"`
-	Since  string   `json:"since"`
-	Until  string   `json:"until"`
+	Limit  int         `json:"limit" validate:"gte=1,lte=20"`
+	Offset int         `json:"offset" validate:"gte=0"`
+	Sort   string      `json:"sort" validate:"oneof=asc desc"`
+	Tags   []string    `json:"tags" validate:"max=5"`
+	Search string      `json:"search" validate:"max=100"`
+	Cursor *FeedCursor `json:"-"`
+	TimeRange
 }
 
 func (fq *PaginatedFeedQeury) Parse(r *http.Request) error {
@@ -46,7 +168,7 @@ func (fq *PaginatedFeedQeury) Parse(r *http.Request) error {
 
 	tags := qv.Get("tags")
 	if len(tags) > 0 {
-		fq.Tags = strings.Split(tags, ",")
+		fq.Tags = NormalizeTags(strings.Split(tags, ","))
 	}
 
 	search := qv.Get("search")
@@ -54,23 +176,29 @@ func (fq *PaginatedFeedQeury) Parse(r *http.Request) error {
 		fq.Search = search
 	}
 
-	since := qv.Get("since")
-	if since != "" {
-		fq.Since = parseTime(since)
-	}
+	cursor := qv.Get("cursor")
+	if cursor != "" {
+		c, err := DecodeFeedCursor(cursor)
+		if err != nil {
+			return err
+		}
 
-	until := qv.Get("until")
-	if until != "" {
-		fq.Until = parseTime(until)
+		fq.Cursor = c
 	}
 
-	return nil
+	return fq.TimeRange.Parse(r)
 }
 
-func parseTime(s string) string {
-	t, err := time.Parse(time.DateTime, s)
+func parseTime(s string) (time.Time, error) {
+	t, err := time.Parse(time.RFC3339, s)
+	if err == nil {
+		return t, nil
+	}
+
+	t, err = time.Parse(time.DateTime, s)
 	if err != nil {
-		return ""
+		return time.Time{}, fmt.Errorf("%q is neither RFC 3339 nor %q", s, time.DateTime)
 	}
-	return t.Format(time.DateOnly)
+
+	return t, nil
 }
@@ -0,0 +1,63 @@
+package store
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestFeedCursor(t *testing.T) {
+	t.Run("should round trip through its encoded form", func(t *testing.T) {
+		cursor := FeedCursor{CreatedAt: time.Date(2025, 3, 1, 12, 30, 0, 0, time.UTC), ID: 42}
+
+		decoded, err := DecodeFeedCursor(cursor.Encode())
+		if err != nil {
+			t.Fatalf("error: %s\n", err.Error())
+		}
+
+		if decoded.ID != cursor.ID || !decoded.CreatedAt.Equal(cursor.CreatedAt) {
+			t.Errorf("expected cursor %+v and got %+v", cursor, *decoded)
+		}
+	})
+
+	t.Run("should reject malformed cursors when parsing the feed query", func(t *testing.T) {
+		for _, raw := range []string{"not-base64!", "e30", "bm90IGpzb24"} {
+			fq := PaginatedFeedQeury{}
+			req := httptest.NewRequest("GET", "/v1/users/feed?cursor="+raw, nil)
+
+			if err := fq.Parse(req); err == nil {
+				t.Errorf("expected cursor %q to be rejected", raw)
+			}
+		}
+	})
+}
+
+func TestTimeRangeParse(t *testing.T) {
+	t.Run("should accept RFC 3339 and date time values", func(t *testing.T) {
+		var tr TimeRange
+		req := httptest.NewRequest("GET", "/?since=2025-01-02T03:04:05Z&until=2025-02-01+00:00:00", nil)
+
+		if err := tr.Parse(req); err != nil {
+			t.Fatalf("error: %s\n", err.Error())
+		}
+
+		if tr.Since == nil || !tr.Since.Equal(time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)) {
+			t.Errorf("unexpected since %v", tr.Since)
+		}
+
+		if tr.Until == nil || !tr.Until.Equal(time.Date(2025, 2, 1, 0, 0, 0, 0, time.UTC)) {
+			t.Errorf("unexpected until %v", tr.Until)
+		}
+	})
+
+	t.Run("should reject invalid values and inverted ranges", func(t *testing.T) {
+		for _, query := range []string{"since=yesterday", "until=2025-13-01T00:00:00Z", "since=2025-02-01T00:00:00Z&until=2025-01-01T00:00:00Z"} {
+			var tr TimeRange
+			req := httptest.NewRequest("GET", "/?"+query, nil)
+
+			if err := tr.Parse(req); err == nil {
+				t.Errorf("expected %q to be rejected", query)
+			}
+		}
+	})
+}
@@ -16,16 +16,19 @@ type PostStore struct {
 }
 
 type Post struct {
-	ID        int       `json:"id"`
-	Content   string    `json:"content"`
-	Title     string    `json:"title"`
-	UserId    int       `json:"user_id"`
-	Tags      []string  `json:"tags"`
-	Version   int       `json:"version"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
-	Comments  []Comment `json:"comments"`
-	User      User      `json:"user"`
+	ID         int            `json:"id"`
+	Content    string         `json:"content"`
+	Title      string         `json:"title"`
+	UserId     int            `json:"user_id"`
+	Tags       []string       `json:"tags"`
+	Version    int            `json:"version"`
+	CreatedAt  time.Time      `json:"created_at"`
+	UpdatedAt  time.Time      `json:"updated_at"`
+	Comments   []Comment      `json:"comments"`
+	User       User           `json:"user"`
+	Reactions  ReactionCounts `json:"reactions"`
+	MyReaction *string        `json:"my_reaction"`
+	Mentions   []Mention      `json:"mentions"`
 }
 
 type PostWithMetadata struct {
@@ -40,26 +43,35 @@ func (s *PostStore) Create(ctx context.Context, post *Post) error {
 
 	`
 
-	ctx, cancel := context.WithTimeout(ctx, queryTimeoutDuration)
-	defer cancel()
+	post.Tags = NormalizeTags(post.Tags)
 
-	err := s.db.QueryRowContext(
-		ctx,
-		query,
-		post.Content,
-		post.Title,
-		post.UserId,
-		pq.Array(post.Tags)).
-		Scan(
-			&post.ID,
-			&post.CreatedAt,
-			&post.UpdatedAt,
-		)
-	if err != nil {
-		return err
-	}
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, queryTimeoutDuration)
+		defer cancel()
+
+		err := tx.QueryRowContext(
+			ctx,
+			query,
+			post.Content,
+			post.Title,
+			post.UserId,
+			pq.Array(post.Tags)).
+			Scan(
+				&post.ID,
+				&post.CreatedAt,
+				&post.UpdatedAt,
+			)
+		if err != nil {
+			return err
+		}
+
+		post.Mentions, err = setMentions(ctx, tx, "post_id", post.ID, post.Version, post.UserId, post.Content)
+		if err != nil {
+			return err
+		}
 
-	return nil
+		return addToOutbox(ctx, tx, OutboxPostCreated, outboxKey(OutboxPostCreated, post.ID), post)
+	})
 }
 
 func (s *PostStore) GetById(ctx context.Context, postID int) (*Post, error) {
@@ -89,6 +101,13 @@ func (s *PostStore) GetById(ctx context.Context, postID int) (*Post, error) {
 			return nil, err
 		}
 	}
+
+	mentions, err := loadMentions(ctx, s.db, "post_id", []int64{int64(post.ID)})
+	if err != nil {
+		return nil, err
+	}
+	post.Mentions = MentionEntities(post.Content, mentions[post.ID])
+
 	return post, nil
 }
 
@@ -97,24 +116,26 @@ func (s *PostStore) Delete(ctx context.Context, postID int) error {
 	DELETE FROM posts 
 	WHERE id = $1
 	`
-	ctx, cancel := context.WithTimeout(ctx, queryTimeoutDuration)
-	defer cancel()
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, queryTimeoutDuration)
+		defer cancel()
 
-	res, err := s.db.ExecContext(ctx, query, postID)
-	if err != nil {
-		return err
-	}
+		res, err := tx.ExecContext(ctx, query, postID)
+		if err != nil {
+			return err
+		}
 
-	rows, err := res.RowsAffected()
-	if err != nil {
-		return err
-	}
+		rows, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
 
-	if rows == 0 {
-		return ErrNotFound
-	}
+		if rows == 0 {
+			return ErrNotFound
+		}
 
-	return nil
+		return addToOutbox(ctx, tx, OutboxPostDeleted, outboxKey(OutboxPostDeleted, postID), map[string]int{"id": postID})
+	})
 }
 
 func (s *PostStore) Update(ctx context.Context, post *Post) error {
@@ -124,71 +145,182 @@ func (s *PostStore) Update(ctx context.Context, post *Post) error {
 	WHERE id = $1 AND version = $2
 	RETURNING updated_at,version
 	`
-	ctx, cancel := context.WithTimeout(ctx, queryTimeoutDuration)
-	defer cancel()
+	post.Tags = NormalizeTags(post.Tags)
 
-	if err := s.db.QueryRowContext(ctx, query,
-		post.ID,
-		post.Version,
-		post.Title,
-		post.Content,
-		pq.Array(post.Tags),
-	).Scan(
-		&post.UpdatedAt,
-		&post.Version,
-	); err != nil {
-		switch {
-		case errors.Is(err, sql.ErrNoRows):
-			return ErrNotFound
-		default:
-			return err
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, queryTimeoutDuration)
+		defer cancel()
+
+		if err := tx.QueryRowContext(ctx, query,
+			post.ID,
+			post.Version,
+			post.Title,
+			post.Content,
+			pq.Array(post.Tags),
+		).Scan(
+			&post.UpdatedAt,
+			&post.Version,
+		); err != nil {
+			switch {
+			case errors.Is(err, sql.ErrNoRows):
+				return ErrNotFound
+			default:
+				return err
+			}
 		}
-	}
 
-	return nil
+		var err error
+		post.Mentions, err = setMentions(ctx, tx, "post_id", post.ID, post.Version, post.UserId, post.Content)
+
+		return err
+	})
+}
+
+// postWithMetadataColumns selects a post aliased as p joined with its author
+// as u, along with the metadata seen by the viewer at the given placeholder.
+func postWithMetadataColumns(viewerPlaceholder string) string {
+	return `p.id,p.user_id,p.title,p.content,p.created_at,p.version,p.tags,u.username,
+	(SELECT COUNT(*) FROM comments AS c WHERE c.post_id = p.id) AS comments_count,
+	` + reactionColumns(viewerPlaceholder)
 }
 
 func (s *PostStore) GetUserFeed(ctx context.Context, userId int, fq PaginatedFeedQeury) ([]PostWithMetadata, error) {
+	// the keyset comparison follows the sort direction so the cursor always
+	// points towards the rest of the feed
+	cmp := "<"
+	if fq.Sort == "asc" {
+		cmp = ">"
+	}
+
+	var cursorTime *time.Time
+	var cursorId int
+	offset := fq.Offset
+	if fq.Cursor != nil {
+		cursorTime = &fq.Cursor.CreatedAt
+		cursorId = fq.Cursor.ID
+		offset = 0
+	}
+
+	// a row (user_id, follower_id) in followers means user_id follows
+	// follower_id, the feed is made of the user's own posts and the posts of
+	// everyone they follow
 	query := `
-SELECT p.id,p.user_id,p.title,p.content,p.created_at,p.version,p.tags,username,COUNT(c.id) AS comments_count
-FROM posts as p
-LEFT JOIN comments AS c ON c.post_id = p.id
-LEFT JOIN users AS u ON u.id = p.user_id
-INNER JOIN followers AS f ON f.follower_id = p.user_id OR p.user_id = $1
+SELECT ` + postWithMetadataColumns("$1") + `
+FROM posts AS p
+INNER JOIN users AS u ON u.id = p.user_id
 WHERE 
-	f.user_id = $1 AND 
+	(p.user_id = $1 OR EXISTS (
+		SELECT 1 FROM followers AS f WHERE f.user_id = $1 AND f.follower_id = p.user_id
+	)) AND
+	` + visibleTo("p.user_id", "$1") + ` AND
+	` + notMutedBy("p.user_id", "$1") + ` AND
 	(p.title ILIKE '%' || $4 || '%' OR p.content ILIKE '%' || $4 || '%') AND
-	(p.tags @> $5 OR $5 = '{}')
-GROUP BY p.id,u.username` + fmt.Sprintf(" ORDER BY p.created_at %s ", strings.ToUpper(fq.Sort)) + `LIMIT $2 OFFSET $3;`
+	(p.tags @> $5 OR $5 = '{}') AND
+	($6::timestamptz IS NULL OR (p.created_at, p.id) ` + cmp + ` ($6, $7)) AND
+	` + fq.TimeRange.condition("p.created_at", 8, 9) + fmt.Sprintf(" ORDER BY p.created_at %s, p.id %s ", strings.ToUpper(fq.Sort), strings.ToUpper(fq.Sort)) + `LIMIT $2 OFFSET $3;`
 
 	ctx, cancel := context.WithTimeout(ctx, queryTimeoutDuration)
 	defer cancel()
 
-	rows, err := s.db.QueryContext(ctx, query, userId, fq.Limit, fq.Offset, pq.Array(fq.Tags))
+	return s.queryWithMetadata(ctx, query, userId, fq.Limit, offset, fq.Search, pq.Array(fq.Tags), cursorTime, cursorId, fq.Since, fq.Until)
+}
+
+// GetByIds returns the posts with the given ids, as seen by the viewer, in
+// the order of ids. Ids of posts that no longer exist or that the viewer may
+// not see in their feed are skipped.
+func (s *PostStore) GetByIds(ctx context.Context, viewerId int, ids []int) ([]PostWithMetadata, error) {
+	if len(ids) == 0 {
+		return []PostWithMetadata{}, nil
+	}
+
+	query := `
+	SELECT ` + postWithMetadataColumns("$1") + `
+	FROM posts AS p
+	INNER JOIN users AS u ON u.id = p.user_id
+	WHERE p.id = ANY($2) AND
+		` + visibleTo("p.user_id", "$1") + ` AND
+		` + notMutedBy("p.user_id", "$1") + `
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, queryTimeoutDuration)
+	defer cancel()
+
+	postIds := make([]int64, len(ids))
+	for i, id := range ids {
+		postIds[i] = int64(id)
+	}
+
+	posts, err := s.queryWithMetadata(ctx, query, viewerId, pq.Array(postIds))
+	if err != nil {
+		return nil, err
+	}
+
+	byId := make(map[int]PostWithMetadata, len(posts))
+	for _, post := range posts {
+		byId[post.ID] = post
+	}
+
+	ordered := make([]PostWithMetadata, 0, len(posts))
+	for _, id := range ids {
+		if post, ok := byId[id]; ok {
+			ordered = append(ordered, post)
+		}
+	}
+
+	return ordered, nil
+}
+
+func (s *PostStore) queryWithMetadata(ctx context.Context, query string, args ...any) ([]PostWithMetadata, error) {
+	rows, err := s.db.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	var feed []PostWithMetadata
+	feed := make([]PostWithMetadata, 0)
 	for rows.Next() {
 		var pwd PostWithMetadata
-		if err := rows.Scan(
-			&pwd.ID,
-			&pwd.UserId,
-			&pwd.Title,
-			&pwd.Content,
-			&pwd.CreatedAt,
-			&pwd.Version,
-			pq.Array(&pwd.Tags),
-			&pwd.User.UserName,
-			&pwd.CommentsCount,
-		); err != nil {
+		if err := rows.Scan(pwd.scanDest()...); err != nil {
 			return nil, err
 		}
 
 		feed = append(feed, pwd)
 	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	ids := make([]int64, len(feed))
+	for i, post := range feed {
+		ids[i] = int64(post.ID)
+	}
+
+	mentions, err := loadMentions(ctx, s.db, "post_id", ids)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range feed {
+		feed[i].Mentions = MentionEntities(feed[i].Content, mentions[feed[i].ID])
+	}
 
 	return feed, nil
 }
+
+// scanDest returns the destinations of the columns selected by
+// postWithMetadataColumns.
+func (pwd *PostWithMetadata) scanDest() []any {
+	return []any{
+		&pwd.ID,
+		&pwd.UserId,
+		&pwd.Title,
+		&pwd.Content,
+		&pwd.CreatedAt,
+		&pwd.Version,
+		pq.Array(&pwd.Tags),
+		&pwd.User.UserName,
+		&pwd.CommentsCount,
+		&pwd.Reactions,
+		&pwd.MyReaction,
+	}
+}
@@ -0,0 +1,131 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+)
+
+// newTestDB connects to the migrated database in TEST_DB_ADDR, tests that
+// need one are skipped when it is not set.
+func newTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+
+	addr := os.Getenv("TEST_DB_ADDR")
+	if addr == "" {
+		t.Skip("TEST_DB_ADDR is not set")
+	}
+
+	db, err := sql.Open("postgres", addr)
+	if err != nil {
+		t.Fatalf("error: %s\n", err.Error())
+	}
+	t.Cleanup(func() { db.Close() })
+
+	if err := db.Ping(); err != nil {
+		t.Fatalf("error: %s\n", err.Error())
+	}
+
+	return db
+}
+
+func createTestUsers(t *testing.T, s Storage, db *sql.DB, names ...string) []*User {
+	t.Helper()
+	ctx := context.Background()
+	suffix := time.Now().UnixNano()
+
+	users := make([]*User, len(names))
+	err := withTx(db, ctx, func(tx *sql.Tx) error {
+		for i, name := range names {
+			users[i] = &User{
+				UserName: fmt.Sprintf("%s%d", name, suffix),
+				Email:    fmt.Sprintf("%s%d@example.com", name, suffix),
+			}
+			if err := users[i].Password.Set("password"); err != nil {
+				return err
+			}
+
+			if err := s.Users.Create(ctx, tx, users[i]); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("error: %s\n", err.Error())
+	}
+
+	t.Cleanup(func() {
+		for _, user := range users {
+			db.Exec(`DELETE FROM posts WHERE user_id = $1`, user.ID)
+			db.Exec(`DELETE FROM users WHERE id = $1`, user.ID)
+		}
+	})
+
+	return users
+}
+
+func TestGetUserFeed(t *testing.T) {
+	db := newTestDB(t)
+	s := NewStorage(db)
+	ctx := context.Background()
+
+	users := createTestUsers(t, s, db, "reader", "author", "stranger", "fan")
+	reader, author, stranger, fan := users[0], users[1], users[2], users[3]
+
+	// both the reader and the fan follow the author, which used to duplicate
+	// the author's posts once per follower row
+	for _, follower := range []*User{reader, fan} {
+		if err := s.Followers.Follow(ctx, follower.ID, author.ID); err != nil {
+			t.Fatalf("error: %s\n", err.Error())
+		}
+	}
+
+	postsBy := map[int]*Post{}
+	for _, user := range []*User{reader, author, stranger} {
+		post := &Post{UserId: user.ID, Title: "title", Content: "content", Tags: []string{}}
+		if err := s.Posts.Create(ctx, post); err != nil {
+			t.Fatalf("error: %s\n", err.Error())
+		}
+		postsBy[user.ID] = post
+	}
+
+	fq := PaginatedFeedQeury{Limit: 20, Sort: "desc"}
+
+	t.Run("should contain own and followed posts exactly once", func(t *testing.T) {
+		feed, err := s.Posts.GetUserFeed(ctx, reader.ID, fq)
+		if err != nil {
+			t.Fatalf("error: %s\n", err.Error())
+		}
+
+		seen := map[int]int{}
+		for _, post := range feed {
+			seen[post.ID]++
+		}
+
+		for _, want := range []*Post{postsBy[reader.ID], postsBy[author.ID]} {
+			if seen[want.ID] != 1 {
+				t.Errorf("expected post %d once in the feed and got it %d times", want.ID, seen[want.ID])
+			}
+		}
+
+		if seen[postsBy[stranger.ID].ID] != 0 {
+			t.Errorf("expected post %d of an unfollowed user not to be in the feed", postsBy[stranger.ID].ID)
+		}
+	})
+
+	t.Run("should contain own posts of a user following nobody", func(t *testing.T) {
+		feed, err := s.Posts.GetUserFeed(ctx, stranger.ID, fq)
+		if err != nil {
+			t.Fatalf("error: %s\n", err.Error())
+		}
+
+		if len(feed) != 1 || feed[0].ID != postsBy[stranger.ID].ID {
+			t.Errorf("expected only post %d in the feed and got %+v", postsBy[stranger.ID].ID, feed)
+		}
+	})
+}
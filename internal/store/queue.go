@@ -0,0 +1,57 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// queue describes a table of jobs claimed by workers with a lease. The rows
+// have an id, an attempts count and a next_attempt_at time.
+type queue struct {
+	table string
+	// due selects the ids of the claimable rows of the table aliased as q,
+	// in the order they are claimed. It can select more columns for
+	// returning, through the due alias.
+	due string
+	// returning lists the columns of the claimed rows.
+	returning string
+}
+
+// claim picks up to limit due rows of the queue and counts an attempt for
+// each. The rows are leased: their next attempt is pushed back by lease, so
+// a row that is neither settled nor failed by then, for example because the
+// worker stopped, is claimed again. Rows locked by other workers are
+// skipped.
+func claim[T any](ctx context.Context, db *sql.DB, q queue, limit int, lease time.Duration, scan func(*sql.Rows, *T) error) ([]T, error) {
+	query := `
+	WITH due AS (` + q.due + `
+		LIMIT $1
+		FOR UPDATE OF q SKIP LOCKED
+	)
+	UPDATE ` + q.table + ` AS q
+	SET attempts = q.attempts + 1, next_attempt_at = NOW() + make_interval(secs => $2)
+	FROM due
+	WHERE q.id = due.id
+	RETURNING ` + q.returning
+
+	ctx, cancel := context.WithTimeout(ctx, queryTimeoutDuration)
+	defer cancel()
+
+	rows, err := db.QueryContext(ctx, query, limit, lease.Seconds())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	claimed := make([]T, 0)
+	for rows.Next() {
+		var row T
+		if err := scan(rows, &row); err != nil {
+			return nil, err
+		}
+		claimed = append(claimed, row)
+	}
+
+	return claimed, rows.Err()
+}
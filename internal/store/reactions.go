@@ -0,0 +1,121 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+)
+
+// ReactionKinds are the reactions a user can leave on a post.
+var ReactionKinds = []string{"like", "love", "laugh", "wow", "sad", "angry"}
+
+func IsValidReactionKind(kind string) bool {
+	return slices.Contains(ReactionKinds, kind)
+}
+
+// ReactionCounts maps a reaction kind to the number of users that reacted
+// with it. It scans from a JSON object built by the database.
+type ReactionCounts map[string]int
+
+func (rc *ReactionCounts) Scan(src any) error {
+	var data []byte
+	switch v := src.(type) {
+	case nil:
+		*rc = ReactionCounts{}
+		return nil
+	case []byte:
+		data = v
+	case string:
+		data = []byte(v)
+	default:
+		return fmt.Errorf("cannot scan %T into ReactionCounts", src)
+	}
+
+	counts := ReactionCounts{}
+	if err := json.Unmarshal(data, &counts); err != nil {
+		return err
+	}
+	*rc = counts
+
+	return nil
+}
+
+// reactionColumns selects the per-kind counts of a post aliased as p and the
+// reaction of the user passed as the given placeholder.
+func reactionColumns(userPlaceholder string) string {
+	return `COALESCE((
+		SELECT jsonb_object_agg(kind, total) FROM (
+			SELECT pr.kind, COUNT(*) AS total FROM post_reactions AS pr
+			WHERE pr.post_id = p.id GROUP BY pr.kind
+		) AS rk
+	), '{}'::jsonb),
+	(SELECT pr.kind FROM post_reactions AS pr WHERE pr.post_id = p.id AND pr.user_id = ` + userPlaceholder + `)`
+}
+
+type ReactionStore struct {
+	db *sql.DB
+}
+
+// Set records the reaction of a user to a post, replacing the previous one.
+func (s *ReactionStore) Set(ctx context.Context, postId, userId int, kind string) error {
+	query := `
+	INSERT INTO post_reactions (post_id,user_id,kind) VALUES ($1,$2,$3)
+	ON CONFLICT (post_id,user_id) DO UPDATE SET kind = EXCLUDED.kind, created_at = NOW()
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, queryTimeoutDuration)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, query, postId, userId, kind)
+
+	return err
+}
+
+func (s *ReactionStore) Remove(ctx context.Context, postId, userId int, kind string) error {
+	query := `DELETE FROM post_reactions WHERE post_id = $1 AND user_id = $2 AND kind = $3`
+
+	ctx, cancel := context.WithTimeout(ctx, queryTimeoutDuration)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, query, postId, userId, kind)
+	if err != nil {
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// GetForPost returns the reaction counts of a post and the reaction the user
+// left on it, if any.
+func (s *ReactionStore) GetForPost(ctx context.Context, postId, userId int) (ReactionCounts, *string, error) {
+	query := `SELECT ` + reactionColumns("$2") + ` FROM posts AS p WHERE p.id = $1`
+
+	ctx, cancel := context.WithTimeout(ctx, queryTimeoutDuration)
+	defer cancel()
+
+	var counts ReactionCounts
+	var own *string
+
+	if err := s.db.QueryRowContext(ctx, query, postId, userId).Scan(&counts, &own); err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, nil, ErrNotFound
+		default:
+			return nil, nil, err
+		}
+	}
+
+	return counts, own, nil
+}
@@ -0,0 +1,124 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"maps"
+	"testing"
+)
+
+func TestReactionCountsScan(t *testing.T) {
+	for name, tc := range map[string]struct {
+		src  any
+		want ReactionCounts
+	}{
+		"null":   {src: nil, want: ReactionCounts{}},
+		"bytes":  {src: []byte(`{"like": 2, "wow": 1}`), want: ReactionCounts{"like": 2, "wow": 1}},
+		"string": {src: `{"sad": 3}`, want: ReactionCounts{"sad": 3}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			var counts ReactionCounts
+			if err := counts.Scan(tc.src); err != nil {
+				t.Fatalf("error: %s\n", err.Error())
+			}
+
+			if !maps.Equal(counts, tc.want) {
+				t.Errorf("expected %v and got %v", tc.want, counts)
+			}
+		})
+	}
+}
+
+func TestReactions(t *testing.T) {
+	db := newTestDB(t)
+	s := NewStorage(db)
+	ctx := context.Background()
+
+	users := createTestUsers(t, s, db, "reacted", "reactor", "liker")
+	author, reactor, liker := users[0], users[1], users[2]
+
+	post := &Post{UserId: author.ID, Title: "title", Content: "content", Tags: []string{}}
+	if err := s.Posts.Create(ctx, post); err != nil {
+		t.Fatalf("error: %s\n", err.Error())
+	}
+
+	set := func(t *testing.T, user *User, kind string) {
+		t.Helper()
+
+		if err := s.Reactions.Set(ctx, post.ID, user.ID, kind); err != nil {
+			t.Fatalf("error: %s\n", err.Error())
+		}
+	}
+
+	check := func(t *testing.T, viewer *User, want ReactionCounts, wantOwn string) {
+		t.Helper()
+
+		counts, own, err := s.Reactions.GetForPost(ctx, post.ID, viewer.ID)
+		if err != nil {
+			t.Fatalf("error: %s\n", err.Error())
+		}
+
+		if !maps.Equal(counts, want) {
+			t.Errorf("expected counts %v and got %v", want, counts)
+		}
+
+		var got string
+		if own != nil {
+			got = *own
+		}
+
+		if got != wantOwn {
+			t.Errorf("expected own reaction %q and got %q", wantOwn, got)
+		}
+	}
+
+	t.Run("should replace the previous reaction of a user", func(t *testing.T) {
+		set(t, reactor, "like")
+		set(t, reactor, "love")
+
+		check(t, reactor, ReactionCounts{"love": 1}, "love")
+	})
+
+	t.Run("should count the reactions of every user per kind", func(t *testing.T) {
+		set(t, liker, "like")
+
+		check(t, reactor, ReactionCounts{"love": 1, "like": 1}, "love")
+		check(t, liker, ReactionCounts{"love": 1, "like": 1}, "like")
+		check(t, author, ReactionCounts{"love": 1, "like": 1}, "")
+	})
+
+	t.Run("should return the counts and own reaction along with the post", func(t *testing.T) {
+		posts, err := s.Posts.GetByIds(ctx, liker.ID, []int{post.ID})
+		if err != nil {
+			t.Fatalf("error: %s\n", err.Error())
+		}
+
+		if len(posts) != 1 {
+			t.Fatalf("expected post %d and got %+v", post.ID, posts)
+		}
+
+		if !maps.Equal(posts[0].Reactions, ReactionCounts{"love": 1, "like": 1}) {
+			t.Errorf("expected the counts of the post and got %v", posts[0].Reactions)
+		}
+
+		if posts[0].MyReaction == nil || *posts[0].MyReaction != "like" {
+			t.Errorf("expected own reaction like and got %v", posts[0].MyReaction)
+		}
+	})
+
+	t.Run("should not remove a kind the user did not react with", func(t *testing.T) {
+		if err := s.Reactions.Remove(ctx, post.ID, reactor.ID, "like"); !errors.Is(err, ErrNotFound) {
+			t.Errorf("expected %v and got %v", ErrNotFound, err)
+		}
+
+		check(t, reactor, ReactionCounts{"love": 1, "like": 1}, "love")
+	})
+
+	t.Run("should remove the reaction of the user", func(t *testing.T) {
+		if err := s.Reactions.Remove(ctx, post.ID, reactor.ID, "love"); err != nil {
+			t.Fatalf("error: %s\n", err.Error())
+		}
+
+		check(t, reactor, ReactionCounts{"like": 1}, "")
+	})
+}
@@ -0,0 +1,227 @@
+package store
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"html"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// SearchCursor points at the last result of a search page. Scores depend on
+// the age of the posts, so they are computed as of the time of the first
+// page to stay comparable across pages.
+type SearchCursor struct {
+	AsOf  time.Time `json:"t"`
+	Score float64   `json:"s"`
+	ID    int       `json:"id"`
+}
+
+func (c SearchCursor) Encode() string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func DecodeSearchCursor(s string) (*SearchCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, errInvalidCursor
+	}
+
+	var c SearchCursor
+	if err := json.Unmarshal(data, &c); err != nil || c.ID < 1 || c.AsOf.IsZero() {
+		return nil, errInvalidCursor
+	}
+
+	return &c, nil
+}
+
+// PostSearchQuery pages through search results by keyset. AsOf is the
+// reference time of the scores, now on the first page and the time carried by
+// the cursor on the next ones.
+type PostSearchQuery struct {
+	Query  string        `json:"q" validate:"required,max=100"`
+	Tags   []string      `json:"tags" validate:"max=5"`
+	Limit  int           `json:"limit" validate:"gte=1,lte=20"`
+	Cursor *SearchCursor `json:"-"`
+	AsOf   time.Time     `json:"-"`
+}
+
+func (sq *PostSearchQuery) Parse(r *http.Request) error {
+	qv := r.URL.Query()
+
+	sq.AsOf = time.Now()
+
+	sq.Query = strings.TrimSpace(qv.Get("q"))
+
+	limit := qv.Get("limit")
+	if limit != "" {
+		l, err := strconv.Atoi(limit)
+		if err != nil {
+			return err
+		}
+
+		sq.Limit = l
+	}
+
+	tags := qv.Get("tags")
+	if len(tags) > 0 {
+		sq.Tags = NormalizeTags(strings.Split(tags, ","))
+	}
+
+	cursor := qv.Get("cursor")
+	if cursor != "" {
+		c, err := DecodeSearchCursor(cursor)
+		if err != nil {
+			return err
+		}
+
+		sq.Cursor = c
+		sq.AsOf = c.AsOf
+	}
+
+	return nil
+}
+
+// PostSearchResult is a post matching a search with the matched words of its
+// title and content wrapped in <mark> tags. The rest of the highlights is
+// HTML escaped, so they can be rendered as HTML.
+type PostSearchResult struct {
+	PostWithMetadata
+	TitleHighlight string  `json:"title_highlight"`
+	Snippet        string  `json:"snippet"`
+	Score          float64 `json:"score"`
+}
+
+// searchScore ranks a post by text relevance plus a recency boost that
+// halves every week, with the query in $2 and the reference time in $5.
+const searchScore = `(
+	ts_rank(p.search_vector, websearch_to_tsquery('english', $2))::float8 +
+	0.1 * exp(-ln(2) * GREATEST(EXTRACT(EPOCH FROM ($5::timestamptz - p.created_at))::float8, 0) / 604800)
+)`
+
+// Matches are delimited by characters from the private use area, which are
+// stripped from the posts beforehand, and only turned into <mark> tags once
+// the highlight is escaped.
+const (
+	highlightStart = "\uE000"
+	highlightStop  = "\uE001"
+)
+
+const highlightOptions = `StartSel="` + highlightStart + `", StopSel="` + highlightStop + `"`
+
+var highlightReplacer = strings.NewReplacer(highlightStart, "<mark>", highlightStop, "</mark>")
+
+// renderHighlight escapes the output of ts_headline and marks its matches.
+func renderHighlight(headline string) string {
+	return highlightReplacer.Replace(html.EscapeString(headline))
+}
+
+// Search returns a page of the posts visible to the viewer matching the query,
+// best first.
+func (s *PostStore) Search(ctx context.Context, viewerId int, sq PostSearchQuery) ([]PostSearchResult, error) {
+	var cursorScore *float64
+	var cursorId int
+	if sq.Cursor != nil {
+		cursorScore = &sq.Cursor.Score
+		cursorId = sq.Cursor.ID
+	}
+
+	query := `
+	SELECT ` + postWithMetadataColumns("$1") + `,
+		ts_headline('english', translate(p.title, $8::text, ''), websearch_to_tsquery('english', $2), 'HighlightAll=true, ' || $9::text),
+		ts_headline('english', translate(p.content, $8::text, ''), websearch_to_tsquery('english', $2), 'MaxFragments=2, MaxWords=20, MinWords=5, ' || $9::text),
+		` + searchScore + ` AS score
+	FROM posts AS p
+	INNER JOIN users AS u ON u.id = p.user_id
+	WHERE p.search_vector @@ websearch_to_tsquery('english', $2) AND
+		(p.tags @> $3 OR $3 = '{}') AND
+		` + visibleTo("p.user_id", "$1") + ` AND
+		($6::float8 IS NULL OR (` + searchScore + `, p.id) < ($6, $7))
+	ORDER BY score DESC, p.id DESC
+	LIMIT $4
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, queryTimeoutDuration)
+	defer cancel()
+
+	tags := sq.Tags
+	if tags == nil {
+		tags = []string{}
+	}
+
+	rows, err := s.db.QueryContext(ctx, query, viewerId, sq.Query, pq.Array(tags), sq.Limit, sq.AsOf, cursorScore, cursorId,
+		highlightStart+highlightStop, highlightOptions)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	results := make([]PostSearchResult, 0)
+	for rows.Next() {
+		var res PostSearchResult
+		dest := append(res.scanDest(), &res.TitleHighlight, &res.Snippet, &res.Score)
+		if err := rows.Scan(dest...); err != nil {
+			return nil, err
+		}
+
+		res.TitleHighlight = renderHighlight(res.TitleHighlight)
+		res.Snippet = renderHighlight(res.Snippet)
+
+		results = append(results, res)
+	}
+
+	return results, rows.Err()
+}
+
+// UserSearchResult is a user matching a username search.
+type UserSearchResult struct {
+	ID        int    `json:"id"`
+	UserName  string `json:"username"`
+	Followers int    `json:"followers"`
+}
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// Search returns the active users whose username starts with or is similar
+// to q, prefix matches first, then by similarity and follower count. Users
+// that blocked the viewer or that the viewer blocked are left out.
+func (s *UserStore) Search(ctx context.Context, viewerId int, q string, limit int) ([]UserSearchResult, error) {
+	query := `
+	SELECT u.id, u.username, u.follower_count
+	FROM users AS u
+	WHERE u.is_active = true AND
+		(lower(u.username) LIKE lower($2) || '%' OR u.username % $3) AND
+		NOT EXISTS (
+			SELECT 1 FROM user_blocks AS b
+			WHERE (b.blocker_id = u.id AND b.blocked_id = $1) OR (b.blocker_id = $1 AND b.blocked_id = u.id)
+		)
+	ORDER BY lower(u.username) LIKE lower($2) || '%' DESC, similarity(u.username, $3) DESC, u.follower_count DESC, u.id
+	LIMIT $4
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, queryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, viewerId, likeEscaper.Replace(q), q, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	users := make([]UserSearchResult, 0)
+	for rows.Next() {
+		var u UserSearchResult
+		if err := rows.Scan(&u.ID, &u.UserName, &u.Followers); err != nil {
+			return nil, err
+		}
+		users = append(users, u)
+	}
+
+	return users, rows.Err()
+}
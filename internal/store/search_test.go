@@ -0,0 +1,101 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestPostSearchQueryParse(t *testing.T) {
+	t.Run("should score the next pages as of the first one", func(t *testing.T) {
+		asOf := time.Date(2025, 3, 1, 12, 30, 0, 0, time.UTC)
+		cursor := SearchCursor{AsOf: asOf, Score: 0.0607927, ID: 42}
+
+		sq := PostSearchQuery{}
+		req := httptest.NewRequest("GET", "/v1/search/posts?q=go&cursor="+cursor.Encode(), nil)
+
+		if err := sq.Parse(req); err != nil {
+			t.Fatalf("error: %s\n", err.Error())
+		}
+
+		if sq.Cursor == nil || *sq.Cursor != cursor {
+			t.Errorf("expected cursor %+v and got %+v", cursor, sq.Cursor)
+		}
+
+		if !sq.AsOf.Equal(asOf) {
+			t.Errorf("expected scores as of %v and got %v", asOf, sq.AsOf)
+		}
+	})
+
+	t.Run("should reject malformed cursors", func(t *testing.T) {
+		for _, raw := range []string{"not-base64!", "e30", "bm90IGpzb24"} {
+			sq := PostSearchQuery{}
+			req := httptest.NewRequest("GET", "/v1/search/posts?q=go&cursor="+raw, nil)
+
+			if err := sq.Parse(req); err == nil {
+				t.Errorf("expected cursor %q to be rejected", raw)
+			}
+		}
+	})
+}
+
+func TestRenderHighlight(t *testing.T) {
+	headline := `<img src=x onerror="alert(1)"> ` + highlightStart + `gopher` + highlightStop + ` & co`
+	want := `&lt;img src=x onerror=&#34;alert(1)&#34;&gt; <mark>gopher</mark> &amp; co`
+
+	if got := renderHighlight(headline); got != want {
+		t.Errorf("expected %q and got %q", want, got)
+	}
+}
+
+func TestSearchPosts(t *testing.T) {
+	db := newTestDB(t)
+	s := NewStorage(db)
+	ctx := context.Background()
+
+	users := createTestUsers(t, s, db, "searcher", "writer")
+	searcher, writer := users[0], users[1]
+
+	word := fmt.Sprintf("zebra%d", time.Now().UnixNano())
+	for i := 0; i < 3; i++ {
+		post := &Post{UserId: writer.ID, Title: "<b>about</b> " + word, Content: "content", Tags: []string{}}
+		if err := s.Posts.Create(ctx, post); err != nil {
+			t.Fatalf("error: %s\n", err.Error())
+		}
+	}
+
+	sq := PostSearchQuery{Query: word, Limit: 2, AsOf: time.Now()}
+
+	first, err := s.Posts.Search(ctx, searcher.ID, sq)
+	if err != nil {
+		t.Fatalf("error: %s\n", err.Error())
+	}
+
+	if len(first) != 2 {
+		t.Fatalf("expected a full first page and got %d results", len(first))
+	}
+
+	if want := "&lt;b&gt;about&lt;/b&gt; <mark>" + word + "</mark>"; first[0].TitleHighlight != want {
+		t.Errorf("expected the title highlight %q and got %q", want, first[0].TitleHighlight)
+	}
+
+	last := first[len(first)-1]
+	sq.Cursor = &SearchCursor{AsOf: sq.AsOf, Score: last.Score, ID: last.ID}
+
+	second, err := s.Posts.Search(ctx, searcher.ID, sq)
+	if err != nil {
+		t.Fatalf("error: %s\n", err.Error())
+	}
+
+	if len(second) != 1 {
+		t.Fatalf("expected the last result on the second page and got %d results", len(second))
+	}
+
+	for _, res := range first {
+		if res.ID == second[0].ID {
+			t.Errorf("expected post %d only once across pages", res.ID)
+		}
+	}
+}
@@ -0,0 +1,178 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+var ErrTokenReused = errors.New("refresh token has already been used")
+
+type Session struct {
+	ID        string     `json:"id"`
+	UserId    int        `json:"user_id"`
+	RevokedAt *time.Time `json:"revoked_at"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+type SessionStore struct {
+	db *sql.DB
+}
+
+func (s *SessionStore) Create(ctx context.Context, session *Session, token string, exp time.Duration) error {
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		query := `
+		INSERT INTO sessions (id,user_id) VALUES ($1,$2)
+		RETURNING created_at
+		`
+
+		ctx, cancel := context.WithTimeout(ctx, queryTimeoutDuration)
+		defer cancel()
+
+		if err := tx.QueryRowContext(ctx, query, session.ID, session.UserId).Scan(&session.CreatedAt); err != nil {
+			return err
+		}
+
+		return s.createRefreshToken(ctx, tx, session.ID, token, exp)
+	})
+}
+
+// Rotate exchanges a refresh token for a new one within the same session.
+// Presenting a token that was already rotated revokes the whole session.
+func (s *SessionStore) Rotate(ctx context.Context, oldToken, newToken string, exp time.Duration) (*Session, error) {
+	session := &Session{}
+	reused := false
+
+	err := withTx(s.db, ctx, func(tx *sql.Tx) error {
+		query := `
+		SELECT s.id,s.user_id,s.revoked_at,s.created_at,rt.expiry,rt.used_at
+		FROM refresh_tokens AS rt
+		JOIN sessions AS s ON s.id = rt.session_id
+		WHERE rt.token = $1
+		FOR UPDATE
+		`
+
+		ctx, cancel := context.WithTimeout(ctx, queryTimeoutDuration)
+		defer cancel()
+
+		var expiry time.Time
+		var usedAt *time.Time
+
+		err := tx.QueryRowContext(ctx, query, hashToken(oldToken)).Scan(
+			&session.ID,
+			&session.UserId,
+			&session.RevokedAt,
+			&session.CreatedAt,
+			&expiry,
+			&usedAt,
+		)
+		if err != nil {
+			switch {
+			case errors.Is(err, sql.ErrNoRows):
+				return ErrNotFound
+			default:
+				return err
+			}
+		}
+
+		if session.RevokedAt != nil || expiry.Before(time.Now()) {
+			return ErrNotFound
+		}
+
+		if usedAt != nil {
+			reused = true
+			return s.revoke(ctx, tx, session.ID)
+		}
+
+		if _, err := tx.ExecContext(ctx, `UPDATE refresh_tokens SET used_at = NOW() WHERE token = $1`, hashToken(oldToken)); err != nil {
+			return err
+		}
+
+		return s.createRefreshToken(ctx, tx, session.ID, newToken, exp)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if reused {
+		return nil, ErrTokenReused
+	}
+
+	return session, nil
+}
+
+func (s *SessionStore) RevokeByToken(ctx context.Context, token string) error {
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		query := `SELECT session_id FROM refresh_tokens WHERE token = $1`
+
+		ctx, cancel := context.WithTimeout(ctx, queryTimeoutDuration)
+		defer cancel()
+
+		var sessionId string
+		if err := tx.QueryRowContext(ctx, query, hashToken(token)).Scan(&sessionId); err != nil {
+			switch {
+			case errors.Is(err, sql.ErrNoRows):
+				return ErrNotFound
+			default:
+				return err
+			}
+		}
+
+		return s.revoke(ctx, tx, sessionId)
+	})
+}
+
+func (s *SessionStore) IsActive(ctx context.Context, sessionId string) (bool, error) {
+	query := `SELECT revoked_at IS NULL FROM sessions WHERE id = $1`
+
+	ctx, cancel := context.WithTimeout(ctx, queryTimeoutDuration)
+	defer cancel()
+
+	var active bool
+	if err := s.db.QueryRowContext(ctx, query, sessionId).Scan(&active); err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return false, nil
+		default:
+			return false, err
+		}
+	}
+
+	return active, nil
+}
+
+func (s *SessionStore) createRefreshToken(ctx context.Context, tx *sql.Tx, sessionId, token string, exp time.Duration) error {
+	query := `
+	INSERT INTO refresh_tokens (token,session_id,expiry)
+	VALUES ($1,$2,$3)
+	`
+	ctx, cancel := context.WithTimeout(ctx, queryTimeoutDuration)
+	defer cancel()
+
+	_, err := tx.ExecContext(ctx, query, hashToken(token), sessionId, time.Now().Add(exp))
+
+	return err
+}
+
+func (s *SessionStore) revoke(ctx context.Context, tx *sql.Tx, sessionId string) error {
+	query := `UPDATE sessions SET revoked_at = NOW() WHERE id = $1 AND revoked_at IS NULL`
+
+	ctx, cancel := context.WithTimeout(ctx, queryTimeoutDuration)
+	defer cancel()
+
+	_, err := tx.ExecContext(ctx, query, sessionId)
+
+	return err
+}
+
+func revokeUserSessions(ctx context.Context, tx *sql.Tx, userId int) error {
+	query := `UPDATE sessions SET revoked_at = NOW() WHERE user_id = $1 AND revoked_at IS NULL`
+
+	ctx, cancel := context.WithTimeout(ctx, queryTimeoutDuration)
+	defer cancel()
+
+	_, err := tx.ExecContext(ctx, query, userId)
+
+	return err
+}
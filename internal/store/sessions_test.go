@@ -0,0 +1,109 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestSessionStore(t *testing.T) {
+	db := newTestDB(t)
+	s := NewStorage(db)
+	ctx := context.Background()
+
+	user := createTestUsers(t, s, db, "sessions")[0]
+
+	newSession := func(t *testing.T) (*Session, string) {
+		t.Helper()
+
+		session := &Session{ID: uuid.New().String(), UserId: user.ID}
+		token := uuid.New().String()
+		if err := s.Sessions.Create(ctx, session, token, time.Hour); err != nil {
+			t.Fatalf("error: %s\n", err.Error())
+		}
+		t.Cleanup(func() { db.Exec(`DELETE FROM sessions WHERE id = $1`, session.ID) })
+
+		return session, token
+	}
+
+	isActive := func(t *testing.T, sessionId string) bool {
+		t.Helper()
+
+		active, err := s.Sessions.IsActive(ctx, sessionId)
+		if err != nil {
+			t.Fatalf("error: %s\n", err.Error())
+		}
+
+		return active
+	}
+
+	t.Run("should rotate a refresh token within the session", func(t *testing.T) {
+		session, token := newSession(t)
+
+		rotated, err := s.Sessions.Rotate(ctx, token, uuid.New().String(), time.Hour)
+		if err != nil {
+			t.Fatalf("error: %s\n", err.Error())
+		}
+
+		if rotated.ID != session.ID || rotated.UserId != user.ID {
+			t.Errorf("expected session %s of user %d and got %+v", session.ID, user.ID, rotated)
+		}
+
+		if !isActive(t, session.ID) {
+			t.Error("expected the session to stay active")
+		}
+	})
+
+	t.Run("should revoke the session when a rotated token is reused", func(t *testing.T) {
+		session, token := newSession(t)
+		next := uuid.New().String()
+
+		if _, err := s.Sessions.Rotate(ctx, token, next, time.Hour); err != nil {
+			t.Fatalf("error: %s\n", err.Error())
+		}
+
+		if _, err := s.Sessions.Rotate(ctx, token, uuid.New().String(), time.Hour); !errors.Is(err, ErrTokenReused) {
+			t.Fatalf("expected %v and got %v", ErrTokenReused, err)
+		}
+
+		if isActive(t, session.ID) {
+			t.Error("expected the session to be revoked")
+		}
+
+		if _, err := s.Sessions.Rotate(ctx, next, uuid.New().String(), time.Hour); !errors.Is(err, ErrNotFound) {
+			t.Errorf("expected the latest token of a revoked session to be rejected and got %v", err)
+		}
+	})
+
+	t.Run("should reject an expired refresh token", func(t *testing.T) {
+		session := &Session{ID: uuid.New().String(), UserId: user.ID}
+		token := uuid.New().String()
+		if err := s.Sessions.Create(ctx, session, token, -time.Minute); err != nil {
+			t.Fatalf("error: %s\n", err.Error())
+		}
+		t.Cleanup(func() { db.Exec(`DELETE FROM sessions WHERE id = $1`, session.ID) })
+
+		if _, err := s.Sessions.Rotate(ctx, token, uuid.New().String(), time.Hour); !errors.Is(err, ErrNotFound) {
+			t.Errorf("expected %v and got %v", ErrNotFound, err)
+		}
+	})
+
+	t.Run("should revoke the session on logout", func(t *testing.T) {
+		session, token := newSession(t)
+
+		if err := s.Sessions.RevokeByToken(ctx, token); err != nil {
+			t.Fatalf("error: %s\n", err.Error())
+		}
+
+		if isActive(t, session.ID) {
+			t.Error("expected the session to be revoked")
+		}
+
+		if _, err := s.Sessions.Rotate(ctx, token, uuid.New().String(), time.Hour); !errors.Is(err, ErrNotFound) {
+			t.Errorf("expected the token of a revoked session to be rejected and got %v", err)
+		}
+	})
+}
@@ -2,7 +2,9 @@ package store
 
 import (
 	"context"
+	"crypto/sha256"
 	"database/sql"
+	"encoding/hex"
 	"errors"
 	"time"
 )
@@ -20,39 +22,131 @@ type Storage struct {
 		Delete(context.Context, int) error
 		Update(context.Context, *Post) error
 		GetUserFeed(context.Context, int, PaginatedFeedQeury) ([]PostWithMetadata, error)
+		GetByIds(context.Context, int, []int) ([]PostWithMetadata, error)
+		Search(context.Context, int, PostSearchQuery) ([]PostSearchResult, error)
+		GetByTag(context.Context, int, string, *FeedCursor, int) ([]PostWithMetadata, error)
+		GetTrendingTags(context.Context, time.Duration, int) ([]TrendingTag, error)
 	}
 
 	Users interface {
-		Activate(context.Context, string) error
+		Activate(context.Context, string) (*User, error)
 		Create(context.Context, *sql.Tx, *User) error
 		GetById(context.Context, int) (*User, error)
 		GetByEmail(context.Context, string) (*User, error)
-		CreateAndInvite(context.Context, *User, string, time.Duration) error
+		Authenticate(context.Context, string, string, int, time.Duration) (*User, error)
+		CreateAndInvite(context.Context, *User, string, time.Duration, EmailFunc) error
 		createUserInvitation(context.Context, *sql.Tx, string, time.Duration, int) error
+		ReissueInvitation(context.Context, string, string, time.Duration, EmailFunc) (*User, error)
+		PurgeInactive(context.Context, time.Duration) (int64, error)
 		Delete(context.Context, int) error
+		CreatePasswordReset(context.Context, string, string, time.Duration, EmailFunc) (*User, error)
+		ResetPassword(context.Context, string, string) error
+		GetCounts(context.Context, int) (*UserCounts, error)
+		SetPrivate(context.Context, int, bool) error
+		SetLanguage(context.Context, int, string) error
+		CanView(context.Context, int, int) (bool, error)
+		Search(context.Context, int, string, int) ([]UserSearchResult, error)
 	}
 
 	Comments interface {
 		Create(context.Context, *Comment) error
-		GetByPostId(context.Context, int) ([]Comment, error)
+		GetById(context.Context, int) (*Comment, error)
+		GetByPostId(context.Context, int, int, PaginatedQuery) ([]Comment, error)
+		GetReplies(context.Context, int, int, int, int) ([]Comment, error)
+		Update(context.Context, *Comment) error
+		Delete(context.Context, int) error
 	}
 
 	Followers interface {
 		Follow(context.Context, int, int) error
 		Unfollow(context.Context, int, int) error
+		GetFollowerIds(context.Context, int) ([]int, error)
+		CountFollowers(context.Context, int) (int, error)
+		FollowsPopular(context.Context, int, int) (bool, error)
+		GetFollowers(context.Context, int, *FeedCursor, int) ([]Connection, error)
+		GetFollowing(context.Context, int, *FeedCursor, int) ([]Connection, error)
+		GetRelationship(context.Context, int, int) (*Relationship, error)
+		RequestFollow(context.Context, int, int) error
+		GetFollowRequests(context.Context, int, *FeedCursor, int) ([]Connection, error)
+		ApproveFollowRequest(context.Context, int, int) error
+		RejectFollowRequest(context.Context, int, int) error
 	}
 	Roles interface {
 		GetByName(context.Context, string) (*Role, error)
 	}
+
+	Notifications interface {
+		Create(context.Context, *Notification) error
+		CreateMentions(context.Context, MentionEvent) ([]Notification, error)
+		GetForUser(context.Context, int, *FeedCursor, int) ([]Notification, error)
+		CountUnread(context.Context, int) (int, error)
+		MarkRead(context.Context, int, []int) error
+	}
+
+	Blocks interface {
+		Block(context.Context, int, int) error
+		Unblock(context.Context, int, int) error
+		Mute(context.Context, int, int) error
+		Unmute(context.Context, int, int) error
+	}
+
+	Reactions interface {
+		Set(context.Context, int, int, string) error
+		Remove(context.Context, int, int, string) error
+		GetForPost(context.Context, int, int) (ReactionCounts, *string, error)
+	}
+
+	Webhooks interface {
+		Create(context.Context, *Webhook) error
+		GetAll(context.Context) ([]Webhook, error)
+		GetById(context.Context, int) (*Webhook, error)
+		Update(context.Context, *Webhook) error
+		Delete(context.Context, int) error
+		Enqueue(context.Context, string, string, any) error
+		ClaimDue(context.Context, int, time.Duration) ([]DueDelivery, error)
+		MarkDelivered(context.Context, int, int) error
+		MarkFailed(context.Context, int, int, string, *time.Time) error
+		GetDeliveries(context.Context, int, *FeedCursor, int) ([]WebhookDelivery, error)
+		Redeliver(context.Context, int, int) error
+	}
+
+	Outbox interface {
+		ClaimDue(context.Context, int, time.Duration) ([]OutboxEvent, error)
+		MarkHandled(context.Context, int, string) error
+		MarkProcessed(context.Context, int) error
+		MarkFailed(context.Context, int, string, time.Time) error
+		PurgeProcessed(context.Context, time.Duration) (int64, error)
+	}
+
+	Emails interface {
+		ClaimDue(context.Context, int, time.Duration) ([]QueuedEmail, error)
+		MarkSent(context.Context, int) error
+		MarkFailed(context.Context, int, string, *time.Time) error
+		PurgeSettled(context.Context, time.Duration) (int64, error)
+	}
+
+	Sessions interface {
+		Create(context.Context, *Session, string, time.Duration) error
+		Rotate(context.Context, string, string, time.Duration) (*Session, error)
+		RevokeByToken(context.Context, string) error
+		IsActive(context.Context, string) (bool, error)
+	}
 }
 
 func NewStorage(db *sql.DB) Storage {
 	return Storage{
-		Posts:     &PostStore{db},
-		Users:     &UserStore{db},
-		Comments:  &CommentStore{db},
-		Followers: &FollowerStore{db},
-		Roles:     &RoleStore{db},
+		Posts:         &PostStore{db},
+		Users:         &UserStore{db},
+		Comments:      &CommentStore{db},
+		Followers:     &FollowerStore{db},
+		Roles:         &RoleStore{db},
+		Reactions:     &ReactionStore{db},
+		Sessions:      &SessionStore{db},
+		Blocks:        &BlockStore{db},
+		Notifications: &NotificationStore{db},
+		Webhooks:      &WebhookStore{db},
+		Outbox:        &OutboxStore{db},
+		Emails:        &EmailStore{db},
 	}
 }
 
@@ -70,3 +164,10 @@ func withTx(db *sql.DB, ctx context.Context, fn func(*sql.Tx) error) error {
 
 	return tx.Commit()
 }
+
+// hashToken returns the hex encoded sha256 of a plain token, which is how
+// one-time tokens are persisted.
+func hashToken(token string) string {
+	hash := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(hash[:])
+}
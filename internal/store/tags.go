@@ -0,0 +1,109 @@
+package store
+
+import (
+	"context"
+	"strings"
+	"time"
+)
+
+// NormalizeTags lower cases tags, drops a leading # and collapses
+// whitespace, removing the tags left empty and the duplicates.
+func NormalizeTags(tags []string) []string {
+	normalized := make([]string, 0, len(tags))
+	seen := make(map[string]bool, len(tags))
+
+	for _, tag := range tags {
+		tag = strings.TrimPrefix(strings.TrimSpace(tag), "#")
+		tag = strings.ToLower(strings.Join(strings.Fields(tag), " "))
+
+		if tag == "" || seen[tag] {
+			continue
+		}
+
+		seen[tag] = true
+		normalized = append(normalized, tag)
+	}
+
+	return normalized
+}
+
+// TrendingTag is a tag used in Count posts during the last window, against
+// PreviousCount posts during the window before it.
+type TrendingTag struct {
+	Tag           string  `json:"tag"`
+	Count         int     `json:"count"`
+	PreviousCount int     `json:"previous_count"`
+	Growth        float64 `json:"growth"`
+}
+
+// minTrendingCount is the number of posts a tag needs in a window to trend,
+// so a single post does not show as infinite growth.
+const minTrendingCount = 3
+
+// GetByTag returns a page of the posts with the given tag visible to the
+// viewer, newest first.
+func (s *PostStore) GetByTag(ctx context.Context, viewerId int, tag string, cursor *FeedCursor, limit int) ([]PostWithMetadata, error) {
+	var cursorTime *time.Time
+	var cursorId int
+	if cursor != nil {
+		cursorTime = &cursor.CreatedAt
+		cursorId = cursor.ID
+	}
+
+	query := `
+	SELECT ` + postWithMetadataColumns("$1") + `
+	FROM posts AS p
+	INNER JOIN users AS u ON u.id = p.user_id
+	WHERE p.tags @> ARRAY[$2]::varchar[] AND
+		` + visibleTo("p.user_id", "$1") + ` AND
+		($4::timestamptz IS NULL OR (p.created_at, p.id) < ($4, $5))
+	ORDER BY p.created_at DESC, p.id DESC
+	LIMIT $3
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, queryTimeoutDuration)
+	defer cancel()
+
+	return s.queryWithMetadata(ctx, query, viewerId, tag, limit, cursorTime, cursorId)
+}
+
+// GetTrendingTags returns the tags of public posts that grew the most during
+// the last window compared to the window before it.
+func (s *PostStore) GetTrendingTags(ctx context.Context, window time.Duration, limit int) ([]TrendingTag, error) {
+	query := `
+	SELECT tag, recent, previous, (recent - previous)::float8 / (previous + 1) AS growth
+	FROM (
+		SELECT tag,
+			COUNT(*) FILTER (WHERE p.created_at > NOW() - $1 * interval '1 second') AS recent,
+			COUNT(*) FILTER (WHERE p.created_at <= NOW() - $1 * interval '1 second') AS previous
+		FROM posts AS p
+		INNER JOIN users AS u ON u.id = p.user_id
+		CROSS JOIN LATERAL unnest(p.tags) AS tag
+		WHERE p.created_at > NOW() - 2 * $1 * interval '1 second' AND NOT u.is_private
+		GROUP BY tag
+	) AS counts
+	WHERE recent >= $2 AND recent > previous
+	ORDER BY growth DESC, recent DESC, tag
+	LIMIT $3
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, queryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, window.Seconds(), minTrendingCount, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tags := make([]TrendingTag, 0)
+	for rows.Next() {
+		var t TrendingTag
+		if err := rows.Scan(&t.Tag, &t.Count, &t.PreviousCount, &t.Growth); err != nil {
+			return nil, err
+		}
+		tags = append(tags, t)
+	}
+
+	return tags, rows.Err()
+}
@@ -0,0 +1,15 @@
+package store
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestNormalizeTags(t *testing.T) {
+	got := NormalizeTags([]string{" GoLang ", "#golang", "Machine   Learning", "", "  #  ", "SQL"})
+	want := []string{"golang", "machine learning", "sql"}
+
+	if !slices.Equal(got, want) {
+		t.Errorf("expected %v and got %v", want, got)
+	}
+}
@@ -2,18 +2,21 @@ package store
 
 import (
 	"context"
-	"crypto/sha256"
 	"database/sql"
-	"encoding/hex"
 	"errors"
 	"time"
 
 	"golang.org/x/crypto/bcrypt"
 )
 
+// DefaultLanguage is the language of users that did not choose one.
+const DefaultLanguage = "en"
+
 var (
-	ErrDuplicateEmail    = errors.New("a user with that email already exists")
-	ErrDuplicateUsername = errors.New("a user with that username already exists")
+	ErrDuplicateEmail     = errors.New("a user with that email already exists")
+	ErrDuplicateUsername  = errors.New("a user with that username already exists")
+	ErrInvalidCredentials = errors.New("invalid credentials")
+	ErrAccountLocked      = errors.New("account is temporarily locked")
 )
 
 type User struct {
@@ -24,7 +27,20 @@ type User struct {
 	CreatedAt time.Time `json:"created_at"`
 	IsActive  bool      `json:"is_active"`
 	RoleID    int       `json:"role_id"`
-	Role      Role      `json:'role'`
+	Role      Role      `json:"role"`
+	IsPrivate bool      `json:"is_private"`
+	// Language is the BCP 47 tag of the preferred language, emails are sent
+	// in it when a translation exists.
+	Language string `json:"language"`
+	// Counts and Relationship are only set on profile responses.
+	Counts       *UserCounts   `json:"counts,omitempty"`
+	Relationship *Relationship `json:"relationship,omitempty"`
+}
+
+type UserCounts struct {
+	Followers int `json:"followers"`
+	Following int `json:"following"`
+	Posts     int `json:"posts"`
 }
 
 type password struct {
@@ -44,14 +60,18 @@ func (p *password) Set(text string) error {
 	return nil
 }
 
+func (p *password) Compare(text string) error {
+	return bcrypt.CompareHashAndPassword(p.hash, []byte(text))
+}
+
 type UserStore struct {
 	db *sql.DB
 }
 
 func (s *UserStore) Create(ctx context.Context, tx *sql.Tx, user *User) error {
 	query := `
-	INSERT INTO users (username,email,password,role_id) 
-	VALUES ($1,$2,$3,(SELECT id FROM roles WHERE name = $4)) 
+	INSERT INTO users (username,email,password,role_id,language) 
+	VALUES ($1,$2,$3,(SELECT id FROM roles WHERE name = $4),$5) 
 	RETURNING id,created_at;
 	`
 
@@ -63,11 +83,16 @@ func (s *UserStore) Create(ctx context.Context, tx *sql.Tx, user *User) error {
 		role = "user"
 	}
 
+	if user.Language == "" {
+		user.Language = DefaultLanguage
+	}
+
 	err := tx.QueryRowContext(ctx, query,
 		user.UserName,
 		user.Email,
 		user.Password.hash,
 		role,
+		user.Language,
 	).Scan(&user.ID, &user.CreatedAt)
 	if err != nil {
 		switch {
@@ -84,7 +109,7 @@ func (s *UserStore) Create(ctx context.Context, tx *sql.Tx, user *User) error {
 
 func (s *UserStore) GetById(ctx context.Context, id int) (*User, error) {
 	qeury := `
-	SELECT username,email,password,created_at, roles.* 
+	SELECT username,email,password,created_at,is_private,language, roles.* 
 	FROM users
 	JOIN roles ON users.role_id = roles.id
 	WHERE users.id = $1 AND users.is_active = true;
@@ -100,6 +125,8 @@ func (s *UserStore) GetById(ctx context.Context, id int) (*User, error) {
 		&user.Email,
 		&user.Password.hash,
 		&user.CreatedAt,
+		&user.IsPrivate,
+		&user.Language,
 		&user.Role.ID,
 		&user.Role.Name,
 		&user.Role.Level,
@@ -118,7 +145,7 @@ func (s *UserStore) GetById(ctx context.Context, id int) (*User, error) {
 
 func (s *UserStore) GetByEmail(ctx context.Context, email string) (*User, error) {
 	qeury := `
-	SELECT id,username,password,created_at 
+	SELECT id,username,password,created_at,language 
 	FROM users
 	WHERE email = $1 AND is_active = true;
 	`
@@ -133,6 +160,7 @@ func (s *UserStore) GetByEmail(ctx context.Context, email string) (*User, error)
 		&user.UserName,
 		&user.Password.hash,
 		&user.CreatedAt,
+		&user.Language,
 	)
 	if err != nil {
 		switch err {
@@ -146,23 +174,186 @@ func (s *UserStore) GetByEmail(ctx context.Context, email string) (*User, error)
 	return user, nil
 }
 
-func (s *UserStore) CreateAndInvite(ctx context.Context, user *User, token string, invitationExp time.Duration) error {
+// dummyPasswordHash is compared against when authenticating an unknown email,
+// so the response takes as long as for a wrong password and does not tell
+// whether an account exists. It has the cost of the stored passwords.
+var dummyPasswordHash = []byte("$2a$10$N0f7d3rZK9KMGkgHc3NJFe7HoP.PWXJbeYImNpKyURf7QiFoOZHSC")
+
+// Authenticate checks the password of the active user with the given email.
+// Every failed attempt is counted and once maxAttempts is reached the account
+// is locked for the lockout duration, regardless of the client address.
+func (s *UserStore) Authenticate(ctx context.Context, email, plainPassword string, maxAttempts int, lockout time.Duration) (*User, error) {
+	user := &User{Email: email}
+	failed := false
+
+	err := withTx(s.db, ctx, func(tx *sql.Tx) error {
+		query := `
+		SELECT id,username,password,created_at,locked_until
+		FROM users
+		WHERE email = $1 AND is_active = true
+		FOR UPDATE;
+		`
+
+		ctx, cancel := context.WithTimeout(ctx, queryTimeoutDuration)
+		defer cancel()
+
+		var lockedUntil *time.Time
+
+		err := tx.QueryRowContext(ctx, query, email).Scan(
+			&user.ID,
+			&user.UserName,
+			&user.Password.hash,
+			&user.CreatedAt,
+			&lockedUntil,
+		)
+		if err != nil {
+			switch {
+			case errors.Is(err, sql.ErrNoRows):
+				bcrypt.CompareHashAndPassword(dummyPasswordHash, []byte(plainPassword))
+				return ErrNotFound
+			default:
+				return err
+			}
+		}
+
+		if lockedUntil != nil && lockedUntil.After(time.Now()) {
+			return ErrAccountLocked
+		}
+
+		if err := user.Password.Compare(plainPassword); err != nil {
+			failed = true
+			return s.recordFailedLogin(ctx, tx, user.ID, maxAttempts, lockout)
+		}
+
+		return s.resetFailedLogins(ctx, tx, user.ID)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if failed {
+		return nil, ErrInvalidCredentials
+	}
+
+	return user, nil
+}
+
+func (s *UserStore) CreateAndInvite(ctx context.Context, user *User, token string, invitationExp time.Duration, email EmailFunc) error {
 	return withTx(s.db, ctx, func(tx *sql.Tx) error {
 		if err := s.Create(ctx, tx, user); err != nil {
 			return err
 		}
 
-		if err := s.createUserInvitation(ctx, tx, token, invitationExp, user.ID); err != nil {
+		if err := s.createUserInvitation(ctx, tx, hashToken(token), invitationExp, user.ID); err != nil {
 			return err
 		}
 
-		return nil
+		return issueToken(ctx, tx, OutboxUserInvited, user, token, email)
 	})
 }
 
-func (s *UserStore) Activate(ctx context.Context, token string) error {
-	return withTx(s.db, ctx, func(tx *sql.Tx) error {
-		user, err := s.getUserFromInvitation(ctx, tx, token)
+// issueToken records the event of a one-time token being issued and queues
+// the email linking to it. The plain token only reaches the email, the event
+// keeps its hash.
+func issueToken(ctx context.Context, tx *sql.Tx, event string, user *User, token string, email EmailFunc) error {
+	key := outboxKey(event, hashToken(token))
+
+	if err := addToOutbox(ctx, tx, event, key, &TokenEvent{UserId: user.ID, TokenHash: hashToken(token)}); err != nil {
+		return err
+	}
+
+	queued, err := email(user)
+	if err != nil {
+		return err
+	}
+	queued.IdempotencyKey = key
+
+	return queueEmail(ctx, tx, queued)
+}
+
+// ReissueInvitation replaces the invitations of the inactive user with the
+// given email by a new one.
+func (s *UserStore) ReissueInvitation(ctx context.Context, email, token string, invitationExp time.Duration, invitationEmail EmailFunc) (*User, error) {
+	user := &User{Email: email}
+
+	err := withTx(s.db, ctx, func(tx *sql.Tx) error {
+		query := `
+		SELECT id,username,created_at,language
+		FROM users
+		WHERE email = $1 AND is_active = false;
+		`
+		ctx, cancel := context.WithTimeout(ctx, queryTimeoutDuration)
+		defer cancel()
+
+		if err := tx.QueryRowContext(ctx, query, email).Scan(&user.ID, &user.UserName, &user.CreatedAt, &user.Language); err != nil {
+			switch {
+			case errors.Is(err, sql.ErrNoRows):
+				return ErrNotFound
+			default:
+				return err
+			}
+		}
+
+		if err := s.deleteUserInvitations(ctx, tx, user.ID); err != nil {
+			return err
+		}
+
+		if err := s.createUserInvitation(ctx, tx, hashToken(token), invitationExp, user.ID); err != nil {
+			return err
+		}
+
+		return issueToken(ctx, tx, OutboxUserInvited, user, token, invitationEmail)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
+// PurgeInactive deletes invitations that expired more than gracePeriod ago
+// and users that never activated their account within the grace period and
+// have no invitation left. A user is kept for the grace period after their
+// last invitation expired.
+func (s *UserStore) PurgeInactive(ctx context.Context, gracePeriod time.Duration) (int64, error) {
+	var deleted int64
+
+	err := withTx(s.db, ctx, func(tx *sql.Tx) error {
+		cutoff := time.Now().Add(-gracePeriod)
+
+		ctx, cancel := context.WithTimeout(ctx, queryTimeoutDuration)
+		defer cancel()
+
+		if _, err := tx.ExecContext(ctx, `DELETE FROM user_invitations WHERE expiry < $1`, cutoff); err != nil {
+			return err
+		}
+
+		// invitations reference their user without cascading, so only users
+		// whose invitations were all purged above can be deleted
+		query := `
+		DELETE FROM users
+		WHERE is_active = false AND created_at < $1 AND NOT EXISTS (
+			SELECT 1 FROM user_invitations AS ui WHERE ui.user_id = users.id
+		)
+		`
+		res, err := tx.ExecContext(ctx, query, cutoff)
+		if err != nil {
+			return err
+		}
+
+		deleted, err = res.RowsAffected()
+
+		return err
+	})
+
+	return deleted, err
+}
+
+func (s *UserStore) Activate(ctx context.Context, token string) (*User, error) {
+	var user *User
+	err := withTx(s.db, ctx, func(tx *sql.Tx) error {
+		var err error
+		user, err = s.getUserFromInvitation(ctx, tx, token)
 		if err != nil {
 			return err
 		}
@@ -176,7 +367,84 @@ func (s *UserStore) Activate(ctx context.Context, token string) error {
 			return err
 		}
 
-		return nil
+		return addToOutbox(ctx, tx, OutboxUserActivated, outboxKey(OutboxUserActivated, user.ID), user)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
+// CreatePasswordReset stores a reset token for the active user with the given
+// email, replacing any token issued before.
+func (s *UserStore) CreatePasswordReset(ctx context.Context, email, token string, exp time.Duration, resetEmail EmailFunc) (*User, error) {
+	user, err := s.GetByEmail(ctx, email)
+	if err != nil {
+		return nil, err
+	}
+
+	err = withTx(s.db, ctx, func(tx *sql.Tx) error {
+		if err := s.deletePasswordResets(ctx, tx, user.ID); err != nil {
+			return err
+		}
+
+		query := `
+		INSERT INTO password_resets (user_id,token,expiry)
+		VALUES ($1,$2,$3)
+		`
+		ctx, cancel := context.WithTimeout(ctx, queryTimeoutDuration)
+		defer cancel()
+
+		if _, err := tx.ExecContext(ctx, query, user.ID, hashToken(token), time.Now().Add(exp)); err != nil {
+			return err
+		}
+
+		return issueToken(ctx, tx, OutboxPasswordResetRequested, user, token, resetEmail)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
+// ResetPassword sets a new password for the owner of the reset token and
+// revokes every session the user had open.
+func (s *UserStore) ResetPassword(ctx context.Context, token, plainPassword string) error {
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		query := `
+		SELECT user_id FROM password_resets
+		WHERE token = $1 AND expiry > $2
+		`
+		ctx, cancel := context.WithTimeout(ctx, queryTimeoutDuration)
+		defer cancel()
+
+		user := &User{}
+		if err := tx.QueryRowContext(ctx, query, hashToken(token), time.Now()).Scan(&user.ID); err != nil {
+			switch {
+			case errors.Is(err, sql.ErrNoRows):
+				return ErrNotFound
+			default:
+				return err
+			}
+		}
+
+		if err := user.Password.Set(plainPassword); err != nil {
+			return err
+		}
+
+		if _, err := tx.ExecContext(ctx, `
+		UPDATE users SET password = $1, failed_login_attempts = 0, locked_until = NULL
+		WHERE id = $2`, user.Password.hash, user.ID); err != nil {
+			return err
+		}
+
+		if err := s.deletePasswordResets(ctx, tx, user.ID); err != nil {
+			return err
+		}
+
+		return revokeUserSessions(ctx, tx, user.ID)
 	})
 }
 
@@ -222,10 +490,7 @@ func (s *UserStore) getUserFromInvitation(ctx context.Context, tx *sql.Tx, token
 
 	user := &User{}
 
-	hash := sha256.Sum256([]byte(token))
-	hashToken := hex.EncodeToString(hash[:])
-
-	err := tx.QueryRowContext(ctx, query, hashToken, time.Now()).Scan(
+	err := tx.QueryRowContext(ctx, query, hashToken(token), time.Now()).Scan(
 		&user.ID,
 		&user.UserName,
 		&user.Email,
@@ -258,6 +523,36 @@ func (s *UserStore) update(ctx context.Context, tx *sql.Tx, user *User) error {
 	return nil
 }
 
+func (s *UserStore) recordFailedLogin(ctx context.Context, tx *sql.Tx, userId, maxAttempts int, lockout time.Duration) error {
+	query := `
+	UPDATE users SET
+		failed_login_attempts = CASE WHEN failed_login_attempts + 1 >= $2 THEN 0 ELSE failed_login_attempts + 1 END,
+		locked_until = CASE WHEN failed_login_attempts + 1 >= $2 THEN $3 ELSE locked_until END
+	WHERE id = $1
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, queryTimeoutDuration)
+	defer cancel()
+
+	_, err := tx.ExecContext(ctx, query, userId, maxAttempts, time.Now().Add(lockout))
+
+	return err
+}
+
+func (s *UserStore) resetFailedLogins(ctx context.Context, tx *sql.Tx, userId int) error {
+	query := `
+	UPDATE users SET failed_login_attempts = 0, locked_until = NULL
+	WHERE id = $1 AND (failed_login_attempts > 0 OR locked_until IS NOT NULL)
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, queryTimeoutDuration)
+	defer cancel()
+
+	_, err := tx.ExecContext(ctx, query, userId)
+
+	return err
+}
+
 func (s *UserStore) deleteUserInvitations(ctx context.Context, tx *sql.Tx, userId int) error {
 	query := `DELETE FROM user_invitations WHERE user_id = $1`
 
@@ -272,6 +567,17 @@ func (s *UserStore) deleteUserInvitations(ctx context.Context, tx *sql.Tx, userI
 	return nil
 }
 
+func (s *UserStore) deletePasswordResets(ctx context.Context, tx *sql.Tx, userId int) error {
+	query := `DELETE FROM password_resets WHERE user_id = $1`
+
+	ctx, cancel := context.WithTimeout(ctx, queryTimeoutDuration)
+	defer cancel()
+
+	_, err := tx.ExecContext(ctx, query, userId)
+
+	return err
+}
+
 func (s *UserStore) delete(ctx context.Context, tx *sql.Tx, userId int) error {
 	query := `
 	DELETE FROM users
@@ -287,3 +593,133 @@ func (s *UserStore) delete(ctx context.Context, tx *sql.Tx, userId int) error {
 
 	return nil
 }
+
+func (s *UserStore) GetCounts(ctx context.Context, userId int) (*UserCounts, error) {
+	query := `
+	SELECT
+		(SELECT COUNT(*) FROM followers WHERE follower_id = $1),
+		(SELECT COUNT(*) FROM followers WHERE user_id = $1),
+		(SELECT COUNT(*) FROM posts WHERE user_id = $1)
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, queryTimeoutDuration)
+	defer cancel()
+
+	counts := &UserCounts{}
+	if err := s.db.QueryRowContext(ctx, query, userId).Scan(
+		&counts.Followers,
+		&counts.Following,
+		&counts.Posts,
+	); err != nil {
+		return nil, err
+	}
+
+	return counts, nil
+}
+
+// SetLanguage changes the preferred language of a user.
+func (s *UserStore) SetLanguage(ctx context.Context, userId int, language string) error {
+	query := `UPDATE users SET language = $2 WHERE id = $1`
+
+	ctx, cancel := context.WithTimeout(ctx, queryTimeoutDuration)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, query, userId, language)
+	if err != nil {
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// SetPrivate changes whether the content of a user is only visible to their
+// followers. Making an account public approves its pending follow requests,
+// each with the outbox event of an approved request.
+func (s *UserStore) SetPrivate(ctx context.Context, userId int, private bool) error {
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, queryTimeoutDuration)
+		defer cancel()
+
+		res, err := tx.ExecContext(ctx, `UPDATE users SET is_private = $2 WHERE id = $1`, userId, private)
+		if err != nil {
+			return err
+		}
+
+		rows, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+
+		if rows == 0 {
+			return ErrNotFound
+		}
+
+		if private {
+			return nil
+		}
+
+		follows, err := tx.QueryContext(ctx, `
+		WITH requests AS (
+			DELETE FROM follow_requests WHERE target_id = $1
+			RETURNING requester_id
+		)
+		INSERT INTO followers (user_id,follower_id)
+		SELECT requester_id, $1 FROM requests
+		ON CONFLICT DO NOTHING
+		RETURNING user_id, created_at
+		`, userId)
+		if err != nil {
+			return err
+		}
+		defer follows.Close()
+
+		type follow struct {
+			requesterId int
+			followedAt  time.Time
+		}
+
+		approved := make([]follow, 0)
+		for follows.Next() {
+			var f follow
+			if err := follows.Scan(&f.requesterId, &f.followedAt); err != nil {
+				return err
+			}
+			approved = append(approved, f)
+		}
+		if err := follows.Err(); err != nil {
+			return err
+		}
+
+		// each approved request is announced like one approved on its own
+		for _, f := range approved {
+			if err := addFollowToOutbox(ctx, tx, f.requesterId, userId, f.followedAt); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// CanView reports whether viewerId may read the posts and comments of
+// ownerId.
+func (s *UserStore) CanView(ctx context.Context, viewerId, ownerId int) (bool, error) {
+	query := `SELECT ` + visibleTo("$2::bigint", "$1::bigint")
+
+	ctx, cancel := context.WithTimeout(ctx, queryTimeoutDuration)
+	defer cancel()
+
+	var visible bool
+	err := s.db.QueryRowContext(ctx, query, viewerId, ownerId).Scan(&visible)
+
+	return visible, err
+}
@@ -0,0 +1,279 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestPasswordCompare(t *testing.T) {
+	var p password
+	if err := p.Set("correct horse"); err != nil {
+		t.Fatalf("error: %s\n", err.Error())
+	}
+
+	t.Run("should accept the matching password", func(t *testing.T) {
+		if err := p.Compare("correct horse"); err != nil {
+			t.Errorf("expected password to match, got %v", err)
+		}
+	})
+
+	t.Run("should reject a different password", func(t *testing.T) {
+		if err := p.Compare("battery staple"); err == nil {
+			t.Error("expected password mismatch, got nil")
+		}
+	})
+}
+
+func TestDummyPasswordHash(t *testing.T) {
+	cost, err := bcrypt.Cost(dummyPasswordHash)
+	if err != nil {
+		t.Fatalf("error: %s\n", err.Error())
+	}
+
+	if cost != bcrypt.DefaultCost {
+		t.Errorf("expected dummy hash cost %d, got %d", bcrypt.DefaultCost, cost)
+	}
+}
+
+func TestPasswordReset(t *testing.T) {
+	db := newTestDB(t)
+	s := NewStorage(db)
+	ctx := context.Background()
+
+	users := createTestUsers(t, s, db, "reset", "resetinactive")
+	user, inactive := users[0], users[1]
+	if _, err := db.Exec(`UPDATE users SET is_active = true WHERE id = $1`, user.ID); err != nil {
+		t.Fatalf("error: %s\n", err.Error())
+	}
+	t.Cleanup(func() {
+		db.Exec(`DELETE FROM password_resets WHERE user_id = $1`, user.ID)
+		db.Exec(`DELETE FROM sessions WHERE user_id = $1`, user.ID)
+		db.Exec(`DELETE FROM email_queue WHERE email = $1`, user.Email)
+	})
+
+	var emails []string
+	resetEmail := func(u *User) (*QueuedEmail, error) {
+		emails = append(emails, u.Email)
+		return &QueuedEmail{Template: "reset", UserName: u.UserName, Email: u.Email, Data: []byte(`{}`)}, nil
+	}
+
+	issue := func(t *testing.T, exp time.Duration) string {
+		t.Helper()
+
+		token := uuid.New().String()
+		if _, err := s.Users.CreatePasswordReset(ctx, user.Email, token, exp, resetEmail); err != nil {
+			t.Fatalf("error: %s\n", err.Error())
+		}
+
+		return token
+	}
+
+	t.Run("should queue no email for an unknown or inactive account", func(t *testing.T) {
+		emails = nil
+
+		for _, email := range []string{"unknown-" + user.Email, inactive.Email} {
+			if _, err := s.Users.CreatePasswordReset(ctx, email, uuid.New().String(), time.Hour, resetEmail); !errors.Is(err, ErrNotFound) {
+				t.Errorf("expected %v for %s and got %v", ErrNotFound, email, err)
+			}
+		}
+
+		if len(emails) != 0 {
+			t.Errorf("expected no email and got %v", emails)
+		}
+	})
+
+	t.Run("should queue the reset email", func(t *testing.T) {
+		issue(t, time.Hour)
+
+		var queued int
+		if err := db.QueryRow(`SELECT COUNT(*) FROM email_queue WHERE email = $1`, user.Email).Scan(&queued); err != nil {
+			t.Fatalf("error: %s\n", err.Error())
+		}
+
+		if queued == 0 {
+			t.Error("expected the reset email to be queued")
+		}
+	})
+
+	t.Run("should reject an expired token", func(t *testing.T) {
+		token := issue(t, -time.Minute)
+
+		if err := s.Users.ResetPassword(ctx, token, "new password"); !errors.Is(err, ErrNotFound) {
+			t.Errorf("expected %v and got %v", ErrNotFound, err)
+		}
+	})
+
+	t.Run("should reject a token replaced by a newer one", func(t *testing.T) {
+		token := issue(t, time.Hour)
+		issue(t, time.Hour)
+
+		if err := s.Users.ResetPassword(ctx, token, "new password"); !errors.Is(err, ErrNotFound) {
+			t.Errorf("expected %v and got %v", ErrNotFound, err)
+		}
+	})
+
+	t.Run("should reset the password once and revoke every session", func(t *testing.T) {
+		sessionIds := []string{uuid.New().String(), uuid.New().String()}
+		for _, id := range sessionIds {
+			if err := s.Sessions.Create(ctx, &Session{ID: id, UserId: user.ID}, uuid.New().String(), time.Hour); err != nil {
+				t.Fatalf("error: %s\n", err.Error())
+			}
+		}
+
+		token := issue(t, time.Hour)
+		if err := s.Users.ResetPassword(ctx, token, "new password"); err != nil {
+			t.Fatalf("error: %s\n", err.Error())
+		}
+
+		for _, id := range sessionIds {
+			active, err := s.Sessions.IsActive(ctx, id)
+			if err != nil {
+				t.Fatalf("error: %s\n", err.Error())
+			}
+			if active {
+				t.Errorf("expected session %s to be revoked", id)
+			}
+		}
+
+		updated, err := s.Users.GetByEmail(ctx, user.Email)
+		if err != nil {
+			t.Fatalf("error: %s\n", err.Error())
+		}
+		if err := updated.Password.Compare("new password"); err != nil {
+			t.Errorf("expected the new password to match, got %v", err)
+		}
+
+		if err := s.Users.ResetPassword(ctx, token, "another password"); !errors.Is(err, ErrNotFound) {
+			t.Errorf("expected a used token to be rejected with %v and got %v", ErrNotFound, err)
+		}
+	})
+}
+
+func TestReissueInvitation(t *testing.T) {
+	db := newTestDB(t)
+	s := NewStorage(db)
+	ctx := context.Background()
+
+	users := createTestUsers(t, s, db, "pending", "activated")
+	pending, activated := users[0], users[1]
+	if _, err := db.Exec(`UPDATE users SET is_active = true WHERE id = $1`, activated.ID); err != nil {
+		t.Fatalf("error: %s\n", err.Error())
+	}
+	t.Cleanup(func() {
+		db.Exec(`DELETE FROM user_invitations WHERE user_id = $1`, pending.ID)
+		db.Exec(`DELETE FROM email_queue WHERE email = $1`, pending.Email)
+	})
+
+	var emails []string
+	invitationEmail := func(u *User) (*QueuedEmail, error) {
+		emails = append(emails, u.Email)
+		return &QueuedEmail{Template: "invitation", UserName: u.UserName, Email: u.Email, Data: []byte(`{}`)}, nil
+	}
+
+	t.Run("should queue no email for an unknown or active account", func(t *testing.T) {
+		for _, email := range []string{"unknown-" + pending.Email, activated.Email} {
+			if _, err := s.Users.ReissueInvitation(ctx, email, uuid.New().String(), time.Hour, invitationEmail); !errors.Is(err, ErrNotFound) {
+				t.Errorf("expected %v for %s and got %v", ErrNotFound, email, err)
+			}
+		}
+
+		if len(emails) != 0 {
+			t.Errorf("expected no email and got %v", emails)
+		}
+	})
+
+	t.Run("should invalidate the invitations issued before", func(t *testing.T) {
+		older, newer := uuid.New().String(), uuid.New().String()
+		for _, token := range []string{older, newer} {
+			if _, err := s.Users.ReissueInvitation(ctx, pending.Email, token, time.Hour, invitationEmail); err != nil {
+				t.Fatalf("error: %s\n", err.Error())
+			}
+		}
+
+		if len(emails) != 2 {
+			t.Errorf("expected an email per invitation and got %v", emails)
+		}
+
+		if _, err := s.Users.Activate(ctx, older); !errors.Is(err, ErrNotFound) {
+			t.Errorf("expected the older invitation to be rejected with %v and got %v", ErrNotFound, err)
+		}
+
+		user, err := s.Users.Activate(ctx, newer)
+		if err != nil {
+			t.Fatalf("error: %s\n", err.Error())
+		}
+
+		if user.ID != pending.ID || !user.IsActive {
+			t.Errorf("expected user %d to be activated and got %+v", pending.ID, user)
+		}
+	})
+}
+
+func TestPurgeInactive(t *testing.T) {
+	db := newTestDB(t)
+	s := NewStorage(db)
+	ctx := context.Background()
+
+	users := createTestUsers(t, s, db, "stale", "fresh", "staleactive", "stalepending", "staleexpiring", "staleexpired")
+	stale, fresh, active, pending, expiring, expired := users[0], users[1], users[2], users[3], users[4], users[5]
+	t.Cleanup(func() {
+		for _, user := range users {
+			db.Exec(`DELETE FROM user_invitations WHERE user_id = $1`, user.ID)
+			db.Exec(`DELETE FROM email_queue WHERE email = $1`, user.Email)
+		}
+	})
+
+	for _, user := range []*User{stale, active, pending, expiring, expired} {
+		if _, err := db.Exec(`UPDATE users SET created_at = NOW() - interval '1 day' WHERE id = $1`, user.ID); err != nil {
+			t.Fatalf("error: %s\n", err.Error())
+		}
+	}
+	if _, err := db.Exec(`UPDATE users SET is_active = true WHERE id = $1`, active.ID); err != nil {
+		t.Fatalf("error: %s\n", err.Error())
+	}
+
+	invitationEmail := func(u *User) (*QueuedEmail, error) {
+		return &QueuedEmail{Template: "invitation", UserName: u.UserName, Email: u.Email, Data: []byte(`{}`)}, nil
+	}
+	for user, exp := range map[*User]time.Duration{
+		pending:  time.Hour,
+		expiring: -time.Minute,
+		expired:  -2 * time.Hour,
+	} {
+		if _, err := s.Users.ReissueInvitation(ctx, user.Email, uuid.New().String(), exp, invitationEmail); err != nil {
+			t.Fatalf("error: %s\n", err.Error())
+		}
+	}
+
+	deleted, err := s.Users.PurgeInactive(ctx, time.Hour)
+	if err != nil {
+		t.Fatalf("error: %s\n", err.Error())
+	}
+
+	if deleted < 2 {
+		t.Errorf("expected at least the two stale users to be deleted and got %d", deleted)
+	}
+
+	for user, want := range map[*User]bool{
+		stale:    false,
+		fresh:    true,
+		active:   true,
+		pending:  true,
+		expiring: true,
+		expired:  false,
+	} {
+		var exists bool
+		if err := db.QueryRow(`SELECT EXISTS (SELECT 1 FROM users WHERE id = $1)`, user.ID).Scan(&exists); err != nil {
+			t.Fatalf("error: %s\n", err.Error())
+		}
+
+		if exists != want {
+			t.Errorf("expected user %s to exist: %t, got %t", user.UserName, want, exists)
+		}
+	}
+}
@@ -0,0 +1,12 @@
+package store
+
+// visibleTo filters out rows whose author, in column, hides their content
+// from the user passed as the given placeholder: authors that blocked the
+// viewer, and private authors the viewer does not follow.
+func visibleTo(column, viewerPlaceholder string) string {
+	return notBlockedBy(column, viewerPlaceholder) + ` AND (
+		` + column + ` = ` + viewerPlaceholder + ` OR
+		NOT EXISTS (SELECT 1 FROM users AS pu WHERE pu.id = ` + column + ` AND pu.is_private) OR
+		EXISTS (SELECT 1 FROM followers AS vf WHERE vf.user_id = ` + viewerPlaceholder + ` AND vf.follower_id = ` + column + `)
+	)`
+}
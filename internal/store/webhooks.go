@@ -0,0 +1,347 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"slices"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+const (
+	WebhookUserActivated  = "user.activated"
+	WebhookPostCreated    = "post.created"
+	WebhookPostDeleted    = "post.deleted"
+	WebhookCommentCreated = "comment.created"
+)
+
+// WebhookEvents lists the events a webhook can subscribe to.
+var WebhookEvents = []string{WebhookUserActivated, WebhookPostCreated, WebhookPostDeleted, WebhookCommentCreated}
+
+func IsValidWebhookEvent(event string) bool {
+	return slices.Contains(WebhookEvents, event)
+}
+
+const (
+	DeliveryPending   = "pending"
+	DeliveryDelivered = "delivered"
+	// DeliveryDead marks a delivery that ran out of attempts, it is only
+	// retried when redelivered by an admin.
+	DeliveryDead = "dead"
+)
+
+type Webhook struct {
+	ID  int    `json:"id"`
+	URL string `json:"url"`
+	// Secret signs the deliveries, it is only returned when the webhook is
+	// created.
+	Secret    string    `json:"secret,omitempty"`
+	Events    []string  `json:"events"`
+	Active    bool      `json:"active"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type WebhookDelivery struct {
+	ID             int             `json:"id"`
+	WebhookId      int             `json:"webhook_id"`
+	Event          string          `json:"event"`
+	Payload        json.RawMessage `json:"payload"`
+	Status         string          `json:"status"`
+	Attempts       int             `json:"attempts"`
+	NextAttemptAt  time.Time       `json:"next_attempt_at"`
+	ResponseStatus *int            `json:"response_status"`
+	LastError      *string         `json:"last_error"`
+	CreatedAt      time.Time       `json:"created_at"`
+	DeliveredAt    *time.Time      `json:"delivered_at"`
+}
+
+// DueDelivery is a delivery claimed by a worker along with the endpoint it
+// goes to.
+type DueDelivery struct {
+	WebhookDelivery
+	URL    string
+	Secret string
+}
+
+type WebhookStore struct {
+	db *sql.DB
+}
+
+func (s *WebhookStore) Create(ctx context.Context, webhook *Webhook) error {
+	query := `
+	INSERT INTO webhooks (url,secret,events,active)
+	VALUES ($1,$2,$3,$4) RETURNING id,created_at,updated_at
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, queryTimeoutDuration)
+	defer cancel()
+
+	return s.db.QueryRowContext(ctx, query, webhook.URL, webhook.Secret, pq.Array(webhook.Events), webhook.Active).
+		Scan(&webhook.ID, &webhook.CreatedAt, &webhook.UpdatedAt)
+}
+
+func (s *WebhookStore) GetAll(ctx context.Context) ([]Webhook, error) {
+	query := `SELECT id,url,events,active,created_at,updated_at FROM webhooks ORDER BY id`
+
+	ctx, cancel := context.WithTimeout(ctx, queryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	webhooks := make([]Webhook, 0)
+	for rows.Next() {
+		var w Webhook
+		if err := rows.Scan(&w.ID, &w.URL, pq.Array(&w.Events), &w.Active, &w.CreatedAt, &w.UpdatedAt); err != nil {
+			return nil, err
+		}
+		webhooks = append(webhooks, w)
+	}
+
+	return webhooks, rows.Err()
+}
+
+func (s *WebhookStore) GetById(ctx context.Context, id int) (*Webhook, error) {
+	query := `SELECT id,url,events,active,created_at,updated_at FROM webhooks WHERE id = $1`
+
+	ctx, cancel := context.WithTimeout(ctx, queryTimeoutDuration)
+	defer cancel()
+
+	w := &Webhook{}
+	err := s.db.QueryRowContext(ctx, query, id).
+		Scan(&w.ID, &w.URL, pq.Array(&w.Events), &w.Active, &w.CreatedAt, &w.UpdatedAt)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return w, nil
+}
+
+func (s *WebhookStore) Update(ctx context.Context, webhook *Webhook) error {
+	query := `
+	UPDATE webhooks SET url = $2, events = $3, active = $4, updated_at = NOW()
+	WHERE id = $1
+	RETURNING updated_at
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, queryTimeoutDuration)
+	defer cancel()
+
+	err := s.db.QueryRowContext(ctx, query, webhook.ID, webhook.URL, pq.Array(webhook.Events), webhook.Active).
+		Scan(&webhook.UpdatedAt)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrNotFound
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *WebhookStore) Delete(ctx context.Context, id int) error {
+	query := `DELETE FROM webhooks WHERE id = $1`
+
+	ctx, cancel := context.WithTimeout(ctx, queryTimeoutDuration)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, query, id)
+	if err != nil {
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// Enqueue queues a delivery of the event to every active webhook subscribed
+// to it. Enqueueing an event again with the same key is a no-op.
+func (s *WebhookStore) Enqueue(ctx context.Context, event, key string, data any) error {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	query := `
+	INSERT INTO webhook_deliveries (webhook_id,event,payload,idempotency_key)
+	SELECT w.id, $1::varchar, $2::jsonb, $3::text FROM webhooks AS w
+	WHERE w.active AND $1 = ANY(w.events)
+	ON CONFLICT (webhook_id, idempotency_key) DO NOTHING
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, queryTimeoutDuration)
+	defer cancel()
+
+	_, err = s.db.ExecContext(ctx, query, event, string(payload), key)
+
+	return err
+}
+
+var deliveryQueue = queue{
+	table: "webhook_deliveries",
+	due: `
+		SELECT q.id, w.url, w.secret FROM webhook_deliveries AS q
+		INNER JOIN webhooks AS w ON w.id = q.webhook_id
+		WHERE q.status = 'pending' AND q.next_attempt_at <= NOW() AND w.active
+		ORDER BY q.next_attempt_at`,
+	returning: `q.id, q.webhook_id, q.event, q.payload, q.status, q.attempts, q.next_attempt_at, q.created_at, due.url, due.secret`,
+}
+
+// ClaimDue claims up to limit pending deliveries of active webhooks that are
+// due.
+func (s *WebhookStore) ClaimDue(ctx context.Context, limit int, lease time.Duration) ([]DueDelivery, error) {
+	return claim(ctx, s.db, deliveryQueue, limit, lease, func(rows *sql.Rows, d *DueDelivery) error {
+		return rows.Scan(
+			&d.ID,
+			&d.WebhookId,
+			&d.Event,
+			&d.Payload,
+			&d.Status,
+			&d.Attempts,
+			&d.NextAttemptAt,
+			&d.CreatedAt,
+			&d.URL,
+			&d.Secret,
+		)
+	})
+}
+
+func (s *WebhookStore) MarkDelivered(ctx context.Context, id, responseStatus int) error {
+	query := `
+	UPDATE webhook_deliveries
+	SET status = 'delivered', response_status = $2, last_error = NULL, delivered_at = NOW()
+	WHERE id = $1
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, queryTimeoutDuration)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, query, id, responseStatus)
+
+	return err
+}
+
+// MarkFailed records a failed attempt. The delivery is retried at retryAt,
+// or moved to the dead letter state when retryAt is nil. responseStatus is 0
+// when no response was received.
+func (s *WebhookStore) MarkFailed(ctx context.Context, id, responseStatus int, lastError string, retryAt *time.Time) error {
+	query := `
+	UPDATE webhook_deliveries
+	SET status = $2, response_status = NULLIF($3::int, 0), last_error = $4, next_attempt_at = COALESCE($5, next_attempt_at)
+	WHERE id = $1
+	`
+
+	status := DeliveryPending
+	if retryAt == nil {
+		status = DeliveryDead
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, queryTimeoutDuration)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, query, id, status, responseStatus, lastError, retryAt)
+
+	return err
+}
+
+// GetDeliveries returns a page of the deliveries of a webhook, most recent
+// first, paginated by (created_at, id).
+func (s *WebhookStore) GetDeliveries(ctx context.Context, webhookId int, cursor *FeedCursor, limit int) ([]WebhookDelivery, error) {
+	var cursorTime *time.Time
+	var cursorId int
+	if cursor != nil {
+		cursorTime = &cursor.CreatedAt
+		cursorId = cursor.ID
+	}
+
+	query := `
+	SELECT id, webhook_id, event, payload, status, attempts, next_attempt_at, response_status, last_error, created_at, delivered_at
+	FROM webhook_deliveries
+	WHERE webhook_id = $1 AND ($3::timestamptz IS NULL OR (created_at, id) < ($3, $4))
+	ORDER BY created_at DESC, id DESC
+	LIMIT $2
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, queryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, webhookId, limit, cursorTime, cursorId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	deliveries := make([]WebhookDelivery, 0)
+	for rows.Next() {
+		var d WebhookDelivery
+		if err := rows.Scan(
+			&d.ID,
+			&d.WebhookId,
+			&d.Event,
+			&d.Payload,
+			&d.Status,
+			&d.Attempts,
+			&d.NextAttemptAt,
+			&d.ResponseStatus,
+			&d.LastError,
+			&d.CreatedAt,
+			&d.DeliveredAt,
+		); err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, d)
+	}
+
+	return deliveries, rows.Err()
+}
+
+// Redeliver queues a dead or delivered delivery of the webhook again, with a
+// fresh set of attempts.
+func (s *WebhookStore) Redeliver(ctx context.Context, webhookId, deliveryId int) error {
+	query := `
+	UPDATE webhook_deliveries
+	SET status = 'pending', attempts = 0, next_attempt_at = NOW()
+	WHERE id = $1 AND webhook_id = $2 AND status <> 'pending'
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, queryTimeoutDuration)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, query, deliveryId, webhookId)
+	if err != nil {
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
@@ -0,0 +1,137 @@
+package stream
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+)
+
+const (
+	EventPost         = "post"
+	EventComment      = "comment"
+	EventReaction     = "reaction"
+	EventNotification = "notification"
+)
+
+// HistorySize is the number of recent events kept per user so a client that
+// reconnects with the ID of the last event it saw can catch up.
+const HistorySize = 100
+
+// subscriptionBuffer is the number of events a subscriber can fall behind
+// before its subscription is closed.
+const subscriptionBuffer = 32
+
+// Event is delivered to a single user. IDs increase per user and are used as
+// the SSE event ID.
+type Event struct {
+	ID   int64           `json:"id"`
+	Type string          `json:"type"`
+	Data json.RawMessage `json:"data"`
+}
+
+type Broker interface {
+	// Publish sends an event of the given type to every connected client of
+	// the users.
+	Publish(ctx context.Context, userIds []int, eventType string, data any) error
+	// Subscribe registers a client of the user. Events published after the
+	// call are received on the subscription until it is closed.
+	Subscribe(userId int) *Subscription
+	// Replay returns the recent events of the user published after the
+	// event with the given ID, oldest first.
+	Replay(ctx context.Context, userId int, afterId int64) ([]Event, error)
+	// Close ends every subscription, the broker can not be used afterwards.
+	Close() error
+}
+
+// Subscription receives the events of a user. Events is closed when the
+// subscription is closed, when the subscriber falls too far behind or when
+// the broker shuts down; the client is expected to reconnect and resume.
+type Subscription struct {
+	Events <-chan Event
+
+	events chan Event
+	userId int
+	hub    *hub
+	once   sync.Once
+}
+
+func (s *Subscription) Close() {
+	s.hub.remove(s)
+}
+
+// hub dispatches events to the subscriptions of this process.
+type hub struct {
+	mu     sync.Mutex
+	subs   map[int]map[*Subscription]struct{}
+	closed bool
+}
+
+func newHub() *hub {
+	return &hub{subs: make(map[int]map[*Subscription]struct{})}
+}
+
+func (h *hub) subscribe(userId int) *Subscription {
+	events := make(chan Event, subscriptionBuffer)
+	sub := &Subscription{Events: events, events: events, userId: userId, hub: h}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.closed {
+		sub.once.Do(func() { close(sub.events) })
+		return sub
+	}
+
+	if h.subs[userId] == nil {
+		h.subs[userId] = make(map[*Subscription]struct{})
+	}
+	h.subs[userId][sub] = struct{}{}
+
+	return sub
+}
+
+func (h *hub) remove(sub *Subscription) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.detach(sub)
+}
+
+// detach must be called with h.mu held.
+func (h *hub) detach(sub *Subscription) {
+	if subs, ok := h.subs[sub.userId]; ok {
+		delete(subs, sub)
+		if len(subs) == 0 {
+			delete(h.subs, sub.userId)
+		}
+	}
+
+	sub.once.Do(func() { close(sub.events) })
+}
+
+// deliver never blocks the publisher, a subscriber whose buffer is full is
+// dropped and resumes from its last event after reconnecting.
+func (h *hub) deliver(userId int, event Event) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for sub := range h.subs[userId] {
+		select {
+		case sub.events <- event:
+		default:
+			h.detach(sub)
+		}
+	}
+}
+
+func (h *hub) close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.closed = true
+	for _, subs := range h.subs {
+		for sub := range subs {
+			h.detach(sub)
+		}
+	}
+}
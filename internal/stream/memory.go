@@ -0,0 +1,74 @@
+package stream
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+)
+
+// MemoryBroker delivers events to the clients connected to this process
+// only. It is used when redis is disabled.
+type MemoryBroker struct {
+	hub *hub
+
+	mu      sync.Mutex
+	seq     map[int]int64
+	history map[int][]Event
+}
+
+func NewMemoryBroker() *MemoryBroker {
+	return &MemoryBroker{
+		hub:     newHub(),
+		seq:     make(map[int]int64),
+		history: make(map[int][]Event),
+	}
+}
+
+func (b *MemoryBroker) Publish(_ context.Context, userIds []int, eventType string, data any) error {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	for _, userId := range userIds {
+		b.mu.Lock()
+		b.seq[userId]++
+		event := Event{ID: b.seq[userId], Type: eventType, Data: payload}
+
+		history := append(b.history[userId], event)
+		if len(history) > HistorySize {
+			history = history[len(history)-HistorySize:]
+		}
+		b.history[userId] = history
+
+		// delivering under the lock keeps the events of a user in ID order
+		// across concurrent publishers, deliver never blocks
+		b.hub.deliver(userId, event)
+		b.mu.Unlock()
+	}
+
+	return nil
+}
+
+func (b *MemoryBroker) Subscribe(userId int) *Subscription {
+	return b.hub.subscribe(userId)
+}
+
+func (b *MemoryBroker) Replay(_ context.Context, userId int, afterId int64) ([]Event, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	events := make([]Event, 0)
+	for _, event := range b.history[userId] {
+		if event.ID > afterId {
+			events = append(events, event)
+		}
+	}
+
+	return events, nil
+}
+
+func (b *MemoryBroker) Close() error {
+	b.hub.close()
+	return nil
+}
@@ -0,0 +1,125 @@
+package stream
+
+import (
+	"context"
+	"sync"
+	"testing"
+)
+
+func TestMemoryBroker(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("should deliver events to the subscribers of the user only", func(t *testing.T) {
+		b := NewMemoryBroker()
+		defer b.Close()
+
+		sub := b.Subscribe(1)
+		defer sub.Close()
+		other := b.Subscribe(2)
+		defer other.Close()
+
+		if err := b.Publish(ctx, []int{1}, EventPost, map[string]int{"id": 42}); err != nil {
+			t.Fatalf("error: %s\n", err.Error())
+		}
+
+		event := <-sub.Events
+		if event.ID != 1 || event.Type != EventPost || string(event.Data) != `{"id":42}` {
+			t.Errorf("unexpected event %+v", event)
+		}
+
+		select {
+		case event := <-other.Events:
+			t.Errorf("unexpected event %+v for another user", event)
+		default:
+		}
+	})
+
+	t.Run("should replay the events after the given ID", func(t *testing.T) {
+		b := NewMemoryBroker()
+		defer b.Close()
+
+		for i := 0; i < HistorySize+5; i++ {
+			if err := b.Publish(ctx, []int{1}, EventNotification, i); err != nil {
+				t.Fatalf("error: %s\n", err.Error())
+			}
+		}
+
+		events, err := b.Replay(ctx, 1, HistorySize)
+		if err != nil {
+			t.Fatalf("error: %s\n", err.Error())
+		}
+
+		if len(events) != 5 || events[0].ID != HistorySize+1 {
+			t.Errorf("unexpected replay %+v", events)
+		}
+
+		events, _ = b.Replay(ctx, 1, 0)
+		if len(events) != HistorySize || events[0].ID != 6 {
+			t.Errorf("expected the history to be trimmed to %d events and got %d", HistorySize, len(events))
+		}
+	})
+
+	t.Run("should deliver concurrently published events in ID order", func(t *testing.T) {
+		b := NewMemoryBroker()
+		defer b.Close()
+
+		sub := b.Subscribe(1)
+		defer sub.Close()
+
+		const publishers = 8
+		perPublisher := subscriptionBuffer / publishers
+
+		var wg sync.WaitGroup
+		for range publishers {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for i := range perPublisher {
+					b.Publish(ctx, []int{1}, EventPost, i)
+				}
+			}()
+		}
+		wg.Wait()
+
+		for i := range publishers * perPublisher {
+			event := <-sub.Events
+			if event.ID != int64(i+1) {
+				t.Fatalf("expected event %d and got %d", i+1, event.ID)
+			}
+		}
+	})
+
+	t.Run("should close subscribers that fall behind", func(t *testing.T) {
+		b := NewMemoryBroker()
+		defer b.Close()
+
+		sub := b.Subscribe(1)
+		for i := 0; i <= subscriptionBuffer; i++ {
+			b.Publish(ctx, []int{1}, EventPost, i)
+		}
+
+		received := 0
+		for range sub.Events {
+			received++
+		}
+
+		if received != subscriptionBuffer {
+			t.Errorf("expected %d buffered events and got %d", subscriptionBuffer, received)
+		}
+	})
+
+	t.Run("should end subscriptions on close", func(t *testing.T) {
+		b := NewMemoryBroker()
+		sub := b.Subscribe(1)
+
+		b.Close()
+
+		if _, ok := <-sub.Events; ok {
+			t.Error("expected the subscription to be closed")
+		}
+
+		if _, ok := <-b.Subscribe(1).Events; ok {
+			t.Error("expected subscriptions after close to be closed")
+		}
+	})
+}
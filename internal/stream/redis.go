@@ -0,0 +1,137 @@
+package stream
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// eventsChannel is the pub/sub channel shared by every API instance, each
+// instance delivers the events of the users connected to it.
+const eventsChannel = "stream/events"
+
+// historyExpTime drops the history of users that have been quiet for a while,
+// their clients reconnect with a full reload instead.
+const historyExpTime = 24 * time.Hour
+
+// publishScript numbers an event, appends it to the history of the user and
+// publishes it. The data is already JSON and is spliced in as is.
+var publishScript = redis.NewScript(`
+local id = redis.call('INCR', KEYS[1])
+local event = '{"id":' .. id .. ',"type":' .. cjson.encode(ARGV[1]) .. ',"data":' .. ARGV[2] .. '}'
+redis.call('ZADD', KEYS[2], id, event)
+redis.call('ZREMRANGEBYRANK', KEYS[2], 0, -tonumber(ARGV[3]) - 1)
+redis.call('EXPIRE', KEYS[2], ARGV[4])
+redis.call('PUBLISH', ARGV[5], '{"user_id":' .. ARGV[6] .. ',"event":' .. event .. '}')
+return id
+`)
+
+type message struct {
+	UserId int   `json:"user_id"`
+	Event  Event `json:"event"`
+}
+
+// RedisBroker delivers events through redis pub/sub so clients connected to
+// any API instance receive them, and keeps the history in redis.
+type RedisBroker struct {
+	rdb    *redis.Client
+	pubsub *redis.PubSub
+	hub    *hub
+	done   chan struct{}
+}
+
+func NewRedisBroker(ctx context.Context, rdb *redis.Client) (*RedisBroker, error) {
+	pubsub := rdb.Subscribe(ctx, eventsChannel)
+	if _, err := pubsub.Receive(ctx); err != nil {
+		pubsub.Close()
+		return nil, err
+	}
+
+	b := &RedisBroker{
+		rdb:    rdb,
+		pubsub: pubsub,
+		hub:    newHub(),
+		done:   make(chan struct{}),
+	}
+
+	go b.receive()
+
+	return b, nil
+}
+
+func (b *RedisBroker) receive() {
+	defer close(b.done)
+
+	for msg := range b.pubsub.Channel() {
+		var m message
+		if err := json.Unmarshal([]byte(msg.Payload), &m); err != nil {
+			continue
+		}
+
+		b.hub.deliver(m.UserId, m.Event)
+	}
+}
+
+func seqKey(userId int) string {
+	return fmt.Sprintf("stream/%d/seq", userId)
+}
+
+func historyKey(userId int) string {
+	return fmt.Sprintf("stream/%d/history", userId)
+}
+
+func (b *RedisBroker) Publish(ctx context.Context, userIds []int, eventType string, data any) error {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	for _, userId := range userIds {
+		err := publishScript.Run(ctx, b.rdb,
+			[]string{seqKey(userId), historyKey(userId)},
+			eventType, string(payload), HistorySize, int(historyExpTime.Seconds()), eventsChannel, userId,
+		).Err()
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (b *RedisBroker) Subscribe(userId int) *Subscription {
+	return b.hub.subscribe(userId)
+}
+
+func (b *RedisBroker) Replay(ctx context.Context, userId int, afterId int64) ([]Event, error) {
+	members, err := b.rdb.ZRangeByScore(ctx, historyKey(userId), &redis.ZRangeBy{
+		Min: "(" + strconv.FormatInt(afterId, 10),
+		Max: "+inf",
+	}).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	events := make([]Event, 0, len(members))
+	for _, member := range members {
+		var event Event
+		if err := json.Unmarshal([]byte(member), &event); err != nil {
+			return nil, err
+		}
+		events = append(events, event)
+	}
+
+	return events, nil
+}
+
+func (b *RedisBroker) Close() error {
+	err := b.pubsub.Close()
+	<-b.done
+	b.hub.close()
+
+	return err
+}
@@ -0,0 +1,95 @@
+package webhook_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/AlieNoori/social/internal/webhook"
+	"github.com/AlieNoori/social/internal/webhook/webhooktest"
+)
+
+func TestSignature(t *testing.T) {
+	body := []byte(`{"event":"post.created"}`)
+
+	t.Run("should verify its own signatures", func(t *testing.T) {
+		header := webhook.Sign("secret", time.Now(), body)
+
+		if err := webhook.Verify("secret", header, body, time.Minute); err != nil {
+			t.Errorf("error: %s\n", err.Error())
+		}
+	})
+
+	t.Run("should reject tampered, foreign and stale signatures", func(t *testing.T) {
+		now := time.Now()
+
+		cases := map[string]error{
+			webhook.Sign("secret", now, []byte(`{}`)):         webhook.ErrInvalidSignature,
+			webhook.Sign("other", now, body):                  webhook.ErrInvalidSignature,
+			webhook.Sign("secret", now.Add(-time.Hour), body): webhook.ErrExpiredSignature,
+			"v1=deadbeef": webhook.ErrInvalidSignature,
+			"t=abc," + webhook.Sign("secret", now, body)[len("t="):]: webhook.ErrInvalidSignature,
+		}
+
+		for header, want := range cases {
+			if err := webhook.Verify("secret", header, body, time.Minute); err != want {
+				t.Errorf("expected %v for %q and got %v", want, header, err)
+			}
+		}
+	})
+}
+
+func TestRetry(t *testing.T) {
+	want := map[int]time.Duration{1: 30 * time.Second, 2: time.Minute, 4: 4 * time.Minute, 20: 6 * time.Hour}
+
+	for attempts, backoff := range want {
+		if got := webhook.Retry.Delay(attempts); got != backoff {
+			t.Errorf("expected a backoff of %s after %d attempts and got %s", backoff, attempts, got)
+		}
+	}
+}
+
+func TestSender(t *testing.T) {
+	receiver := webhooktest.NewReceiver("secret")
+	defer receiver.Close()
+
+	sender := webhook.NewSender(time.Second)
+	delivery := webhook.Delivery{
+		ID:     7,
+		URL:    receiver.URL,
+		Secret: "secret",
+		Event:  "post.created",
+		Body:   []byte(`{"id":7}`),
+	}
+
+	t.Run("should deliver signed requests", func(t *testing.T) {
+		status, err := sender.Send(context.Background(), delivery)
+		if err != nil {
+			t.Fatalf("error: %s\n", err.Error())
+		}
+
+		if status != http.StatusOK {
+			t.Errorf("expected status %d and got %d", http.StatusOK, status)
+		}
+
+		got := receiver.Deliveries()
+		if len(got) != 1 || got[0].ID != "7" || got[0].Event != "post.created" || string(got[0].Body) != `{"id":7}` {
+			t.Errorf("unexpected deliveries %+v", got)
+		}
+	})
+
+	t.Run("should fail on a wrong secret or an error response", func(t *testing.T) {
+		wrong := delivery
+		wrong.Secret = "other"
+
+		if status, err := sender.Send(context.Background(), wrong); err == nil || status != http.StatusUnauthorized {
+			t.Errorf("expected the receiver to reject the signature, got %d %v", status, err)
+		}
+
+		receiver.RespondWith(http.StatusServiceUnavailable)
+		if status, err := sender.Send(context.Background(), delivery); err == nil || status != http.StatusServiceUnavailable {
+			t.Errorf("expected the delivery to fail, got %d %v", status, err)
+		}
+	})
+}
@@ -0,0 +1,80 @@
+// Package webhooktest provides a webhook endpoint for tests, it records the
+// deliveries it receives after verifying their signature.
+package webhooktest
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"time"
+
+	"github.com/AlieNoori/social/internal/webhook"
+)
+
+type Delivery struct {
+	ID    string
+	Event string
+	Body  []byte
+}
+
+type Receiver struct {
+	*httptest.Server
+	Secret string
+
+	mu         sync.Mutex
+	deliveries []Delivery
+	status     int
+}
+
+// NewReceiver starts a receiver verifying deliveries signed with secret. The
+// caller closes it.
+func NewReceiver(secret string) *Receiver {
+	r := &Receiver{Secret: secret, status: http.StatusOK}
+	r.Server = httptest.NewServer(http.HandlerFunc(r.handle))
+
+	return r
+}
+
+// RespondWith sets the status of the responses to the next deliveries, to
+// make the receiver fail for example.
+func (r *Receiver) RespondWith(status int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.status = status
+}
+
+// Deliveries returns the deliveries accepted so far.
+func (r *Receiver) Deliveries() []Delivery {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return append([]Delivery(nil), r.deliveries...)
+}
+
+func (r *Receiver) handle(w http.ResponseWriter, req *http.Request) {
+	body, err := io.ReadAll(req.Body)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	if err := webhook.Verify(r.Secret, req.Header.Get(webhook.SignatureHeader), body, time.Minute); err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.status >= 200 && r.status < 300 {
+		r.deliveries = append(r.deliveries, Delivery{
+			ID:    req.Header.Get(webhook.DeliveryHeader),
+			Event: req.Header.Get(webhook.EventHeader),
+			Body:  body,
+		})
+	}
+
+	w.WriteHeader(r.status)
+}